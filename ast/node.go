@@ -77,6 +77,11 @@ type (
 		Right    Expression
 	}
 
+	AwaitExpression struct {
+		Await    file.Idx
+		Argument Expression
+	}
+
 	BadExpression struct {
 		From file.Idx
 		To   file.Idx
@@ -100,6 +105,9 @@ type (
 		Member       Expression
 		LeftBracket  file.Idx
 		RightBracket file.Idx
+
+		// Optional is set for a?.[b]
+		Optional bool
 	}
 
 	CallExpression struct {
@@ -107,6 +115,19 @@ type (
 		LeftParenthesis  file.Idx
 		ArgumentList     []Expression
 		RightParenthesis file.Idx
+
+		// Optional is set for a?.()
+		Optional bool
+	}
+
+	// ClassLiteral is a class declaration or expression, eg.
+	// class A extends B { m() {} }.
+	ClassLiteral struct {
+		Class      file.Idx
+		Name       *Identifier
+		SuperClass Expression
+		Body       []MethodDefinition
+		RightBrace file.Idx
 	}
 
 	ConditionalExpression struct {
@@ -118,6 +139,9 @@ type (
 	DotExpression struct {
 		Left       Expression
 		Identifier *Identifier
+
+		// Optional is set for a?.b
+		Optional bool
 	}
 
 	EmptyExpression struct {
@@ -132,6 +156,14 @@ type (
 		Body          Statement
 		Source        string
 
+		// Arrow is set for arrow functions, whose Function is the index of
+		// "=>". The body of x => x is a block returning x, with the index
+		// of the expression as its LeftBrace.
+		Arrow bool
+
+		// Async is set for async functions, arrow functions and methods.
+		Async bool
+
 		DeclarationList []Declaration
 	}
 
@@ -145,6 +177,19 @@ type (
 		As   *Identifier
 	}
 
+	// MethodDefinition is a method of a class. Kind is "constructor",
+	// "method", "get" or "set".
+	MethodDefinition struct {
+		Idx    file.Idx
+		Key    string
+		Kind   string
+		Static bool
+		Value  *FunctionLiteral
+
+		// Computed is the key of [a]() {}, instead of Key
+		Computed Expression
+	}
+
 	NewExpression struct {
 		New              file.Idx
 		Callee           Expression
@@ -174,12 +219,27 @@ type (
 		Opening file.Idx
 		List    []*Identifier
 		Closing file.Idx
+
+		// Defaults are the default values of the parameters of List, eg. 1
+		// for (a = 1), nil for parameters without one. It is nil if no
+		// parameter has a default value.
+		Defaults []Expression
+
+		// Rest is set for (a, ...b), whose last parameter is the array of
+		// the remaining arguments.
+		Rest bool
 	}
 
 	Property struct {
 		Key   string
 		Kind  string
 		Value Expression
+
+		// Shorthand is set for {a}, whose Value is the identifier a.
+		Shorthand bool
+
+		// Computed is the key of {[a]: b}, instead of Key.
+		Computed Expression
 	}
 
 	RegExpLiteral struct {
@@ -200,9 +260,17 @@ type (
 		Value   string
 	}
 
+	SuperExpression struct {
+		Idx file.Idx
+	}
+
 	DynamicStringExpression struct {
 		Idx  file.Idx
 		List []Expression
+
+		// Raw is the source of the strings between the expressions, which
+		// are the StringLiterals at the even indexes of List.
+		Raw []string
 	}
 
 	ThisExpression struct {
@@ -232,11 +300,13 @@ func (*JSXBlock) _expressionNode()                {}
 func (*WhiteSpaceLiteral) _expressionNode()       {}
 func (*ArrayLiteral) _expressionNode()            {}
 func (*AssignExpression) _expressionNode()        {}
+func (*AwaitExpression) _expressionNode()         {}
 func (*BadExpression) _expressionNode()           {}
 func (*BinaryExpression) _expressionNode()        {}
 func (*BooleanLiteral) _expressionNode()          {}
 func (*BracketExpression) _expressionNode()       {}
 func (*CallExpression) _expressionNode()          {}
+func (*ClassLiteral) _expressionNode()            {}
 func (*ConditionalExpression) _expressionNode()   {}
 func (*DotExpression) _expressionNode()           {}
 func (*EmptyExpression) _expressionNode()         {}
@@ -250,6 +320,7 @@ func (*ObjectLiteral) _expressionNode()           {}
 func (*RegExpLiteral) _expressionNode()           {}
 func (*SequenceExpression) _expressionNode()      {}
 func (*StringLiteral) _expressionNode()           {}
+func (*SuperExpression) _expressionNode()         {}
 func (*DynamicStringExpression) _expressionNode() {}
 func (*ThisExpression) _expressionNode()          {}
 func (*UnaryExpression) _expressionNode()         {}
@@ -289,12 +360,18 @@ type (
 		Consequent []Statement
 	}
 
+	// CatchStatement is the catch of a try statement. Parameter is nil for
+	// catch {}.
 	CatchStatement struct {
 		Catch     file.Idx
 		Parameter *Identifier
 		Body      Statement
 	}
 
+	ClassStatement struct {
+		Class *ClassLiteral
+	}
+
 	DebuggerStatement struct {
 		Debugger file.Idx
 	}
@@ -320,6 +397,16 @@ type (
 		Body   Statement
 	}
 
+	// ForOfStatement is for (a of b) {}. Into is a VariableExpression if
+	// it is declared by the statement.
+	ForOfStatement struct {
+		For    file.Idx
+		Into   Expression
+		Source Expression
+		Body   Statement
+		Token  token.Token // VAR, LET or CONST if Into is declared
+	}
+
 	ForStatement struct {
 		For         file.Idx
 		Initializer Expression
@@ -373,6 +460,11 @@ type (
 		Var      file.Idx
 		List     []Expression
 		Constant bool
+		Token    token.Token // VAR, LET or CONST
+
+		// Destructuring is set for var {a, b} = c, whose List declares a
+		// and b with the properties of c, which is their shared Left.
+		Destructuring bool
 	}
 
 	WhileStatement struct {
@@ -413,11 +505,13 @@ func (*BlockStatement) _statementNode()         {}
 func (*BranchStatement) _statementNode()        {}
 func (*CaseStatement) _statementNode()          {}
 func (*CatchStatement) _statementNode()         {}
+func (*ClassStatement) _statementNode()         {}
 func (*DebuggerStatement) _statementNode()      {}
 func (*DoWhileStatement) _statementNode()       {}
 func (*EmptyStatement) _statementNode()         {}
 func (*ExpressionStatement) _statementNode()    {}
 func (*ForInStatement) _statementNode()         {}
+func (*ForOfStatement) _statementNode()         {}
 func (*ForStatement) _statementNode()           {}
 func (*FunctionStatement) _statementNode()      {}
 func (*IfStatement) _statementNode()            {}
@@ -482,11 +576,13 @@ func (self *JSXBlock) Idx0() file.Idx                { return self.OpeningElemen
 func (self *WhiteSpaceLiteral) Idx0() file.Idx       { return self.Idx }
 func (self *ArrayLiteral) Idx0() file.Idx            { return self.LeftBracket }
 func (self *AssignExpression) Idx0() file.Idx        { return self.Left.Idx0() }
+func (self *AwaitExpression) Idx0() file.Idx         { return self.Await }
 func (self *BadExpression) Idx0() file.Idx           { return self.From }
 func (self *BinaryExpression) Idx0() file.Idx        { return self.Left.Idx0() }
 func (self *BooleanLiteral) Idx0() file.Idx          { return self.Idx }
 func (self *BracketExpression) Idx0() file.Idx       { return self.Left.Idx0() }
 func (self *CallExpression) Idx0() file.Idx          { return self.Callee.Idx0() }
+func (self *ClassLiteral) Idx0() file.Idx            { return self.Class }
 func (self *ConditionalExpression) Idx0() file.Idx   { return self.Test.Idx0() }
 func (self *DotExpression) Idx0() file.Idx           { return self.Left.Idx0() }
 func (self *EmptyExpression) Idx0() file.Idx         { return self.Begin }
@@ -500,6 +596,7 @@ func (self *ObjectLiteral) Idx0() file.Idx           { return self.LeftBrace }
 func (self *RegExpLiteral) Idx0() file.Idx           { return self.Idx }
 func (self *SequenceExpression) Idx0() file.Idx      { return self.Sequence[0].Idx0() }
 func (self *StringLiteral) Idx0() file.Idx           { return self.Idx }
+func (self *SuperExpression) Idx0() file.Idx         { return self.Idx }
 func (self *DynamicStringExpression) Idx0() file.Idx { return self.Idx }
 func (self *ThisExpression) Idx0() file.Idx          { return self.Idx }
 func (self *UnaryExpression) Idx0() file.Idx         { return self.Idx }
@@ -510,11 +607,13 @@ func (self *BlockStatement) Idx0() file.Idx         { return self.LeftBrace }
 func (self *BranchStatement) Idx0() file.Idx        { return self.Idx }
 func (self *CaseStatement) Idx0() file.Idx          { return self.Case }
 func (self *CatchStatement) Idx0() file.Idx         { return self.Catch }
+func (self *ClassStatement) Idx0() file.Idx         { return self.Class.Idx0() }
 func (self *DebuggerStatement) Idx0() file.Idx      { return self.Debugger }
 func (self *DoWhileStatement) Idx0() file.Idx       { return self.Do }
 func (self *EmptyStatement) Idx0() file.Idx         { return self.Semicolon }
 func (self *ExpressionStatement) Idx0() file.Idx    { return self.Expression.Idx0() }
 func (self *ForInStatement) Idx0() file.Idx         { return self.For }
+func (self *ForOfStatement) Idx0() file.Idx         { return self.For }
 func (self *ForStatement) Idx0() file.Idx           { return self.For }
 func (self *FunctionStatement) Idx0() file.Idx      { return self.Function.Idx0() }
 func (self *IfStatement) Idx0() file.Idx            { return self.If }
@@ -546,11 +645,13 @@ func (self *JSXElement) Idx1() file.Idx            { return self.RightTag }
 func (self *WhiteSpaceLiteral) Idx1() file.Idx     { return file.Idx(int(self.Idx) + len(self.Literal)) }
 func (self *ArrayLiteral) Idx1() file.Idx          { return self.RightBracket }
 func (self *AssignExpression) Idx1() file.Idx      { return self.Right.Idx1() }
+func (self *AwaitExpression) Idx1() file.Idx       { return self.Argument.Idx1() }
 func (self *BadExpression) Idx1() file.Idx         { return self.To }
 func (self *BinaryExpression) Idx1() file.Idx      { return self.Right.Idx1() }
 func (self *BooleanLiteral) Idx1() file.Idx        { return file.Idx(int(self.Idx) + len(self.Literal)) }
 func (self *BracketExpression) Idx1() file.Idx     { return self.RightBracket + 1 }
 func (self *CallExpression) Idx1() file.Idx        { return self.RightParenthesis + 1 }
+func (self *ClassLiteral) Idx1() file.Idx          { return self.RightBrace + 1 }
 func (self *ConditionalExpression) Idx1() file.Idx { return self.Test.Idx1() }
 func (self *DotExpression) Idx1() file.Idx         { return self.Identifier.Idx1() }
 func (self *EmptyExpression) Idx1() file.Idx       { return self.End }
@@ -564,6 +665,7 @@ func (self *ObjectLiteral) Idx1() file.Idx         { return self.RightBrace }
 func (self *RegExpLiteral) Idx1() file.Idx         { return file.Idx(int(self.Idx) + len(self.Literal)) }
 func (self *SequenceExpression) Idx1() file.Idx    { return self.Sequence[0].Idx1() }
 func (self *StringLiteral) Idx1() file.Idx         { return file.Idx(int(self.Idx) + len(self.Literal)) }
+func (self *SuperExpression) Idx1() file.Idx       { return self.Idx + 5 } // "super"
 func (self *DynamicStringExpression) Idx1() file.Idx {
 	return self.List[len(self.List)-1].Idx1()
 }
@@ -586,11 +688,13 @@ func (self *BlockStatement) Idx1() file.Idx      { return self.RightBrace + 1 }
 func (self *BranchStatement) Idx1() file.Idx     { return self.Idx }
 func (self *CaseStatement) Idx1() file.Idx       { return self.Consequent[len(self.Consequent)-1].Idx1() }
 func (self *CatchStatement) Idx1() file.Idx      { return self.Body.Idx1() }
+func (self *ClassStatement) Idx1() file.Idx      { return self.Class.Idx1() }
 func (self *DebuggerStatement) Idx1() file.Idx   { return self.Debugger + 8 }
 func (self *DoWhileStatement) Idx1() file.Idx    { return self.Test.Idx1() }
 func (self *EmptyStatement) Idx1() file.Idx      { return self.Semicolon + 1 }
 func (self *ExpressionStatement) Idx1() file.Idx { return self.Expression.Idx1() }
 func (self *ForInStatement) Idx1() file.Idx      { return self.Body.Idx1() }
+func (self *ForOfStatement) Idx1() file.Idx      { return self.Body.Idx1() }
 func (self *ForStatement) Idx1() file.Idx        { return self.Body.Idx1() }
 func (self *FunctionStatement) Idx1() file.Idx   { return self.Function.Idx1() }
 func (self *IfStatement) Idx1() file.Idx {
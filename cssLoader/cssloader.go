@@ -6,11 +6,11 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"os"
 	"strings"
 
 	"github.com/aymerick/douceur/css"
 	"github.com/aymerick/douceur/parser"
+	"github.com/walesey/go-bundle/generator"
 )
 
 //TODO: Implement the correct Class nameing and Outfile configs
@@ -23,6 +23,7 @@ type CssLoader struct {
 	config  Config
 	buffer  *bytes.Buffer
 	out     io.Writer
+	styles  bytes.Buffer
 	classes map[string]string
 	hash    string
 }
@@ -31,11 +32,46 @@ func New(config Config) *CssLoader {
 	return &CssLoader{config: config}
 }
 
+// CssLoader is used both as a generator.ModuleLoader and as a
+// generator.Loader for generator.Bundle.
+var (
+	_ generator.ModuleLoader = (*CssLoader)(nil)
+	_ generator.Loader       = (*CssLoader)(nil)
+)
+
+// LoadModule returns a module exporting the renamed classes of the css in
+// src, and emits the styles of every file loaded in the build as Outfile.
+// The styles are kept by the build, so they start empty in every build.
+func (l *CssLoader) LoadModule(ctx *generator.LoadContext, src io.Reader) (io.Reader, error) {
+	module, styles, err := l.load(src)
+	if err != nil {
+		return nil, err
+	}
+	emitted, _ := ctx.Asset(l.config.Outfile)
+	ctx.EmitAsset(l.config.Outfile, append(append([]byte{}, emitted...), styles...))
+	return module, nil
+}
+
+// Load returns a module exporting the renamed classes of the css in src, and
+// writes the styles of every file loaded so far to Outfile.
 func (l *CssLoader) Load(src io.Reader) (io.Reader, error) {
-	srcData, err := ioutil.ReadAll(src)
+	module, styles, err := l.load(src)
 	if err != nil {
 		return nil, err
 	}
+	l.styles.Write(styles)
+	if err := ioutil.WriteFile(l.config.Outfile, l.styles.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+	return module, nil
+}
+
+// load returns the module of the css in src and its renamed styles.
+func (l *CssLoader) load(src io.Reader) (io.Reader, []byte, error) {
+	srcData, err := ioutil.ReadAll(src)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	l.buffer = new(bytes.Buffer)
 	l.classes = make(map[string]string)
@@ -43,20 +79,14 @@ func (l *CssLoader) Load(src io.Reader) (io.Reader, error) {
 
 	styles, err := parser.Parse(string(srcData))
 	if err != nil {
-		return nil, err
-	}
-
-	out, err := os.Create(l.config.Outfile)
-	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	defer out.Close()
-	l.out = out
-
+	var out bytes.Buffer
+	l.out = &out
 	l.parseStyles(styles)
 
-	return l.buffer, nil
+	return l.buffer, out.Bytes(), nil
 }
 
 func (l *CssLoader) parseStyles(styles *css.Stylesheet) {
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/walesey/go-bundle/generator"
+)
+
+// runFmt runs "go-bundle fmt [flags] [path ...]", which formats JavaScript
+// files like gofmt formats Go files, and returns the exit code.
+func runFmt(args []string) int {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	list := fs.Bool("l", false, "list the files whose formatting differs")
+	diff := fs.Bool("d", false, "print the diffs of the formatting")
+	write := fs.Bool("w", false, "write the formatted code to the files")
+	printOptions := printFlags(fs)
+	fs.Parse(args)
+
+	f := &formatter{
+		print: printOptions(),
+		list:  *list,
+		diff:  *diff,
+		write: *write,
+	}
+
+	// the source is read from stdin without paths
+	if fs.NArg() == 0 {
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Println(err)
+			return 2
+		}
+		if err := f.format("<stdin>", src, false); err != nil {
+			fmt.Println(err)
+			return 2
+		}
+		return 0
+	}
+
+	code := 0
+	for _, path := range fs.Args() {
+		if err := f.formatPath(path); err != nil {
+			fmt.Println(err)
+			code = 2
+		}
+	}
+	return code
+}
+
+type formatter struct {
+	print generator.PrintOptions
+	list  bool
+	diff  bool
+	write bool
+}
+
+// formatPath formats the file path, or the .js files in the directory path.
+func (f *formatter) formatPath(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return f.formatFile(path)
+	}
+
+	var errs []string
+	err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == "node_modules" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(file) == ".js" {
+			if err := f.formatFile(file); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%v", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+func (f *formatter) formatFile(path string) error {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return f.format(path, src, true)
+}
+
+// format formats src, read from the file name, and reports or writes the
+// result like the flags select. Without flags, the formatted code is
+// printed.
+func (f *formatter) format(name string, src []byte, isFile bool) error {
+	formatted, err := generator.Format(src, name, f.print)
+	if err != nil {
+		return err
+	}
+
+	changed := !bytes.Equal(src, formatted)
+	if f.list && changed {
+		fmt.Println(name)
+	}
+	if f.write && isFile && changed {
+		info, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(name, formatted, info.Mode()); err != nil {
+			return err
+		}
+	}
+	if f.diff && changed {
+		fmt.Print(unifiedDiff(name, string(src), string(formatted)))
+	}
+	if !f.list && !f.diff && (!f.write || !isFile) {
+		os.Stdout.Write(formatted)
+	}
+	return nil
+}
+
+// diffContext is the number of unchanged lines around the changes of a
+// diff.
+const diffContext = 3
+
+// unifiedDiff returns the changes from a to b in the unified diff format.
+func unifiedDiff(name, a, b string) string {
+	as, bs := splitLines(a), splitLines(b)
+
+	// the lines of both files, with ' ', '-' or '+' before them
+	type line struct {
+		op   byte
+		text string
+		a, b int
+	}
+	var lines []line
+	i, j := 0, 0
+	for _, op := range editScript(as, bs) {
+		switch op {
+		case ' ':
+			lines = append(lines, line{' ', as[i], i, j})
+			i++
+			j++
+		case '-':
+			lines = append(lines, line{'-', as[i], i, j})
+			i++
+		default:
+			lines = append(lines, line{'+', bs[j], i, j})
+			j++
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %v\n+++ %v\n", name, name)
+	for start := 0; start < len(lines); {
+		if lines[start].op == ' ' {
+			start++
+			continue
+		}
+
+		// a hunk ends after diffContext unchanged lines following the
+		// last change, unless another change is within twice that
+		from := start - diffContext
+		if from < 0 {
+			from = 0
+		}
+		end := start
+		for end < len(lines) {
+			if lines[end].op != ' ' {
+				end++
+				continue
+			}
+			next := end
+			for next < len(lines) && lines[next].op == ' ' {
+				next++
+			}
+			if next == len(lines) || next-end > 2*diffContext {
+				break
+			}
+			end = next
+		}
+		to := end + diffContext
+		if to > len(lines) {
+			to = len(lines)
+		}
+
+		aLines, bLines := 0, 0
+		for _, l := range lines[from:to] {
+			if l.op != '+' {
+				aLines++
+			}
+			if l.op != '-' {
+				bLines++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%v,%v +%v,%v @@\n", lines[from].a+1, aLines, lines[from].b+1, bLines)
+		for _, l := range lines[from:to] {
+			fmt.Fprintf(&out, "%c%v\n", l.op, l.text)
+		}
+		start = to
+	}
+	return out.String()
+}
+
+// editScript returns the shortest edit from as to bs, as one operation per
+// line: ' ' keeps a line of both, '-' removes a line of as and '+' adds a
+// line of bs. The removed lines of a change come before the added ones.
+func editScript(as, bs []string) []byte {
+	ops := diffOps(nil, as, bs)
+	for start := 0; start < len(ops); start++ {
+		end := start
+		for end < len(ops) && ops[end] != ' ' {
+			end++
+		}
+		sort.Slice(ops[start:end], func(i, j int) bool {
+			return ops[start+i] == '-' && ops[start+j] == '+'
+		})
+		start = end
+	}
+	return ops
+}
+
+// diffOps appends the edit from as to bs to ops. It uses the linear space
+// variant of Myers' algorithm, which splits the edit at the middle snake,
+// a run of equal lines that the shortest edits pass through.
+func diffOps(ops []byte, as, bs []string) []byte {
+	// the common prefix and suffix are kept
+	prefix := 0
+	for prefix < len(as) && prefix < len(bs) && as[prefix] == bs[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(as)-prefix && suffix < len(bs)-prefix && as[len(as)-1-suffix] == bs[len(bs)-1-suffix] {
+		suffix++
+	}
+	ops = appendOps(ops, ' ', prefix)
+	as, bs = as[prefix:len(as)-suffix], bs[prefix:len(bs)-suffix]
+
+	switch {
+	case len(as) == 0:
+		ops = appendOps(ops, '+', len(bs))
+	case len(bs) == 0:
+		ops = appendOps(ops, '-', len(as))
+	default:
+		x, y, u, v := middleSnake(as, bs)
+		ops = diffOps(ops, as[:x], bs[:y])
+		ops = appendOps(ops, ' ', u-x)
+		ops = diffOps(ops, as[u:], bs[v:])
+	}
+	return appendOps(ops, ' ', suffix)
+}
+
+func appendOps(ops []byte, op byte, n int) []byte {
+	for ; n > 0; n-- {
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+// middleSnake returns the start x, y and the end u, v of the middle snake
+// of the edits from as to bs, which differ in their first and last lines.
+// It follows the furthest paths of d edits from the start and from the end
+// until they overlap.
+func middleSnake(as, bs []string) (x, y, u, v int) {
+	n, m := len(as), len(bs)
+	delta := n - m
+	max := (n + m + 1) / 2
+	offset := max + 1
+
+	// forward[k] is the furthest x on the diagonal x - y = k from the start,
+	// and backward[k] the furthest from the end, counted from the end
+	forward := make([]int, 2*max+3)
+	backward := make([]int, 2*max+3)
+	for d := 0; d <= max; d++ {
+		for k := -d; k <= d; k += 2 {
+			x := forward[offset+k-1] + 1
+			if k == -d || k != d && forward[offset+k-1] < forward[offset+k+1] {
+				x = forward[offset+k+1]
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && as[x] == bs[y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+			if back := delta - k; delta%2 != 0 && back >= -(d-1) && back <= d-1 && x+backward[offset+back] >= n {
+				return startX, startY, x, y
+			}
+		}
+		for k := -d; k <= d; k += 2 {
+			x := backward[offset+k-1] + 1
+			if k == -d || k != d && backward[offset+k-1] < backward[offset+k+1] {
+				x = backward[offset+k+1]
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && as[n-1-x] == bs[m-1-y] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+			if front := delta - k; delta%2 == 0 && front >= -d && front <= d && x+forward[offset+front] >= n {
+				return n - x, m - y, n - startX, m - startY
+			}
+		}
+	}
+	panic("no middle snake")
+}
+
+// splitLines returns the lines of s without their line breaks.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,851 @@
+package generator
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// PlatformBrowser bundles run in a browser. Node.js core modules are
+	// replaced with shims or empty modules.
+	PlatformBrowser = "browser"
+
+	// PlatformNode bundles run in Node.js. Core modules are loaded with the
+	// native require.
+	PlatformNode = "node"
+)
+
+// builtinPathPrefix starts the module key of a Node.js core module.
+const builtinPathPrefix = "<builtin>/"
+
+// nodeBuiltins are the core modules of Node.js.
+var nodeBuiltins = []string{
+	"assert", "assert/strict", "async_hooks", "buffer", "child_process",
+	"cluster", "console", "constants", "crypto", "dgram", "diagnostics_channel",
+	"dns", "dns/promises", "domain", "events", "fs", "fs/promises", "http",
+	"http2", "https", "inspector", "module", "net", "os", "path", "path/posix",
+	"path/win32", "perf_hooks", "process", "punycode", "querystring",
+	"readline", "repl", "stream", "stream/promises", "stream/web",
+	"string_decoder", "sys", "timers", "timers/promises", "tls", "trace_events",
+	"tty", "url", "util", "util/types", "v8", "vm", "wasi", "worker_threads",
+	"zlib",
+}
+
+// builtinShims are the browser implementations of Node.js core modules.
+var builtinShims = map[string]string{
+	"assert":     assertShim,
+	"buffer":     bufferShim,
+	"events":     eventsShim,
+	"path":       pathShim,
+	"path/posix": pathShim,
+	"process":    "module.exports = process;\n",
+	"util":       utilShim,
+	"sys":        utilShim,
+}
+
+func isNodeBuiltin(name string) bool {
+	return containsString(nodeBuiltins, name)
+}
+
+// preferBuiltin reports whether the core module is used even if a package
+// with the same name is installed.
+func (bundle *_bundle) preferBuiltin(importValue, name string) bool {
+	if bundle.config.Platform == PlatformNode || strings.HasPrefix(importValue, "node:") {
+		return true
+	}
+	_, ok := bundle.config.Resolve.Builtins[name]
+	return ok
+}
+
+// resolveBuiltin returns the module used for the core module name.
+func (bundle *_bundle) resolveBuiltin(name, currentPath string, kind importKind) (string, error) {
+	if bundle.config.Platform == PlatformNode {
+		return builtinPathPrefix + name, nil
+	}
+
+	if target, ok := bundle.config.Resolve.Builtins[name]; ok {
+		if target == "" {
+			return emptyModulePath, nil
+		}
+		if strings.HasPrefix(target, ".") || filepath.IsAbs(target) {
+			return bundle.resolvePath(target, "./", kind)
+		}
+		resolved, ok, err := bundle.resolveNodeModules(target, currentPath, kind)
+		if err == nil && !ok {
+			err = fmt.Errorf("Cannot find module: %v", target)
+		}
+		return resolved, err
+	}
+
+	if _, ok := builtinShims[name]; ok {
+		return builtinPathPrefix + name, nil
+	}
+	return emptyModulePath, nil
+}
+
+// builtinSource returns the source of the module used for a core module.
+func (bundle *_bundle) builtinSource(name string) []byte {
+	if bundle.config.Platform == PlatformNode {
+		return []byte(fmt.Sprintf("module.exports = __go_bundle_node_require__('%v');\n", name))
+	}
+	if name == "process" {
+		bundle.usesProcess = true
+	}
+	return []byte(builtinShims[name])
+}
+
+const processJS = `
+var process = {
+  env: {},
+  browser: true,
+  title: 'browser',
+  version: '',
+  versions: {},
+  platform: 'browser',
+  argv: [],
+  cwd: function () { return '/'; },
+  nextTick: function (fn) {
+    var args = Array.prototype.slice.call(arguments, 1);
+    var run = function () { fn.apply(null, args); };
+    if (typeof Promise !== 'undefined') {
+      Promise.resolve().then(run);
+    } else {
+      setTimeout(run, 0);
+    }
+  }
+};
+`
+
+const pathShim = `
+function normalizeArray(parts, allowAboveRoot) {
+  var res = [];
+  for (var i = 0; i < parts.length; i++) {
+    var p = parts[i];
+    if (!p || p === '.') {
+      continue;
+    }
+    if (p === '..') {
+      if (res.length && res[res.length - 1] !== '..') {
+        res.pop();
+      } else if (allowAboveRoot) {
+        res.push('..');
+      }
+    } else {
+      res.push(p);
+    }
+  }
+  return res;
+}
+
+function trimTrailingSlash(p) {
+  return p.length > 1 ? p.replace(/\/+$/, '') : p;
+}
+
+exports.sep = '/';
+exports.delimiter = ':';
+
+exports.isAbsolute = function (p) {
+  return p.charAt(0) === '/';
+};
+
+exports.normalize = function (p) {
+  var isAbsolute = exports.isAbsolute(p);
+  var trailingSlash = p.length > 0 && p.charAt(p.length - 1) === '/';
+  p = normalizeArray(p.split('/'), !isAbsolute).join('/');
+  if (!p && !isAbsolute) {
+    p = '.';
+  }
+  if (p && trailingSlash) {
+    p += '/';
+  }
+  return (isAbsolute ? '/' : '') + p;
+};
+
+exports.join = function () {
+  var paths = Array.prototype.filter.call(arguments, function (p) {
+    return typeof p === 'string' && p;
+  });
+  return exports.normalize(paths.join('/'));
+};
+
+exports.resolve = function () {
+  var resolved = '';
+  var isAbsolute = false;
+  for (var i = arguments.length - 1; i >= -1 && !isAbsolute; i--) {
+    var p = i >= 0 ? arguments[i] : '/';
+    if (typeof p !== 'string' || !p) {
+      continue;
+    }
+    resolved = p + '/' + resolved;
+    isAbsolute = p.charAt(0) === '/';
+  }
+  resolved = normalizeArray(resolved.split('/'), !isAbsolute).join('/');
+  return ((isAbsolute ? '/' : '') + resolved) || '.';
+};
+
+exports.relative = function (from, to) {
+  var fromParts = exports.resolve(from).split('/').filter(Boolean);
+  var toParts = exports.resolve(to).split('/').filter(Boolean);
+  var i = 0;
+  while (i < fromParts.length && i < toParts.length && fromParts[i] === toParts[i]) {
+    i++;
+  }
+  var up = [];
+  for (var j = i; j < fromParts.length; j++) {
+    up.push('..');
+  }
+  return up.concat(toParts.slice(i)).join('/');
+};
+
+exports.dirname = function (p) {
+  p = trimTrailingSlash(p);
+  var i = p.lastIndexOf('/');
+  if (i === -1) {
+    return '.';
+  }
+  if (i === 0) {
+    return '/';
+  }
+  return p.slice(0, i);
+};
+
+exports.basename = function (p, ext) {
+  p = trimTrailingSlash(p);
+  var base = p.slice(p.lastIndexOf('/') + 1);
+  if (ext && base !== ext && base.slice(-ext.length) === ext) {
+    base = base.slice(0, -ext.length);
+  }
+  return base;
+};
+
+exports.extname = function (p) {
+  var base = exports.basename(p);
+  var i = base.lastIndexOf('.');
+  return i <= 0 ? '' : base.slice(i);
+};
+
+exports.posix = exports;
+`
+
+const eventsShim = `
+function EventEmitter() {
+  this._events = this._events || {};
+  this._maxListeners = this._maxListeners || undefined;
+}
+
+EventEmitter.EventEmitter = EventEmitter;
+EventEmitter.defaultMaxListeners = 10;
+
+function listenersOf(emitter, type) {
+  if (!emitter._events) {
+    emitter._events = {};
+  }
+  if (!emitter._events[type]) {
+    emitter._events[type] = [];
+  }
+  return emitter._events[type];
+}
+
+EventEmitter.prototype.setMaxListeners = function (n) {
+  this._maxListeners = n;
+  return this;
+};
+
+EventEmitter.prototype.getMaxListeners = function () {
+  return this._maxListeners === undefined ? EventEmitter.defaultMaxListeners : this._maxListeners;
+};
+
+EventEmitter.prototype.on = EventEmitter.prototype.addListener = function (type, listener) {
+  if (this._events && this._events.newListener) {
+    this.emit('newListener', type, listener.listener || listener);
+  }
+  listenersOf(this, type).push(listener);
+  return this;
+};
+
+EventEmitter.prototype.prependListener = function (type, listener) {
+  listenersOf(this, type).unshift(listener);
+  return this;
+};
+
+EventEmitter.prototype.once = function (type, listener) {
+  var self = this;
+  function wrapper() {
+    self.removeListener(type, wrapper);
+    return listener.apply(this, arguments);
+  }
+  wrapper.listener = listener;
+  return this.on(type, wrapper);
+};
+
+EventEmitter.prototype.off = EventEmitter.prototype.removeListener = function (type, listener) {
+  var list = listenersOf(this, type);
+  for (var i = list.length - 1; i >= 0; i--) {
+    if (list[i] === listener || list[i].listener === listener) {
+      list.splice(i, 1);
+      break;
+    }
+  }
+  return this;
+};
+
+EventEmitter.prototype.removeAllListeners = function (type) {
+  if (arguments.length === 0) {
+    this._events = {};
+  } else if (this._events) {
+    delete this._events[type];
+  }
+  return this;
+};
+
+EventEmitter.prototype.emit = function (type) {
+  var list = this._events && this._events[type];
+  if (!list || !list.length) {
+    if (type === 'error') {
+      var err = arguments[1];
+      throw err instanceof Error ? err : new Error('Unhandled error: ' + err);
+    }
+    return false;
+  }
+  var args = Array.prototype.slice.call(arguments, 1);
+  list = list.slice();
+  for (var i = 0; i < list.length; i++) {
+    list[i].apply(this, args);
+  }
+  return true;
+};
+
+EventEmitter.prototype.listeners = function (type) {
+  return (this._events && this._events[type] || []).map(function (l) {
+    return l.listener || l;
+  });
+};
+
+EventEmitter.prototype.listenerCount = function (type) {
+  return this._events && this._events[type] ? this._events[type].length : 0;
+};
+
+EventEmitter.listenerCount = function (emitter, type) {
+  return emitter.listenerCount(type);
+};
+
+EventEmitter.prototype.eventNames = function () {
+  var events = this._events || {};
+  return Object.keys(events).filter(function (type) {
+    return events[type].length > 0;
+  });
+};
+
+module.exports = EventEmitter;
+`
+
+const utilShim = `
+var formatRegExp = /%[sdifjo%]/g;
+
+exports.inspect = function (value) {
+  if (typeof value === 'string') {
+    return "'" + value + "'";
+  }
+  if (typeof value === 'function') {
+    return '[Function' + (value.name ? ': ' + value.name : '') + ']';
+  }
+  if (value instanceof Error) {
+    return value.stack || String(value);
+  }
+  try {
+    var json = JSON.stringify(value);
+    return json === undefined ? String(value) : json;
+  } catch (e) {
+    return '[Circular]';
+  }
+};
+
+exports.format = function (f) {
+  var args = arguments;
+  if (typeof f !== 'string') {
+    return Array.prototype.map.call(args, function (arg) {
+      return exports.inspect(arg);
+    }).join(' ');
+  }
+  var i = 1;
+  var str = f.replace(formatRegExp, function (x) {
+    if (x === '%%') {
+      return '%';
+    }
+    if (i >= args.length) {
+      return x;
+    }
+    var arg = args[i++];
+    switch (x) {
+    case '%s':
+      return String(arg);
+    case '%d':
+      return String(Number(arg));
+    case '%i':
+      return String(parseInt(arg, 10));
+    case '%f':
+      return String(parseFloat(arg));
+    default:
+      try {
+        return JSON.stringify(arg);
+      } catch (e) {
+        return '[Circular]';
+      }
+    }
+  });
+  for (; i < args.length; i++) {
+    var arg = args[i];
+    str += ' ' + (typeof arg === 'object' && arg !== null ? exports.inspect(arg) : String(arg));
+  }
+  return str;
+};
+
+exports.inherits = function (ctor, superCtor) {
+  ctor.super_ = superCtor;
+  ctor.prototype = Object.create(superCtor.prototype, {
+    constructor: { value: ctor, enumerable: false, writable: true, configurable: true }
+  });
+};
+
+exports.deprecate = function (fn, msg) {
+  var warned = false;
+  return function () {
+    if (!warned) {
+      warned = true;
+      if (typeof console !== 'undefined') {
+        console.warn(msg);
+      }
+    }
+    return fn.apply(this, arguments);
+  };
+};
+
+exports.promisify = function (fn) {
+  return function () {
+    var self = this;
+    var args = Array.prototype.slice.call(arguments);
+    return new Promise(function (resolve, reject) {
+      args.push(function (err, value) {
+        if (err) {
+          reject(err);
+        } else {
+          resolve(value);
+        }
+      });
+      fn.apply(self, args);
+    });
+  };
+};
+
+exports.isArray = Array.isArray;
+exports.isBoolean = function (v) { return typeof v === 'boolean'; };
+exports.isNull = function (v) { return v === null; };
+exports.isNullOrUndefined = function (v) { return v == null; };
+exports.isNumber = function (v) { return typeof v === 'number'; };
+exports.isString = function (v) { return typeof v === 'string'; };
+exports.isUndefined = function (v) { return v === undefined; };
+exports.isObject = function (v) { return typeof v === 'object' && v !== null; };
+exports.isFunction = function (v) { return typeof v === 'function'; };
+exports.isRegExp = function (v) { return v instanceof RegExp; };
+exports.isDate = function (v) { return v instanceof Date; };
+exports.isError = function (v) { return v instanceof Error; };
+exports.isPrimitive = function (v) {
+  return v === null || (typeof v !== 'object' && typeof v !== 'function');
+};
+`
+
+const bufferShim = `
+var hexChars = '0123456789abcdef';
+var base64Chars = 'ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/';
+
+function Buffer(value, encoding) {
+  return Buffer.from(value, encoding);
+}
+
+Buffer.prototype = Object.create(Uint8Array.prototype);
+Buffer.prototype.constructor = Buffer;
+
+function createBuffer(length) {
+  var buf = new Uint8Array(length);
+  Object.setPrototypeOf(buf, Buffer.prototype);
+  return buf;
+}
+
+function fromBytes(bytes) {
+  var buf = createBuffer(bytes.length);
+  for (var i = 0; i < bytes.length; i++) {
+    buf[i] = bytes[i] & 255;
+  }
+  return buf;
+}
+
+function utf8Encode(str) {
+  var out = [];
+  for (var i = 0; i < str.length; i++) {
+    var c = str.charCodeAt(i);
+    if (c >= 0xd800 && c < 0xdc00 && i + 1 < str.length) {
+      var next = str.charCodeAt(i + 1);
+      if (next >= 0xdc00 && next < 0xe000) {
+        c = 0x10000 + ((c - 0xd800) << 10) + (next - 0xdc00);
+        i++;
+      }
+    }
+    if (c < 0x80) {
+      out.push(c);
+    } else if (c < 0x800) {
+      out.push(0xc0 | c >> 6, 0x80 | c & 63);
+    } else if (c < 0x10000) {
+      out.push(0xe0 | c >> 12, 0x80 | c >> 6 & 63, 0x80 | c & 63);
+    } else {
+      out.push(0xf0 | c >> 18, 0x80 | c >> 12 & 63, 0x80 | c >> 6 & 63, 0x80 | c & 63);
+    }
+  }
+  return out;
+}
+
+function utf8Decode(bytes) {
+  var str = '';
+  for (var i = 0; i < bytes.length;) {
+    var c = bytes[i++];
+    if (c >= 0xf0) {
+      c = (c & 7) << 18 | (bytes[i++] & 63) << 12 | (bytes[i++] & 63) << 6 | bytes[i++] & 63;
+    } else if (c >= 0xe0) {
+      c = (c & 15) << 12 | (bytes[i++] & 63) << 6 | bytes[i++] & 63;
+    } else if (c >= 0xc0) {
+      c = (c & 31) << 6 | bytes[i++] & 63;
+    }
+    if (c >= 0x10000) {
+      c -= 0x10000;
+      str += String.fromCharCode(0xd800 + (c >> 10), 0xdc00 + (c & 1023));
+    } else {
+      str += String.fromCharCode(c);
+    }
+  }
+  return str;
+}
+
+function base64Decode(str) {
+  str = str.replace(/-/g, '+').replace(/_/g, '/').replace(/[^A-Za-z0-9+\/]/g, '');
+  var out = [];
+  var bits = 0;
+  var value = 0;
+  for (var i = 0; i < str.length; i++) {
+    value = (value << 6 | base64Chars.indexOf(str.charAt(i))) & 0xffffff;
+    bits += 6;
+    if (bits >= 8) {
+      bits -= 8;
+      out.push(value >> bits & 255);
+    }
+  }
+  return out;
+}
+
+function base64Encode(bytes) {
+  var out = '';
+  for (var i = 0; i < bytes.length; i += 3) {
+    var n = bytes[i] << 16 | (bytes[i + 1] || 0) << 8 | (bytes[i + 2] || 0);
+    out += base64Chars.charAt(n >> 18 & 63) + base64Chars.charAt(n >> 12 & 63) +
+      (i + 1 < bytes.length ? base64Chars.charAt(n >> 6 & 63) : '=') +
+      (i + 2 < bytes.length ? base64Chars.charAt(n & 63) : '=');
+  }
+  return out;
+}
+
+function encode(str, encoding) {
+  var out = [];
+  var i;
+  switch (String(encoding || 'utf8').toLowerCase()) {
+  case 'hex':
+    for (i = 0; i + 1 < str.length; i += 2) {
+      out.push(parseInt(str.substr(i, 2), 16));
+    }
+    return out;
+  case 'base64':
+  case 'base64url':
+    return base64Decode(str);
+  case 'ascii':
+  case 'latin1':
+  case 'binary':
+    for (i = 0; i < str.length; i++) {
+      out.push(str.charCodeAt(i) & 255);
+    }
+    return out;
+  default:
+    return utf8Encode(str);
+  }
+}
+
+Buffer.isBuffer = function (value) {
+  return value instanceof Buffer;
+};
+
+Buffer.from = function (value, encoding) {
+  if (typeof value === 'string') {
+    return fromBytes(encode(value, encoding));
+  }
+  if (value instanceof ArrayBuffer) {
+    return fromBytes(new Uint8Array(value));
+  }
+  if (value && value.type === 'Buffer' && Array.isArray(value.data)) {
+    return fromBytes(value.data);
+  }
+  if (value && typeof value.length === 'number') {
+    return fromBytes(value);
+  }
+  throw new TypeError('Unsupported argument to Buffer.from');
+};
+
+Buffer.alloc = function (size, fill) {
+  var buf = createBuffer(size);
+  if (fill !== undefined) {
+    buf.fill(typeof fill === 'string' ? fill.charCodeAt(0) : fill);
+  }
+  return buf;
+};
+
+Buffer.allocUnsafe = function (size) {
+  return createBuffer(size);
+};
+
+Buffer.byteLength = function (value, encoding) {
+  return typeof value === 'string' ? encode(value, encoding).length : value.length;
+};
+
+Buffer.concat = function (list, totalLength) {
+  var i;
+  if (totalLength === undefined) {
+    totalLength = 0;
+    for (i = 0; i < list.length; i++) {
+      totalLength += list[i].length;
+    }
+  }
+  var buf = createBuffer(totalLength);
+  var offset = 0;
+  for (i = 0; i < list.length && offset < totalLength; i++) {
+    var part = list[i].length <= totalLength - offset ? list[i] : list[i].subarray(0, totalLength - offset);
+    buf.set(part, offset);
+    offset += part.length;
+  }
+  return buf;
+};
+
+Buffer.prototype.toString = function (encoding, start, end) {
+  var bytes = this.subarray(start || 0, end === undefined ? this.length : end);
+  var out = '';
+  var i;
+  switch (String(encoding || 'utf8').toLowerCase()) {
+  case 'hex':
+    for (i = 0; i < bytes.length; i++) {
+      out += hexChars.charAt(bytes[i] >> 4) + hexChars.charAt(bytes[i] & 15);
+    }
+    return out;
+  case 'base64':
+    return base64Encode(bytes);
+  case 'ascii':
+  case 'latin1':
+  case 'binary':
+    for (i = 0; i < bytes.length; i++) {
+      out += String.fromCharCode(bytes[i]);
+    }
+    return out;
+  default:
+    return utf8Decode(bytes);
+  }
+};
+
+Buffer.prototype.slice = function (start, end) {
+  var buf = this.subarray(start, end);
+  Object.setPrototypeOf(buf, Buffer.prototype);
+  return buf;
+};
+
+Buffer.prototype.equals = function (other) {
+  if (this.length !== other.length) {
+    return false;
+  }
+  for (var i = 0; i < this.length; i++) {
+    if (this[i] !== other[i]) {
+      return false;
+    }
+  }
+  return true;
+};
+
+Buffer.prototype.toJSON = function () {
+  return { type: 'Buffer', data: Array.prototype.slice.call(this) };
+};
+
+exports.Buffer = Buffer;
+exports.kMaxLength = 0x7fffffff;
+`
+
+const assertShim = `
+function stringify(value) {
+  try {
+    return JSON.stringify(value);
+  } catch (e) {
+    return String(value);
+  }
+}
+
+function AssertionError(options) {
+  this.name = 'AssertionError';
+  this.actual = options.actual;
+  this.expected = options.expected;
+  this.operator = options.operator;
+  this.message = options.message ||
+    stringify(options.actual) + ' ' + options.operator + ' ' + stringify(options.expected);
+  if (Error.captureStackTrace) {
+    Error.captureStackTrace(this, options.stackStartFn);
+  } else {
+    this.stack = new Error(this.message).stack;
+  }
+}
+
+AssertionError.prototype = Object.create(Error.prototype);
+AssertionError.prototype.constructor = AssertionError;
+
+function fail(actual, expected, message, operator, stackStartFn) {
+  if (message instanceof Error) {
+    throw message;
+  }
+  throw new AssertionError({
+    actual: actual,
+    expected: expected,
+    message: message,
+    operator: operator,
+    stackStartFn: stackStartFn
+  });
+}
+
+function deepEqual(a, b, strict) {
+  if (strict ? a === b : a == b) {
+    return true;
+  }
+  if (a instanceof Date && b instanceof Date) {
+    return a.getTime() === b.getTime();
+  }
+  if (a instanceof RegExp && b instanceof RegExp) {
+    return String(a) === String(b);
+  }
+  if (typeof a !== 'object' || typeof b !== 'object' || a === null || b === null) {
+    return strict ? a !== a && b !== b : a == b;
+  }
+  if (strict && Object.getPrototypeOf(a) !== Object.getPrototypeOf(b)) {
+    return false;
+  }
+  var keysA = Object.keys(a);
+  var keysB = Object.keys(b);
+  if (keysA.length !== keysB.length) {
+    return false;
+  }
+  for (var i = 0; i < keysA.length; i++) {
+    var key = keysA[i];
+    if (!Object.prototype.hasOwnProperty.call(b, key) || !deepEqual(a[key], b[key], strict)) {
+      return false;
+    }
+  }
+  return true;
+}
+
+function ok(value, message) {
+  if (!value) {
+    fail(value, true, message, '==', ok);
+  }
+}
+
+var assert = module.exports = ok;
+assert.ok = ok;
+assert.AssertionError = AssertionError;
+
+assert.fail = function (message) {
+  fail(undefined, undefined, message || 'Failed', 'fail', assert.fail);
+};
+
+assert.equal = function (actual, expected, message) {
+  if (actual != expected) {
+    fail(actual, expected, message, '==', assert.equal);
+  }
+};
+
+assert.notEqual = function (actual, expected, message) {
+  if (actual == expected) {
+    fail(actual, expected, message, '!=', assert.notEqual);
+  }
+};
+
+assert.strictEqual = function (actual, expected, message) {
+  if (actual !== expected) {
+    fail(actual, expected, message, '===', assert.strictEqual);
+  }
+};
+
+assert.notStrictEqual = function (actual, expected, message) {
+  if (actual === expected) {
+    fail(actual, expected, message, '!==', assert.notStrictEqual);
+  }
+};
+
+assert.deepEqual = function (actual, expected, message) {
+  if (!deepEqual(actual, expected, false)) {
+    fail(actual, expected, message, 'deepEqual', assert.deepEqual);
+  }
+};
+
+assert.notDeepEqual = function (actual, expected, message) {
+  if (deepEqual(actual, expected, false)) {
+    fail(actual, expected, message, 'notDeepEqual', assert.notDeepEqual);
+  }
+};
+
+assert.deepStrictEqual = function (actual, expected, message) {
+  if (!deepEqual(actual, expected, true)) {
+    fail(actual, expected, message, 'deepStrictEqual', assert.deepStrictEqual);
+  }
+};
+
+assert.notDeepStrictEqual = function (actual, expected, message) {
+  if (deepEqual(actual, expected, true)) {
+    fail(actual, expected, message, 'notDeepStrictEqual', assert.notDeepStrictEqual);
+  }
+};
+
+assert.throws = function (block, expected, message) {
+  var threw = false;
+  var error;
+  if (typeof expected === 'string') {
+    message = expected;
+    expected = undefined;
+  }
+  try {
+    block();
+  } catch (e) {
+    threw = true;
+    error = e;
+  }
+  if (!threw) {
+    fail(undefined, expected, 'Missing expected exception' + (message ? ': ' + message : '.'), 'throws', assert.throws);
+  }
+  if (expected instanceof RegExp && !expected.test(String(error))) {
+    throw error;
+  }
+  if (typeof expected === 'function' && expected.prototype !== undefined && !(error instanceof expected)) {
+    throw error;
+  }
+};
+
+assert.doesNotThrow = function (block, message) {
+  try {
+    block();
+  } catch (e) {
+    fail(e, undefined, 'Got unwanted exception' + (message ? ': ' + message : '.'), 'doesNotThrow', assert.doesNotThrow);
+  }
+};
+
+assert.ifError = function (err) {
+  if (err) {
+    throw err;
+  }
+};
+`
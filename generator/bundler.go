@@ -5,9 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/fs"
 	"io/ioutil"
-	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/walesey/go-bundle/parser"
@@ -16,8 +18,14 @@ import (
 const globalJS = `
 var require;
 var global = {};
-var process = {};
-process.env = {};
+var __go_bundle_modules__ = {};
+var __go_bundle_module_cache__ = {};
+`
+
+// nodeGlobalJS replaces globalJS for the node platform, where global and
+// process already exist and core modules are loaded with the native require.
+const nodeGlobalJS = `
+var __go_bundle_node_require__ = require;
 var __go_bundle_modules__ = {};
 var __go_bundle_module_cache__ = {};
 `
@@ -33,132 +41,361 @@ require = function (module) {
 };
 `
 
-type Loader interface {
-	Load(in io.Reader) (io.Reader, error)
+// Config holds the options used to create a bundle.
+type Config struct {
+	// Rules select the loaders and module type of each file. The first
+	// matching rule is used.
+	Rules []LoaderRule
+
+	// Loaders maps file extensions to the loaders that transform them. They
+	// are used for files that do not match any of the Rules.
+	Loaders map[string][]ModuleLoader
+
+	// Plugins extend resolving, loading and generating modules.
+	Plugins []Plugin
+
+	// FS is the file system modules are loaded from, instead of the
+	// operating system's. Its root is the working directory, so paths in the
+	// bundle are relative to it. Virtual modules can be used to overlay
+	// in-memory files.
+	FS fs.FS
+
+	// SourceMap selects whether a source map of the bundle is generated, and
+	// how it is output.
+	SourceMap SourceMapMode
+
+	// Comments selects the comments of the modules that are kept in the
+	// bundle.
+	Comments CommentMode
+
+	// Minify writes the bundle in the compact form of the generator.
+	Minify bool
+
+	// Mangle renames the local variables of the modules to short names.
+	Mangle bool
+
+	// MangleProperties renames the properties matching the pattern in all
+	// modules. See Options.MangleProperties. The modules are loaded twice,
+	// first to collect the names of the properties that are kept.
+	MangleProperties *regexp.Regexp
+
+	// Define replaces global names in all modules. See Options.Define.
+	Define map[string]string
+
+	// DeadCode removes code that is never run from the modules, including
+	// their unused top level functions and variables. See Options.DeadCode.
+	DeadCode bool
+
+	// DropDebugging removes console.* calls and debugger statements.
+	DropDebugging bool
+
+	// Print controls the layout of the bundle. See PrintOptions.
+	Print PrintOptions
+
+	// Target is the version of JavaScript the modules are written in. The
+	// default, TargetES5, lowers all newer syntax. See Target.
+	Target Target
+
+	// VerifyTarget reparses the bundle and fails the build if it has syntax
+	// newer than Target, eg. from assets or loaders that bypass the
+	// generator. The error lists each construct with the module and
+	// position it came from.
+	VerifyTarget bool
+
+	// Polyfill adds the built-ins the modules use that are newer than
+	// Target, eg. Promise or Array.from, to browser bundles. Each polyfill
+	// is added once, and only if the engine lacks the built-in. Methods,
+	// eg. includes, are added to every prototype that lacks them, as the
+	// type of the object they are called on is unknown.
+	Polyfill bool
+
+	// Virtual maps import specifiers to modules that do not exist on disk.
+	// Bare names, eg. "virtual:routes", are matched exactly. Relative or
+	// absolute paths add a file at that path, relative to the working
+	// directory, that is resolved and loaded like any other file.
+	Virtual map[string]VirtualModule
+
+	// Platform is the environment the bundle runs in, PlatformBrowser or
+	// PlatformNode. The default is PlatformBrowser.
+	Platform string
+
+	Resolve ResolveConfig
+}
+
+// generatorOptions returns the options the modules are generated with.
+func (c Config) generatorOptions() Options {
+	return Options{
+		Comments:         c.Comments,
+		Minify:           c.Minify,
+		Mangle:           c.Mangle,
+		MangleProperties: c.MangleProperties,
+		Define:           c.Define,
+		DeadCode:         c.DeadCode,
+		DropDebugging:    c.DropDebugging,
+		Print:            c.Print,
+		Target:           c.Target,
+	}
+}
+
+func (c Config) withDefaults() Config {
+	if c.Platform == "" {
+		c.Platform = PlatformBrowser
+	}
+	if c.Platform == PlatformNode {
+		if c.Resolve.Conditions == nil {
+			c.Resolve.Conditions = []string{"node"}
+		}
+		if c.Resolve.MainFields == nil {
+			c.Resolve.MainFields = []string{"module", "main"}
+		}
+	}
+	c.Resolve = c.Resolve.withDefaults()
+	return c
 }
 
 type module struct {
 	name string
 	data []byte
+
+	// source is the original source of a javascript module, and mappings
+	// map data to it, if source maps are enabled
+	source   string
+	mappings []mapping
+
+	// inputMap maps source to the files it was compiled from
+	inputMap *inputSourceMap
+}
+
+// Result is the output of Build.
+type Result struct {
+	// Name is the file name of the entry module without its extension.
+	Name string
+
+	// Code is the javascript bundle.
+	Code []byte
+
+	// Assets are the files emitted by loaders, in the order they were first
+	// emitted.
+	Assets []Asset
+
+	// SourceMap is the source map of Code, if Config.SourceMap is
+	// SourceMapExternal.
+	SourceMap []byte
+
+	// Dependencies are the sorted absolute paths of the files the bundle
+	// was built from.
+	Dependencies []string
+}
+
+// Asset is a file emitted by a loader. Name is a slash separated path
+// relative to the output directory.
+type Asset struct {
+	Name string
+	Data []byte
 }
 
 type _bundle struct {
-	modules map[string]*module
-	loaders map[string][]Loader
+	config       Config
+	fs           fileSystem
+	rules        []LoaderRule
+	modules      map[string]*module
+	packages     map[string]*packageJSON
+	tsconfigs    map[string]*tsconfig
+	assets       []Asset
+	virtual      map[string]*VirtualModule
+	dependencies map[string]bool
 
 	moduleCounter int
+
+	// usesProcess is set when a module refers to the process global
+	usesProcess bool
+
+	// jsonModules holds the names of the modules loaded from JSON files
+	jsonModules map[string]bool
+
+	// properties holds the mangled property names, it is nil if no
+	// properties are mangled
+	properties *propertyNames
+
+	// polyfills holds the names of the built-ins that are polyfilled, it
+	// is nil if polyfills are not added
+	polyfills map[string]bool
 }
 
 // Bundle takes entry and loaders to load js into a single javascript bundle
 func Bundle(entry string, loaders map[string][]Loader) (io.Reader, error) {
-	bundle := newBundle()
-	bundle.loaders = loaders
+	return BundleWithConfig(entry, Config{Loaders: AdaptLoaders(loaders)})
+}
 
-	entryModule, err := bundle.resolveModule(fmt.Sprint("./", entry), "./")
+// BundleWithConfig bundles entry and all of its dependencies into a single
+// javascript bundle using the given configuration. Assets emitted by loaders
+// are written to their names relative to the working directory.
+func BundleWithConfig(entry string, config Config) (io.Reader, error) {
+	result, err := Build(entry, config)
 	if err != nil {
 		return nil, err
 	}
 
-	// write the bundle file
-	out := new(bytes.Buffer)
-	out.Write([]byte(globalJS))
-	for path, mod := range bundle.modules {
-		out.Write([]byte(fmt.Sprint("\n// ", path)))
-		out.Write([]byte(fmt.Sprintf("\n__go_bundle_modules__.%v = function() {\n", mod.name)))
-		out.Write([]byte("var exports = {};\n"))
-		out.Write([]byte("var module = { exports: exports };\n"))
-		out.Write(mod.data)
-		out.Write([]byte("\nreturn module.exports;\n"))
-		out.Write([]byte("};\n\n"))
+	if err := result.WriteAssets("."); err != nil {
+		return nil, err
 	}
-	out.Write([]byte(requireJS))
-	out.Write([]byte(fmt.Sprintf("require('%v');", entryModule)))
-
-	return out, err
+	return bytes.NewReader(result.Code), nil
 }
 
-func (bundle *_bundle) resolveModule(importValue, currentPath string) (string, error) {
-	//use relative path
-	if strings.HasPrefix(importValue, ".") {
-		path := filepath.Join(filepath.Dir(currentPath), importValue)
-		return bundle.loadModule(path)
+// WriteAssets writes the assets of the result to their names relative to
+// dir.
+func (r *Result) WriteAssets(dir string) error {
+	for _, asset := range r.Assets {
+		path, err := outputPath(dir, asset.Name)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(path, asset.Data); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	//look in node_modules
-	searchPath, err := filepath.Abs(currentPath)
+// Build bundles entry and all of its dependencies, returning the bundle
+// together with the assets emitted by loaders.
+func Build(entry string, config Config) (*Result, error) {
+	bundle := newBundle(config)
+
+	if !isPathSpecifier(entry) {
+		entry = "./" + entry
+	}
+	if bundle.properties != nil {
+		// a mangled name must not be the name of a property that is kept in
+		// any module, including the modules loaded after it is chosen
+		collect := newBundle(config)
+		if _, err := collect.resolveModule(entry, "", kindImport); err != nil {
+			return nil, err
+		}
+		bundle.properties.reserved = collect.properties.reserved
+	}
+	entryModule, err := bundle.resolveModule(entry, "", kindImport)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// search for node_modules in all subdirectories
-	for i := 0; i < 10; i++ {
-		nodeModulesPath, err := getNodeModulePath(searchPath)
-		if err != nil {
-			return "", fmt.Errorf("Cannot find module: %v", importValue)
+	// write the bundle file
+	out := new(bytes.Buffer)
+	if bundle.config.Platform == PlatformNode {
+		out.WriteString(bundle.runtime(nodeGlobalJS))
+	} else {
+		out.WriteString(bundle.runtime(globalJS))
+		if bundle.usesProcess {
+			out.WriteString(bundle.runtime(processJS))
 		}
-
-		path := filepath.Join(nodeModulesPath, importValue)
-		ext := filepath.Ext(path)
-		if len(ext) > 0 {
-			if _, err := os.Stat(path); err == nil {
-				return bundle.loadModule(path)
-			}
+		out.WriteString(bundle.polyfillSource())
+	}
+	// modules are written in a fixed order so that the same input always
+	// gives the same bundle
+	var paths []string
+	for path := range bundle.modules {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var sourceMap sourceMapBuilder
+	var spans []moduleSpan
+	line, counted := 0, 0
+	for _, path := range paths {
+		mod := bundle.modules[path]
+		if bundle.config.Minify {
+			out.Write([]byte(fmt.Sprintf("__go_bundle_modules__.%v=function(){var exports={},module={exports:exports};", mod.name)))
 		} else {
-			packagePath := filepath.Join(path, "package.json")
-			if _, err := os.Stat(packagePath); err == nil {
-				packageData, err := ioutil.ReadFile(packagePath)
-				if err != nil {
-					return "", err
-				}
-
-				var pkg map[string]interface{}
-				err = json.Unmarshal(packageData, &pkg)
-				if err != nil {
-					return "", err
-				}
-
-				mainPath := "index.js"
-				if main, ok := pkg["main"]; ok {
-					mainPath = main.(string)
-				}
+			out.Write([]byte(fmt.Sprint("\n// ", path)))
+			out.Write([]byte(fmt.Sprintf("\n__go_bundle_modules__.%v = function() {\n", mod.name)))
+			out.Write([]byte("var exports = {};\n"))
+			out.Write([]byte("var module = { exports: exports };\n"))
+		}
+		if len(mod.mappings) > 0 {
+			line += bytes.Count(out.Bytes()[counted:], []byte("\n"))
+			counted = out.Len()
+			column := out.Len() - bytes.LastIndexByte(out.Bytes(), '\n') - 1
+			sourceMap.addModule(bundle, path, mod, line, column)
+		}
+		start := out.Len()
+		out.Write(mod.data)
+		spans = append(spans, moduleSpan{path: path, mod: mod, start: start, end: out.Len()})
+		if bundle.config.Minify {
+			out.Write([]byte(";return module.exports};"))
+		} else {
+			out.Write([]byte("\nreturn module.exports;\n"))
+			out.Write([]byte("};\n\n"))
+		}
+	}
+	out.WriteString(bundle.runtime(requireJS))
+	out.Write([]byte(fmt.Sprintf("require('%v');", entryModule)))
 
-				return bundle.loadModule(filepath.Join(path, mainPath))
-			}
+	if bundle.config.VerifyTarget {
+		if err := bundle.verifyTarget(out.Bytes(), spans); err != nil {
+			return nil, err
+		}
+	}
 
-			if _, err := os.Stat(fmt.Sprint(path, ".js")); err == nil {
-				return bundle.loadModule(fmt.Sprint(path, ".js"))
-			}
+	var mapData []byte
+	if bundle.config.SourceMap != SourceMapNone {
+		if mapData, err = sourceMap.encode(); err != nil {
+			return nil, err
+		}
+		if bundle.config.SourceMap == SourceMapInline {
+			out.WriteString(inlineSourceMap(mapData))
+			mapData = nil
+		}
+	}
 
-			if _, err := os.Stat(fmt.Sprint(path, ".json")); err == nil {
-				return bundle.loadModule(fmt.Sprint(path, ".json"))
-			}
+	result := &Result{
+		Name:      strings.TrimSuffix(filepath.Base(entry), filepath.Ext(entry)),
+		Code:      out.Bytes(),
+		Assets:    bundle.assets,
+		SourceMap: mapData,
+	}
+	for path := range bundle.dependencies {
+		result.Dependencies = append(result.Dependencies, path)
+	}
+	sort.Strings(result.Dependencies)
 
-			if _, err := os.Stat(fmt.Sprint(path, "/index.js")); err == nil {
-				return bundle.loadModule(fmt.Sprint(path, "/index.js"))
-			}
-		}
-		searchPath = filepath.Join(nodeModulesPath, "../..")
+	if err := bundle.pluginBuildEnd(result); err != nil {
+		return nil, err
 	}
-	return "", fmt.Errorf("Failed to resolve Module")
+	return result, nil
 }
 
-func (bundle *_bundle) loadModule(path string) (string, error) {
+// resolveModule resolves importValue and loads it into the bundle, returning
+// the name of the module. currentPath is empty for the entry module.
+func (bundle *_bundle) resolveModule(importValue, currentPath string, kind importKind) (string, error) {
+	importValue, query := splitQuery(importValue)
+	path, err := bundle.resolve(importValue, currentPath, kind)
+	if err != nil {
+		return "", err
+	}
+	return bundle.loadModule(path, query, currentPath)
+}
 
-	if _, err := os.Stat(fmt.Sprint(path, ".js")); err == nil {
-		path = fmt.Sprint(path, ".js")
-	} else if _, err := os.Stat(fmt.Sprint(path, "/index.js")); err == nil {
-		path = fmt.Sprint(path, "/index.js")
+func (bundle *_bundle) loadModule(path, query, importer string) (string, error) {
+	if path == emptyModulePath {
+		return bundle.emptyModule(), nil
+	}
+	if strings.HasPrefix(path, builtinPathPrefix) {
+		return bundle.builtinModule(path), nil
 	}
 
 	// use the absolute path and check if the file is already loaded
-	absPath, err := filepath.Abs(path)
-	if err != nil {
-		return "", err
+	absPath := path
+	if !isVirtualPath(path) {
+		var err error
+		if absPath, err = bundle.fs.abs(path); err != nil {
+			return "", err
+		}
 	}
 
-	if mod, ok := bundle.modules[absPath]; ok {
+	key := absPath + query
+	if mod, ok := bundle.modules[key]; ok {
 		if mod.data == nil {
-			return "", fmt.Errorf("circular imports not allowed: %v", absPath)
+			return "", fmt.Errorf("circular imports not allowed: %v", key)
 		}
 		return mod.name, nil
 	}
@@ -166,77 +403,234 @@ func (bundle *_bundle) loadModule(path string) (string, error) {
 	// create a new module
 	moduleName := bundle.moduleName()
 	mod := &module{name: moduleName}
-	bundle.modules[absPath] = mod
+	bundle.modules[key] = mod
 
 	// load file and transform using the loader plugins
-	var src io.Reader
-	src, err = os.Open(absPath)
+	loaded, err := bundle.pluginLoad(absPath, query)
 	if err != nil {
 		return moduleName, err
 	}
+	pluginLoaded := loaded != nil
+	if loaded == nil {
+		if loaded, err = bundle.loadVirtual(absPath); err != nil {
+			return moduleName, err
+		}
+	}
+	if loaded == nil {
+		if isVirtualPath(absPath) {
+			return moduleName, fmt.Errorf("No plugin loaded the module: %v", absPath)
+		}
+		data, err := bundle.fs.readFile(absPath)
+		if err != nil {
+			return moduleName, err
+		}
+		bundle.dependencies[absPath] = true
+		loaded = &LoadResult{Contents: data}
+	}
 
-	ext := filepath.Ext(absPath)
-	if loaders, ok := bundle.loaders[ext]; ok {
-		for _, loader := range loaders {
-			src, err = loader.Load(src)
+	// source maps of the loaded file and of the loaders are composed, so
+	// that they map the final source to the original files
+	mapSources := bundle.config.SourceMap != SourceMapNone
+	if mapSources {
+		if loaded.SourceMap != nil {
+			if mod.inputMap, err = parseSourceMap(loaded.SourceMap, filepath.Dir(absPath)); err != nil {
+				return moduleName, fmt.Errorf("Invalid source map for %v: %v", key, err)
+			}
+		} else {
+			mod.inputMap = bundle.loadInputSourceMap(absPath, loaded.Contents)
+		}
+	}
+	loadersMapped := false
+
+	var src io.Reader = bytes.NewReader(loaded.Contents)
+	rule := bundle.rule(absPath, query)
+	if len(rule.Loaders) > 0 {
+		ctx := &LoadContext{Path: absPath, Query: query, Importer: importer, bundle: bundle}
+		for _, loader := range rule.Loaders {
+			src, err = loader.LoadModule(ctx, src)
 			if err != nil {
-				return moduleName, nil
+				return moduleName, fmt.Errorf("Error loading %v: %v", key, err)
+			}
+			if mapSources && ctx.sourceMap != nil {
+				input, err := parseSourceMap(ctx.sourceMap, filepath.Dir(absPath))
+				if err != nil {
+					return moduleName, fmt.Errorf("Invalid source map for %v: %v", key, err)
+				}
+				mod.inputMap, ctx.sourceMap = input.compose(mod.inputMap), nil
+				loadersMapped = true
 			}
 		}
 	}
 
-	// non js files do no not need to be parsed.
-	if ext != ".js" {
+	// virtual modules without an extension are javascript
+	ext := filepath.Ext(absPath)
+	if ext == "" && isVirtualPath(absPath) {
+		ext = ".js"
+	}
+	moduleType := loaded.Type
+	if moduleType == ModuleAuto {
+		moduleType = rule.Type
+	}
+
+	switch moduleType.forExtension(ext) {
+	case ModuleJSON:
+		mod.data, err = loadJSON(src, absPath)
+		bundle.jsonModules[moduleName] = true
+		return moduleName, err
+	case ModuleAsset:
+		// files of unknown types are only included as the output of a
+		// loader or plugin, as their contents are not javascript
+		if moduleType == ModuleAuto && len(rule.Loaders) == 0 && !pluginLoaded {
+			return moduleName, fmt.Errorf("No loader for %v files: %v", ext, key)
+		}
+		// assets do no not need to be parsed.
 		var buf bytes.Buffer
 		_, err := io.Copy(&buf, src)
 		mod.data = buf.Bytes()
 		return moduleName, err
 	}
 
+	// loaders that do not set a source map may link to one from their output
+	if mapSources && len(rule.Loaders) > 0 && !loadersMapped {
+		data, err := ioutil.ReadAll(src)
+		if err != nil {
+			return moduleName, err
+		}
+		if input := bundle.loadInputSourceMap(absPath, data); input != nil {
+			mod.inputMap = input
+		}
+		src = bytes.NewReader(data)
+	}
+
 	// parse the js code and generate source
-	prog, err := parser.ParseFile(nil, path, src, parser.IgnoreRegExpErrors&parser.StoreComments)
+	options := bundle.config.generatorOptions()
+	prog, err := parser.ParseFile(nil, path, src, options.parserMode())
 	if err != nil {
 		return moduleName, err
 	}
 
-	gen, err := generate(prog, path, bundle)
+	if err := bundle.pluginTransform(prog, absPath); err != nil {
+		return moduleName, err
+	}
+
+	gen, err := generate(prog, path, bundle, options)
 	if err != nil {
 		return moduleName, err
 	}
 
 	// load the generated source code into the module data
 	var buf bytes.Buffer
-	if _, err := io.Copy(&buf, gen); err != nil {
+	if _, err := io.Copy(&buf, gen.code()); err != nil {
 		return moduleName, err
 	}
 
 	mod.data = buf.Bytes()
+	if gen.sourceLines != nil {
+		mod.source, mod.mappings = prog.File.Source(), gen.mappings
+	}
 	return moduleName, nil
 }
 
+// loadJSON validates a JSON file and returns a module exporting its value.
+func loadJSON(src io.Reader, path string) ([]byte, error) {
+	data, err := ioutil.ReadAll(src)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("module.exports = ")
+	if err := json.Compact(&buf, data); err != nil {
+		return nil, fmt.Errorf("Invalid JSON in %v: %v", path, err)
+	}
+	buf.WriteString(";")
+
+	// line and paragraph separators are valid in JSON strings but not in
+	// javascript string literals before ES2019
+	out := bytes.Replace(buf.Bytes(), []byte("\u2028"), []byte(`\u2028`), -1)
+	return bytes.Replace(out, []byte("\u2029"), []byte(`\u2029`), -1), nil
+}
+
+// emptyModule returns the name of a module that exports an empty object.
+func (b *_bundle) emptyModule() string {
+	if mod, ok := b.modules[emptyModulePath]; ok {
+		return mod.name
+	}
+	mod := &module{name: b.moduleName(), data: []byte{}}
+	b.modules[emptyModulePath] = mod
+	return mod.name
+}
+
+// builtinModule returns the name of the module for a core module path.
+func (b *_bundle) builtinModule(path string) string {
+	if mod, ok := b.modules[path]; ok {
+		return mod.name
+	}
+	mod := &module{name: b.moduleName()}
+	mod.data = b.builtinSource(strings.TrimPrefix(path, builtinPathPrefix))
+	if b.config.Minify {
+		mod.data = []byte(minifyJS(string(mod.data)))
+	}
+	b.modules[path] = mod
+	return mod.name
+}
+
+// runtime returns the bundle runtime code src, which is minified or laid out
+// like the modules.
+func (b *_bundle) runtime(src string) string {
+	if b.config.Minify {
+		return minifyJS(src) + ";"
+	}
+	if b.config.Print != (PrintOptions{}) {
+		return "\n" + formatJS(src, Options{Print: b.config.Print}) + "\n"
+	}
+	return src
+}
+
+// emitAsset adds or replaces the asset called name.
+func (b *_bundle) emitAsset(name string, data []byte) {
+	for i := range b.assets {
+		if b.assets[i].Name == name {
+			b.assets[i].Data = data
+			return
+		}
+	}
+	b.assets = append(b.assets, Asset{Name: name, Data: data})
+}
+
+// splitQuery splits the resource query, eg. "?raw", from an import.
+func splitQuery(importValue string) (string, string) {
+	if i := strings.Index(importValue, "?"); i > 0 {
+		return importValue[:i], importValue[i:]
+	}
+	return importValue, ""
+}
+
 // moduleName - generate a unique name for a module
 func (b *_bundle) moduleName() string {
 	b.moduleCounter++
 	return fmt.Sprint("m", b.moduleCounter)
 }
 
-func getNodeModulePath(path string) (string, error) {
-	for i := 0; i < 10; i++ {
-		files, err := filepath.Glob(filepath.Join(path, "node_modules"))
-		if err != nil {
-			return "", err
-		}
-		if files != nil && len(files) > 0 {
-			return files[0], nil
-		}
-		path = filepath.Join(path, "..")
+func newBundle(config Config) *_bundle {
+	fsys := newFileSystem(config.FS)
+	bundle := &_bundle{
+		config:       config.withDefaults(),
+		fs:           fsys,
+		rules:        config.rules(),
+		virtual:      config.virtualModules(fsys),
+		modules:      make(map[string]*module),
+		packages:     make(map[string]*packageJSON),
+		tsconfigs:    make(map[string]*tsconfig),
+		jsonModules:  make(map[string]bool),
+		dependencies: make(map[string]bool),
 	}
-	return "", fmt.Errorf("No node_modules directory found in any subdirectories")
-}
-
-func newBundle() *_bundle {
-	return &_bundle{
-		modules: make(map[string]*module),
-		loaders: make(map[string][]Loader),
+	if config.MangleProperties != nil {
+		bundle.properties = newPropertyNames(config.MangleProperties)
+	}
+	// Node.js has the built-ins that are polyfilled
+	if config.Polyfill && bundle.config.Platform == PlatformBrowser {
+		bundle.polyfills = make(map[string]bool)
 	}
+	return bundle
 }
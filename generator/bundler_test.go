@@ -0,0 +1,145 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/walesey/go-bundle/parser"
+)
+
+func TestBundleBuiltins(t *testing.T) {
+	bundle := func(entry string, config Config) string {
+		out, err := BundleWithConfig(entry, config)
+		assert.NoError(t, err, entry)
+		src, err := ioutil.ReadAll(out)
+		assert.NoError(t, err, entry)
+		return string(src)
+	}
+
+	// the shims and process object are only included when they are used
+	src := bundle("testdata/builtins/noprocess.js", Config{})
+	assert.Contains(t, src, "exports.basename = function")
+	assert.NotContains(t, src, "function EventEmitter")
+	assert.NotContains(t, src, "var process")
+
+	src = bundle("testdata/builtins/process.js", Config{})
+	assert.Contains(t, src, "nextTick: function")
+
+	src = bundle("testdata/builtins/index.js", Config{})
+	assert.Contains(t, src, "function EventEmitter")
+	assert.NotContains(t, src, "var process")
+
+	src = bundle("testdata/builtins/index.js", Config{Platform: PlatformNode})
+	assert.Contains(t, src, "__go_bundle_node_require__('events')")
+	assert.NotContains(t, src, "function EventEmitter")
+	assert.Contains(t, src, "var __go_bundle_node_require__ = require;")
+}
+
+func TestBundleJSON(t *testing.T) {
+	out, err := BundleWithConfig("testdata/json/index.js", Config{})
+	assert.NoError(t, err)
+	src, err := ioutil.ReadAll(out)
+	assert.NoError(t, err)
+	assert.Contains(t, string(src), `module.exports = {"name":"go-bundle","default":"not the module","nested":{"list":[1,2.50,null]},"sep":"a\u2028b"};`)
+	assert.Contains(t, string(src), "module.exports = [1,2,3];")
+
+	// the default import is the whole value, even if it has a "default" key
+	bundle := newBundle(Config{})
+	name, err := bundle.resolveModule("./data.json", "testdata/json/index.js", kindImport)
+	assert.NoError(t, err)
+	prog, err := parser.ParseFile(nil, "", "import data, { name } from './data.json';\nimport * as all from './data.json';", 0)
+	assert.NoError(t, err)
+	gen, err := generate(prog, "testdata/json/index.js", bundle, Options{})
+	assert.NoError(t, err)
+	code, err := ioutil.ReadAll(gen.code())
+	assert.NoError(t, err)
+	assert.Contains(t, string(code), fmt.Sprintf("var data = require('%v');", name))
+	assert.Contains(t, string(code), fmt.Sprintf("var name = require('%v').name;", name))
+	assert.Contains(t, string(code), fmt.Sprintf("var all = Object.assign({}, require('%v'), { default: require('%v') });", name, name))
+
+	_, err = BundleWithConfig("testdata/json/invalid.js", Config{})
+	assert.Error(t, err)
+}
+
+type upperLoader struct{}
+
+func (upperLoader) Load(in io.Reader) (io.Reader, error) {
+	data, err := ioutil.ReadAll(in)
+	return bytes.NewReader(bytes.ToUpper(data)), err
+}
+
+func TestBundleLoaders(t *testing.T) {
+	root := testdataPath(t, "loaders")
+
+	var contexts []LoadContext
+	textLoader := LoaderFunc(func(ctx *LoadContext, in io.Reader) (io.Reader, error) {
+		contexts = append(contexts, *ctx)
+		data, err := ioutil.ReadAll(in)
+		if err != nil {
+			return nil, err
+		}
+		ctx.EmitAsset("text/"+filepath.Base(ctx.Path), data)
+		ctx.AddDependency(filepath.Join(root, "extra.txt"))
+		return strings.NewReader(fmt.Sprintf("module.exports = %q;", data)), nil
+	})
+
+	// the loaders are applied in order, adapting the ones without a context
+	config := Config{Loaders: map[string][]ModuleLoader{
+		".txt": {LoaderFunc(func(ctx *LoadContext, in io.Reader) (io.Reader, error) {
+			if ctx.Query == "?upper" {
+				return upperLoader{}.Load(in)
+			}
+			return in, nil
+		}), textLoader},
+	}}
+	result, err := Build("testdata/loaders/index.js", config)
+	assert.NoError(t, err)
+
+	assert.Len(t, contexts, 2)
+	assert.Equal(t, filepath.Join(root, "a.txt"), contexts[0].Path)
+	assert.Equal(t, "?upper", contexts[0].Query)
+	assert.Equal(t, filepath.Join(root, "index.js"), contexts[0].Importer)
+	assert.Equal(t, "", contexts[1].Query)
+
+	assert.Contains(t, string(result.Code), `module.exports = "HELLO";`)
+	assert.Contains(t, string(result.Code), `module.exports = "world";`)
+	assert.Equal(t, []Asset{{"text/a.txt", []byte("HELLO")}, {"text/b.txt", []byte("world")}}, result.Assets)
+	assert.Equal(t, []string{
+		filepath.Join(root, "a.txt"),
+		filepath.Join(root, "b.txt"),
+		filepath.Join(root, "extra.txt"),
+		filepath.Join(root, "index.js"),
+	}, result.Dependencies)
+
+	// assets emitted by earlier modules of the build can be added to, and
+	// start empty in the next build
+	config.Loaders[".txt"] = []ModuleLoader{LoaderFunc(func(ctx *LoadContext, in io.Reader) (io.Reader, error) {
+		data, _ := ctx.Asset("all.txt")
+		ctx.EmitAsset("all.txt", append(append([]byte{}, data...), filepath.Base(ctx.Path)+";"...))
+		return strings.NewReader("module.exports = 1;"), nil
+	})}
+	for i := 0; i < 2; i++ {
+		result, err = Build("testdata/loaders/index.js", config)
+		assert.NoError(t, err)
+		assert.Equal(t, []Asset{{"all.txt", []byte("a.txt;b.txt;")}}, result.Assets)
+	}
+
+	// loader errors stop the build
+	config.Loaders[".txt"] = []ModuleLoader{AdaptLoader(upperLoader{}), LoaderFunc(func(ctx *LoadContext, in io.Reader) (io.Reader, error) {
+		return nil, fmt.Errorf("failed")
+	})}
+	_, err = Build("testdata/loaders/a.txt", config)
+	assert.Error(t, err)
+
+	// also when the module is loaded by require
+	_, err = Build("testdata/loaders/index.js", config)
+	assert.EqualError(t, err, "Error loading "+filepath.Join(root, "a.txt")+"?upper: failed")
+	_, err = BuildSource("require('./missing.txt');", "testdata/loaders/index.js", Config{})
+	assert.EqualError(t, err, "Cannot find module: ./missing.txt")
+}
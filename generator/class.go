@@ -0,0 +1,331 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/walesey/go-bundle/ast"
+)
+
+// classContext is the class whose methods are being generated as functions,
+// for targets before ES2015.
+type classContext struct {
+	// super is the name of the superclass in the lowered class, or "" if
+	// the class does not extend another
+	super string
+
+	// static is set while generating a static method
+	static bool
+}
+
+// superObject returns the code of the object whose properties super.x
+// refers to.
+func (c *classContext) superObject() string {
+	switch {
+	case c.super == "" && c.static:
+		return "Function.prototype"
+	case c.super == "":
+		return "Object.prototype"
+	case c.static:
+		return c.super
+	}
+	return c.super + ".prototype"
+}
+
+func (g *generator) classStatement(c *ast.ClassStatement) error {
+	if g.supports(es2015) {
+		g.writeLine("")
+		return g.classLiteral(c.Class)
+	}
+	g.writeLine("var ")
+	g.write(c.Class.Name.Name + " = ")
+	if err := g.classLiteral(c.Class); err != nil {
+		return err
+	}
+	g.endStatement()
+	return nil
+}
+
+// classLiteral generates c, which is lowered to a constructor function for
+// targets before ES2015.
+func (g *generator) classLiteral(c *ast.ClassLiteral) error {
+	if !g.supports(es2015) {
+		return g.lowerClass(c)
+	}
+	g.write("class")
+	if c.Name != nil {
+		g.write(" ")
+		g.mark(c.Name)
+		g.write(c.Name.Name)
+	}
+	if c.SuperClass != nil {
+		g.write(" extends ")
+		if err := g.subExpression(c.SuperClass, precCall); err != nil {
+			return err
+		}
+	}
+	g.write(" {")
+	g.indentLevel++
+	for _, m := range c.Body {
+		if err := g.method(m); err != nil {
+			return err
+		}
+	}
+	g.writeFinalComments(c)
+	g.indentLevel--
+	g.writeAlone("}")
+	return nil
+}
+
+// method generates a method of a class, eg. static get size() {}.
+func (g *generator) method(m ast.MethodDefinition) error {
+	g.writeOwnLineComments(g.takeComments(m.Value, isKeyComment))
+	prefix := ""
+	if m.Static {
+		prefix = "static "
+	}
+	g.writeLine(prefix)
+	if err := g.async(m.Value); err != nil {
+		return err
+	}
+	if m.Kind == "get" || m.Kind == "set" {
+		g.write(m.Kind + " ")
+	}
+	g.mark(m.Value)
+	if m.Kind == "constructor" {
+		g.write("constructor")
+	} else if m.Computed != nil {
+		if err := g.computedKey(m.Computed, false); err != nil {
+			return err
+		}
+	} else {
+		g.writeRaw(g.key(m.Key))
+	}
+	if err := g.parameterList(m.Value); err != nil {
+		return err
+	}
+	g.write(" ")
+	return g.generateStatement(m.Value.Body, m.Value.DeclarationList)
+}
+
+// lowerClass generates c as a function that returns the constructor, like
+// the loose mode of other compilers: methods are assigned to the prototype,
+// so they are enumerable, the constructor of a subclass calls the
+// superclass as a function, and static methods are inherited through
+// __proto__.
+//
+//	function (_super) {
+//	  function A() { _super.apply(this, arguments); }
+//	  A.prototype = Object.create(_super.prototype);
+//	  A.prototype.constructor = A;
+//	  A.__proto__ = _super;
+//	  A.prototype.m = function () {};
+//	  return A;
+//	}(B)
+func (g *generator) lowerClass(c *ast.ClassLiteral) error {
+	var name string
+	if c.Name != nil {
+		name = c.Name.Name
+	} else {
+		name = g.freeName("_class")
+	}
+	outer := g.class
+	class := &classContext{}
+	if c.SuperClass != nil {
+		// the superclass must not shadow the variables the methods use
+		class.super = g.freeName("_super")
+	}
+	g.class = class
+	defer func() {
+		g.class = outer
+	}()
+
+	g.write("function (" + class.super + ") {")
+	g.indentLevel++
+	if err := g.constructor(c, name); err != nil {
+		return err
+	}
+	if class.super != "" {
+		g.writeLine(name + ".prototype = Object.create(" + class.super + ".prototype)")
+		g.endStatement()
+		g.writeLine(name + ".prototype.constructor = " + name)
+		g.endStatement()
+		g.writeLine(name + ".__proto__ = " + class.super)
+		g.endStatement()
+	}
+
+	defined := make(map[string]bool)
+	for _, m := range c.Body {
+		object := name + ".prototype"
+		if m.Static {
+			object = name
+		}
+		class.static = m.Static
+		switch m.Kind {
+		case "constructor":
+			continue
+		case "get", "set":
+			if m.Computed != nil {
+				// computed keys may differ, so each accessor is defined alone
+				if err := g.defineAccessors(c, m, object); err != nil {
+					return err
+				}
+				break
+			}
+			if defined[object+"."+m.Key] {
+				// defined with the other accessor of the key
+				continue
+			}
+			defined[object+"."+m.Key] = true
+			if err := g.defineAccessors(c, m, object); err != nil {
+				return err
+			}
+		default:
+			g.writeOwnLineComments(g.takeComments(m.Value, isKeyComment))
+			g.writeLine(object)
+			if m.Computed != nil {
+				if err := g.computedKey(m.Computed, false); err != nil {
+					return err
+				}
+			} else {
+				g.write(g.member(m.Key))
+			}
+			g.write(" = ")
+			if err := g.functionLiteral(m.Value, false); err != nil {
+				return err
+			}
+		}
+		g.endStatement()
+	}
+	g.writeLine("return ")
+	g.write(name)
+	g.endStatement()
+	g.writeFinalComments(c)
+	g.indentLevel--
+	g.writeAlone("}")
+
+	g.class = outer
+	g.write("(")
+	if err := g.subExpression(c.SuperClass, precAssign); err != nil {
+		return err
+	}
+	g.write(")")
+	return nil
+}
+
+// constructor generates the constructor function of the class c. A
+// subclass without a constructor calls the superclass with its arguments.
+func (g *generator) constructor(c *ast.ClassLiteral, name string) error {
+	for _, m := range c.Body {
+		if m.Kind == "constructor" {
+			g.writeOwnLineComments(g.takeComments(m.Value, isKeyComment))
+			g.writeLine("function ")
+			g.write(name)
+			g.mark(m.Value)
+			if err := g.parameterList(m.Value); err != nil {
+				return err
+			}
+			g.write(" ")
+			return g.generateStatement(m.Value.Body, m.Value.DeclarationList)
+		}
+	}
+	g.writeLine("function ")
+	g.write(name + "() {")
+	if g.class.super != "" {
+		g.indentLevel++
+		g.writeLine(g.class.super + ".apply(this, arguments)")
+		g.endStatement()
+		g.indentLevel--
+	}
+	g.writeAlone("}")
+	return nil
+}
+
+// defineAccessors generates the definition of the getter and setter of the
+// key of m on object, eg. Object.defineProperty(A.prototype, 'size', {}).
+// Only m is defined if its key is computed.
+func (g *generator) defineAccessors(c *ast.ClassLiteral, m ast.MethodDefinition, object string) error {
+	descriptor := &ast.ObjectLiteral{}
+	for _, accessor := range c.Body {
+		if m.Computed != nil && accessor.Value != m.Value || accessor.Computed != nil && m.Computed == nil {
+			continue
+		}
+		if accessor.Static == m.Static && accessor.Key == m.Key && (accessor.Kind == "get" || accessor.Kind == "set") {
+			descriptor.Value = append(descriptor.Value, ast.Property{Key: accessor.Kind, Kind: "value", Value: accessor.Value})
+		}
+	}
+	descriptor.Value = append(descriptor.Value, ast.Property{Key: "configurable", Kind: "value", Value: &ast.BooleanLiteral{Literal: "true", Value: true}})
+
+	g.writeLine("Object.defineProperty(" + object + ", ")
+	if m.Computed != nil {
+		if err := g.subExpression(m.Computed, precAssign); err != nil {
+			return err
+		}
+	} else {
+		g.writeRaw(g.quoted(escapeKey(m.Key)))
+	}
+	g.write(", ")
+	if err := g.objectLiteral(descriptor); err != nil {
+		return err
+	}
+	g.write(")")
+	return nil
+}
+
+// member returns the code reading the property key of an object, eg. .a or
+// ['a-b'].
+func (g *generator) member(key string) string {
+	if escapeKeyIfRequired(key) == key {
+		return "." + key
+	}
+	return "[" + g.quoted(escapeKey(key)) + "]"
+}
+
+// superExpression generates super, which refers to the superclass of the
+// lowered class for targets before ES2015.
+func (g *generator) superExpression(s *ast.SuperExpression) error {
+	if g.supports(es2015) {
+		g.write("super")
+		return nil
+	}
+	if g.class == nil {
+		return fmt.Errorf("Cannot lower %v: super outside of a class method", g.filePath)
+	}
+	g.write(g.class.superObject())
+	return nil
+}
+
+// callsSuper reports whether c calls super or a method of super.
+func callsSuper(c *ast.CallExpression) bool {
+	switch callee := c.Callee.(type) {
+	case *ast.SuperExpression:
+		return true
+	case *ast.DotExpression:
+		_, ok := callee.Left.(*ast.SuperExpression)
+		return ok
+	case *ast.BracketExpression:
+		_, ok := callee.Left.(*ast.SuperExpression)
+		return ok
+	}
+	return false
+}
+
+// superCall generates c, a call of super or of a method of super in a
+// lowered class, as a call with this as the receiver, eg.
+// _super.prototype.m.call(this, a). In a lowered arrow function, the
+// receiver is the captured this of the method.
+func (g *generator) superCall(c *ast.CallExpression) error {
+	if _, ok := c.Callee.(*ast.SuperExpression); ok {
+		if g.class == nil || g.class.super == "" {
+			return fmt.Errorf("Cannot lower %v: super() outside of the constructor of a subclass", g.filePath)
+		}
+		g.write(g.class.super)
+	} else if err := g.subExpression(c.Callee, precCall); err != nil {
+		return err
+	}
+	g.write(".call")
+	var this ast.Expression = &ast.ThisExpression{}
+	if name, ok := g.captured[c]; ok {
+		this = &ast.Identifier{Name: name}
+	}
+	return g.argumentList(append([]ast.Expression{this}, c.ArgumentList...))
+}
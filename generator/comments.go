@@ -0,0 +1,281 @@
+package generator
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/walesey/go-bundle/ast"
+)
+
+// CommentMode selects which comments of the source are kept in the
+// generated code.
+type CommentMode string
+
+const (
+	// CommentsNone removes all comments.
+	CommentsNone CommentMode = ""
+
+	// CommentsLicense keeps license comments, which start with "!", eg.
+	// "/*! ... */", or contain "@license" or "@preserve".
+	CommentsLicense CommentMode = "license"
+
+	// CommentsAll keeps all comments.
+	CommentsAll CommentMode = "all"
+)
+
+func isLicenseComment(text string) bool {
+	return strings.HasPrefix(text, "!") ||
+		strings.Contains(text, "@license") ||
+		strings.Contains(text, "@preserve")
+}
+
+// isSourceMapComment reports whether text links to a source map. These are
+// never kept, as the map does not apply to the generated code.
+func isSourceMapComment(text string) bool {
+	return strings.HasPrefix(text, "# sourceMappingURL=") || strings.HasPrefix(text, "@ sourceMappingURL=")
+}
+
+// initComments prepares the comments of p that are kept. The comments of
+// function statements are moved to their function, as functions are written
+// where they are hoisted to, except for those that end the line of the code
+// before the function, which stay with that code.
+func (g *generator) initComments(p *ast.Program) {
+	g.comments = make(ast.CommentMap)
+	g.emitted = make(map[*ast.Comment]bool)
+	g.source, g.base = p.File.Source(), p.File.Base()
+	for node, comments := range p.Comments {
+		for _, c := range comments {
+			if !g.keepComment(c) {
+				continue
+			}
+			if fn, ok := node.(*ast.FunctionStatement); ok && !g.followsCode(c) {
+				g.comments.AddComment(fn.Function, c)
+			} else {
+				g.comments.AddComment(node, c)
+			}
+		}
+	}
+}
+
+func (g *generator) keepComment(c *ast.Comment) bool {
+	if isSourceMapComment(c.Text) {
+		return false
+	}
+	switch g.options.Comments {
+	case CommentsAll:
+		return true
+	case CommentsLicense:
+		return isLicenseComment(c.Text)
+	}
+	return false
+}
+
+// takeComments returns the comments of node that match and have not been
+// written yet, and marks them as written.
+func (g *generator) takeComments(node ast.Node, match func(ast.CommentPosition) bool) []*ast.Comment {
+	if g.comments == nil || node == nil {
+		return nil
+	}
+	var comments []*ast.Comment
+	for _, c := range g.comments[node] {
+		if !g.emitted[c] && match(c.Position) {
+			g.emitted[c] = true
+			comments = append(comments, c)
+		}
+	}
+	return comments
+}
+
+func isLeadingComment(position ast.CommentPosition) bool {
+	return position != ast.TRAILING && position != ast.FINAL
+}
+
+func isTrailingComment(position ast.CommentPosition) bool {
+	return position == ast.TRAILING
+}
+
+func isFinalComment(position ast.CommentPosition) bool {
+	return position == ast.FINAL
+}
+
+func isKeyComment(position ast.CommentPosition) bool {
+	return position == ast.KEY
+}
+
+// isLineComment reports whether c was written as a "//" comment. The
+// comment is looked up in the source, as its index is the start of the
+// whitespace and comments before the next token.
+func (g *generator) isLineComment(c *ast.Comment) bool {
+	offset := int(c.Begin) - g.base
+	if offset < 0 || offset > len(g.source) {
+		offset = 0
+	}
+	src := g.source[offset:]
+	line := strings.Index(src, "//"+c.Text)
+	block := strings.Index(src, "/*"+c.Text+"*/")
+	return line != -1 && (block == -1 || line < block)
+}
+
+// writeComment writes c in its original form, after a space. A line comment
+// ends the line, so the code after it is written on the next line.
+func (g *generator) writeComment(c *ast.Comment) {
+	if g.isLineComment(c) {
+		g.writeUnmapped(" //" + c.Text)
+		g.newlinePending = true
+		return
+	}
+	g.writeUnmapped(" /*" + c.Text + "*/")
+}
+
+// inlineComment returns c as a block comment, for comments within code.
+func inlineComment(c *ast.Comment) string {
+	return "/*" + strings.Replace(c.Text, "*/", "* /", -1) + "*/"
+}
+
+// writeUnmapped writes s without mapping it, keeping the pending mapping for
+// the code after it.
+func (g *generator) writeUnmapped(s string) {
+	pendingIdx := g.pendingIdx
+	g.pendingIdx = 0
+	g.writeRaw(s)
+	g.pendingIdx = pendingIdx
+}
+
+// writeOwnLineComments writes comments on lines of their own, except for
+// the comments that end a line of code in the source, which stay on the
+// current line.
+func (g *generator) writeOwnLineComments(comments []*ast.Comment) {
+	for _, c := range comments {
+		if !g.endsLine(c) {
+			g.newlinePending = true
+		}
+		g.writeComment(c)
+		g.newlinePending = true
+	}
+}
+
+// writeLineEndComments writes the comments of node that match and end the
+// current line of code in the source. They are written before the newline
+// between two items of a list, which their node follows.
+func (g *generator) writeLineEndComments(node ast.Node, match func(ast.CommentPosition) bool) {
+	if g.comments == nil || node == nil {
+		return
+	}
+	for _, c := range g.comments[node] {
+		if !g.emitted[c] && match(c.Position) && g.endsLine(c) {
+			g.emitted[c] = true
+			g.writeComment(c)
+		}
+	}
+}
+
+// endsLine reports whether c follows code on its line in the source, and
+// the generated code has code on the current line for it to follow.
+func (g *generator) endsLine(c *ast.Comment) bool {
+	if !g.followsCode(c) {
+		return false
+	}
+	b := g.buffer.Bytes()
+	return len(bytes.TrimSpace(b[bytes.LastIndexByte(b, '\n')+1:])) > 0
+}
+
+// followsCode reports whether c follows code on its line in the source.
+func (g *generator) followsCode(c *ast.Comment) bool {
+	offset := int(c.Begin) - g.base
+	if offset < 0 || offset > len(g.source) {
+		return false
+	}
+	src := g.source[offset:]
+	end := -1
+	for _, form := range []string{"//" + c.Text, "/*" + c.Text + "*/"} {
+		if i := strings.Index(src, form); i != -1 && (end == -1 || i < end) {
+			end = i
+		}
+	}
+	return end != -1 && !strings.Contains(src[:end], "\n")
+}
+
+// writeLeadingComments writes the comments before a statement.
+func (g *generator) writeLeadingComments(node ast.Node) {
+	g.writeOwnLineComments(g.takeComments(node, isLeadingComment))
+}
+
+// writeTrailingComments writes the comments after a statement.
+func (g *generator) writeTrailingComments(node ast.Node) {
+	g.flushComments()
+	for _, c := range g.takeComments(node, isTrailingComment) {
+		g.writeComment(c)
+	}
+}
+
+// writeFinalComments writes the comments at the end of a block.
+func (g *generator) writeFinalComments(node ast.Node) {
+	g.flushComments()
+	g.writeOwnLineComments(g.takeComments(node, isFinalComment))
+}
+
+// writeInlineComments writes the comments before an expression.
+func (g *generator) writeInlineComments(node ast.Node) {
+	for _, c := range g.takeComments(node, isLeadingComment) {
+		g.writeUnmapped(inlineComment(c) + " ")
+	}
+}
+
+// deferTrailingComments queues the comments after an expression. They are
+// written before the code that follows it, or after the semicolon if the
+// expression ends a statement.
+func (g *generator) deferTrailingComments(node ast.Node) {
+	g.trailing = append(g.trailing, g.takeComments(node, isTrailingComment)...)
+}
+
+// flushComments writes the queued trailing comments at the end of a line.
+func (g *generator) flushComments() {
+	comments := g.trailing
+	g.trailing = nil
+	for _, c := range comments {
+		g.writeComment(c)
+	}
+}
+
+// writeQueuedComments writes the queued trailing comments before s, which
+// is about to be written, and returns what is left of s to write.
+func (g *generator) writeQueuedComments(s string) string {
+	switch {
+	case strings.HasPrefix(s, ";"):
+		comments := g.trailing
+		g.trailing = nil
+		g.write(";")
+		g.trailing = comments
+		g.flushComments()
+		s = s[1:]
+	case strings.HasPrefix(s, "\n"):
+		g.flushComments()
+	default:
+		comments := g.trailing
+		g.trailing = nil
+		for _, c := range comments {
+			g.writeUnmapped(" " + inlineComment(c))
+		}
+	}
+	return s
+}
+
+// startLine is called before s is written after a line comment, and moves
+// it to the next line.
+func (g *generator) startLine(s string) string {
+	g.newlinePending = false
+	if strings.HasPrefix(s, "\n") {
+		return s
+	}
+	s = strings.TrimLeft(s, " ")
+	indent := g.indentationString()
+	b := g.buffer.Bytes()
+	if len(strings.TrimSpace(string(b[bytes.LastIndexByte(b, '\n')+1:]))) == 0 {
+		// the line is empty, so only the indentation is missing
+		if g.column < len(indent) {
+			return indent[g.column:] + s
+		}
+		return s
+	}
+	return "\n" + indent + s
+}
@@ -0,0 +1,94 @@
+package generator
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/walesey/go-bundle/parser"
+)
+
+func TestLoadComments(t *testing.T) {
+	load := func(mode CommentMode) string {
+		f, err := os.Open("testdata/comments/input.js")
+		assert.NoError(t, err)
+		defer f.Close()
+		out, err := LoadWithOptions(f, Options{Comments: mode})
+		assert.NoError(t, err)
+		code, err := ioutil.ReadAll(out)
+		assert.NoError(t, err)
+
+		// the comments must not change the code
+		_, err = parser.ParseFile(nil, "", code, 0)
+		assert.NoError(t, err, string(code))
+		return string(code)
+	}
+
+	code := load(CommentsAll)
+	assert.Contains(t, code, "/*! license text */\n// leading of a\nvar a = 1;")
+	assert.Contains(t, code, "/** doc for f */\nfunction f(x /* param */) {")
+	assert.Contains(t, code, "  return x; // after return\n  // final in f\n}")
+	assert.Contains(t, code, "b(); /* after b */")
+	assert.Contains(t, code, "  // key comment\n  k: /* colon */ 1\n  // final obj\n};")
+	assert.Contains(t, code, "} // after if\nelse {")
+
+	code = load(CommentsLicense)
+	assert.Contains(t, code, "/*! license text */")
+	assert.Contains(t, code, "/* @license MIT */")
+	assert.NotContains(t, code, "leading of a")
+	assert.NotContains(t, code, "param")
+
+	code = load(CommentsNone)
+	assert.NotContains(t, code, "/*")
+	assert.NotContains(t, code, "//")
+}
+
+func TestBundleComments(t *testing.T) {
+	result, err := Build("./testdata/comments/index.js", Config{Comments: CommentsLicense})
+	assert.NoError(t, err)
+	code := string(result.Code)
+	assert.Contains(t, code, "/*! index v1.0.0 | MIT */")
+	assert.Contains(t, code, "@license Apache-2.0")
+	assert.NotContains(t, code, "helpers")
+	assert.NotContains(t, code, "sourceMappingURL")
+
+	result, err = Build("./testdata/comments/index.js", Config{Comments: CommentsAll, SourceMap: SourceMapInline})
+	assert.NoError(t, err)
+	code = string(result.Code)
+	assert.Contains(t, code, "// helpers")
+	assert.Contains(t, code, "helper(); // call it")
+	assert.NotContains(t, code, "sourceMappingURL=index.js.map")
+}
+
+func TestImportComments(t *testing.T) {
+	src := "/*! license */\n// about a\nimport a from './a';\n// side effects\nimport './s';\nvar x = a; // after x\nfunction f() {}"
+	load := func(mode CommentMode) string {
+		out, err := LoadWithOptions(strings.NewReader(src), Options{Comments: mode})
+		assert.NoError(t, err)
+		code, err := ioutil.ReadAll(out)
+		assert.NoError(t, err)
+		return string(code)
+	}
+
+	// the comments before the imports start the module, and a comment after
+	// a statement stays with it when the function after it is hoisted
+	assert.Equal(t,
+		"/*! license */\n// about a\nfunction f() {\n}\nvar a = require('./a').default || require('./a');\n"+
+			"// side effects\nrequire('./s');\nvar x = a; // after x\n",
+		load(CommentsAll))
+	assert.Equal(t,
+		"/*! license */\nfunction f() {\n}\nvar a = require('./a').default || require('./a');\nrequire('./s');\nvar x = a;",
+		load(CommentsLicense))
+
+	result, err := BuildSource(src, "./index.js", Config{
+		Comments: CommentsLicense,
+		Virtual: map[string]VirtualModule{
+			"./a.js": {Contents: "export default 1;"},
+			"./s.js": {Contents: ""},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, string(result.Code), "var module = { exports: exports };\n/*! license */\nfunction f() {")
+}
@@ -0,0 +1,188 @@
+package generator
+
+import (
+	"github.com/walesey/go-bundle/ast"
+	"github.com/walesey/go-bundle/file"
+	"github.com/walesey/go-bundle/token"
+)
+
+// unusedDeclarations returns the function declarations and the variables
+// with pure initializers of p that are never used. The names of the
+// program are only removed if they are local to a module.
+func (o *optimizer) unusedDeclarations(p *ast.Program) map[ast.Node]bool {
+	m := analyzeScopes(p, nil, true)
+	m.resolve()
+	o.free = make(map[*string]bool)
+	for _, ref := range m.references {
+		if ref.binding() == nil {
+			o.free[ref.occurrence.name] = true
+		}
+	}
+	unused := make(map[ast.Node]bool)
+	o.collectUnused(m.scope, unused)
+	return unused
+}
+
+func (o *optimizer) collectUnused(s *scope, unused map[ast.Node]bool) {
+	for _, child := range s.children {
+		o.collectUnused(child, unused)
+	}
+	if s.fixed || s.parent == nil && !o.moduleScope {
+		return
+	}
+	for _, b := range s.order {
+		if b.uses > 0 || b.fixed {
+			continue
+		}
+		for _, node := range b.declarations {
+			switch node := node.(type) {
+			case *ast.VariableExpression:
+				if o.isPure(node.Initializer) {
+					unused[node] = true
+				}
+			case *ast.FunctionLiteral:
+				unused[node] = true
+			}
+		}
+	}
+}
+
+// isPure reports whether evaluating exp has no side effects. It is
+// conservative: calls, property reads and globals other than undefined,
+// NaN and Infinity may run code or throw, so they are never pure. Operators
+// that convert their operands, like + and <, may call valueOf or throw, so
+// they are only pure on primitive literals.
+func (o *optimizer) isPure(exp ast.Expression) bool {
+	switch exp := exp.(type) {
+	case nil:
+		return true
+	case *ast.StringLiteral, *ast.NumberLiteral, *ast.BooleanLiteral, *ast.NullLiteral,
+		*ast.RegExpLiteral, *ast.FunctionLiteral, *ast.ThisExpression:
+		return true
+	case *ast.Identifier:
+		switch exp.Name {
+		case "undefined", "NaN", "Infinity":
+			return true
+		}
+		return !o.free[&exp.Name]
+	case *ast.ArrayLiteral:
+		for _, value := range exp.Value {
+			if !o.isPure(value) {
+				return false
+			}
+		}
+		return true
+	case *ast.ObjectLiteral:
+		for _, property := range exp.Value {
+			if property.Kind == "spread" || property.Computed != nil && !o.isPure(property.Computed) || !o.isPure(property.Value) {
+				return false
+			}
+		}
+		return true
+	case *ast.UnaryExpression:
+		switch exp.Operator {
+		case token.NOT, token.VOID:
+			return o.isPure(exp.Operand)
+		case token.PLUS, token.MINUS, token.BITWISE_NOT:
+			return isPrimitive(exp.Operand)
+		case token.TYPEOF:
+			// typeof does not throw for undeclared names
+			if _, ok := exp.Operand.(*ast.Identifier); ok {
+				return true
+			}
+			return o.isPure(exp.Operand)
+		}
+	case *ast.BinaryExpression:
+		switch exp.Operator {
+		case token.LOGICAL_AND, token.LOGICAL_OR, token.STRICT_EQUAL, token.STRICT_NOT_EQUAL:
+			return o.isPure(exp.Left) && o.isPure(exp.Right)
+		case token.IN, token.INSTANCEOF:
+			return false
+		}
+		return isPrimitive(exp.Left) && isPrimitive(exp.Right)
+	case *ast.ConditionalExpression:
+		return o.isPure(exp.Test) && o.isPure(exp.Consequent) && o.isPure(exp.Alternate)
+	case *ast.SequenceExpression:
+		for _, value := range exp.Sequence {
+			if !o.isPure(value) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// isConsoleCall reports whether exp calls a method of the global console.
+func (o *optimizer) isConsoleCall(exp ast.Expression) bool {
+	call, ok := exp.(*ast.CallExpression)
+	if !ok {
+		return false
+	}
+	var object ast.Expression
+	switch callee := call.Callee.(type) {
+	case *ast.DotExpression:
+		object = callee.Left
+	case *ast.BracketExpression:
+		object = callee.Left
+	}
+	ident, ok := object.(*ast.Identifier)
+	return ok && ident.Name == "console" && o.free[&ident.Name]
+}
+
+// removeEmptyBranches removes the branches of an if statement that do
+// nothing, keeping the test if it may have side effects.
+func (o *optimizer) removeEmptyBranches(stmt *ast.IfStatement) ast.Statement {
+	if stmt.Alternate != nil && isEmptyStatement(stmt.Alternate) {
+		stmt.Alternate = nil
+	}
+	if !isEmptyStatement(stmt.Consequent) {
+		return stmt
+	}
+	if stmt.Alternate == nil {
+		if o.isPure(stmt.Test) {
+			return &ast.EmptyStatement{Semicolon: stmt.If}
+		}
+		return &ast.ExpressionStatement{Expression: stmt.Test}
+	}
+	test, ok := negate(stmt.Test)
+	if !ok {
+		return stmt
+	}
+	stmt.Test = test
+	stmt.Consequent, stmt.Alternate = stmt.Alternate, nil
+	return stmt
+}
+
+// negate returns the negation of the test of an if statement. Only tests
+// that need no parentheses after ! are negated.
+func negate(exp ast.Expression) (ast.Expression, bool) {
+	switch e := exp.(type) {
+	case *ast.UnaryExpression:
+		if e.Operator == token.NOT {
+			// the test is only used as a boolean
+			return e.Operand, true
+		}
+	case *ast.BinaryExpression:
+		// binary expressions are printed in parentheses
+	default:
+		if precedence(exp) < precPrefix {
+			return nil, false
+		}
+	}
+	return &ast.UnaryExpression{Operator: token.NOT, Idx: exp.Idx0(), Operand: exp}, true
+}
+
+func isEmptyStatement(stmt ast.Statement) bool {
+	switch stmt := stmt.(type) {
+	case *ast.EmptyStatement:
+		return true
+	case *ast.BlockStatement:
+		return len(stmt.List) == 0
+	}
+	return false
+}
+
+func newUndefined(idx file.Idx) ast.Expression {
+	return &ast.UnaryExpression{Operator: token.VOID, Idx: idx, Operand: &ast.NumberLiteral{Idx: idx, Literal: "0", Value: int64(0)}}
+}
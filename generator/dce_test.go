@@ -0,0 +1,68 @@
+package generator
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadDeadCode(t *testing.T) {
+	load := func(src string, options Options) string {
+		options.Minify = true
+		out, err := LoadWithOptions(strings.NewReader(src), options)
+		assert.NoError(t, err)
+		code, err := ioutil.ReadAll(out)
+		assert.NoError(t, err)
+		return string(code)
+	}
+
+	for src, optimized := range map[string]string{
+		// unreachable statements, keeping hoisted declarations
+		"function f() { return g(b); a(); var b = 1; function g() {} }": "function f(){return g(b);var b;function g(){}}",
+		"function f() { for (;;) { break; a(); } throw e; b(); }":       "function f(){for(;;){break}throw e}",
+		"function f(x) { switch (x) { case 1: return; a(); case 2: } }": "function f(x){switch(x){case 1:return;case 2:}}",
+
+		// unused declarations with pure initializers, until none are left
+		"function f() { var a = 1, b = c(), d = { x: [a, typeof q] }; return 2; }":    "function f(){var b=c();return 2}",
+		"function f() { function g() { return h(); } function h() {} return 1; }":     "function f(){return 1}",
+		"function f(x) { var a = x; return function () { return a; }; }":              "function f(x){var a=x;return function(){return a}}",
+		"function f(x) { var pure = 1 + 2, b = -x * (x < 3), c = x in o; return 1; }": "function f(x){var b=-x*(x<3),c=x in o;return 1}",
+
+		// top level names may be used by other scripts
+		"var a = 1; function g() {}": "function g(){}var a=1",
+
+		// empty blocks and branches
+		"function f(x) { {} if (x) {} if (x()) {} else {} if (x) {} else { a(); } if (a < b) {} else { c(); } }": "function f(x){x();if(!x){a()}if(!(a<b)){c()}}",
+		"function f(x) { if (!x) {} else { a(); } while (x) {} }":                                                "function f(x){if(x){a()}while(x){}}",
+
+		// eval may use any name
+		"function f() { var a = 1; return eval('a'); }": "function f(){var a=1;return eval('a')}",
+	} {
+		assert.Equal(t, optimized, load(src, Options{DeadCode: true}), src)
+	}
+
+	// console calls and debugger statements
+	for src, optimized := range map[string]string{
+		"console.log(1); debugger; a();":                  "a()",
+		"x = console.warn(2) || y;":                       "x=void 0||y",
+		"function f(console) { console.log(1); }":         "function f(console){console.log(1)}",
+		"logger.log(1); console.log = f; console.log(1);": "logger.log(1);console.log=f",
+	} {
+		assert.Equal(t, optimized, load(src, Options{DropDebugging: true}), src)
+	}
+}
+
+func TestBundleDeadCode(t *testing.T) {
+	result, err := Build("./testdata/dce/index.js", Config{DeadCode: true, DropDebugging: true})
+	assert.NoError(t, err)
+	code := string(result.Code)
+	assert.NotContains(t, code, "unused")
+	assert.NotContains(t, code, "legacy")
+	assert.NotContains(t, code, "console")
+	assert.NotContains(t, code, "debugger")
+	assert.NotContains(t, code, "return value;")
+	assert.Contains(t, code, "function format(value)")
+	assert.Contains(t, code, "function repeat(s, n)")
+}
@@ -10,8 +10,42 @@ import (
 )
 
 func (g *generator) generateExpression(exp ast.Expression) error {
-	g.descentExpression()
-	defer g.ascentExpression()
+	// exp is parenthesized only if it binds looser than required by its
+	// position
+	wrap := g.nativePrecedence(exp) < g.precedence
+	g.precedence = precLowest
+	if exp == nil {
+		return nil
+	}
+	if err := g.checkFormat(exp); err != nil {
+		return err
+	}
+	g.writeInlineComments(exp)
+	if wrap {
+		g.write("(")
+	}
+	if err := g.expression(exp); err != nil {
+		return err
+	}
+	if wrap {
+		g.write(")")
+	}
+	g.deferTrailingComments(exp)
+	return nil
+}
+
+// subExpression generates exp at a position that requires an expression
+// binding at least as tight as prec.
+func (g *generator) subExpression(exp ast.Expression, prec int) error {
+	g.precedence = prec
+	return g.generateExpression(exp)
+}
+
+func (g *generator) expression(exp ast.Expression) error {
+	g.mark(exp)
+	if object, optional := optionalChain(exp); optional != nil && !g.supports(es2020) {
+		return g.lowerOptionalChain(exp, object, optional)
+	}
 	switch exp.(type) {
 	case *ast.JSXExpression:
 		return g.jsxExpression(exp.(*ast.JSXExpression))
@@ -22,7 +56,7 @@ func (g *generator) generateExpression(exp ast.Expression) error {
 	case *ast.VariableExpression:
 		return g.variableExpression(exp.(*ast.VariableExpression))
 	case *ast.FunctionLiteral:
-		return g.functionLiteral(exp.(*ast.FunctionLiteral), true)
+		return g.functionLiteral(exp.(*ast.FunctionLiteral), !g.options.Print.KeepLayout)
 	case *ast.ObjectLiteral:
 		return g.objectLiteral(exp.(*ast.ObjectLiteral))
 	case *ast.NumberLiteral:
@@ -38,6 +72,10 @@ func (g *generator) generateExpression(exp ast.Expression) error {
 	case *ast.NullLiteral:
 		return g.nullLiteral(exp.(*ast.NullLiteral))
 	case *ast.Identifier:
+		if g.bundle != nil && exp.(*ast.Identifier).Name == "process" {
+			g.bundle.usesProcess = true
+		}
+		g.usesGlobal(exp)
 		return g.identifier(exp.(*ast.Identifier))
 	case *ast.UnaryExpression:
 		return g.unaryExpression(exp.(*ast.UnaryExpression))
@@ -61,6 +99,12 @@ func (g *generator) generateExpression(exp ast.Expression) error {
 		return g.sequenceExpression(exp.(*ast.SequenceExpression))
 	case *ast.DynamicStringExpression:
 		return g.dynamicStringExpression(exp.(*ast.DynamicStringExpression))
+	case *ast.ClassLiteral:
+		return g.classLiteral(exp.(*ast.ClassLiteral))
+	case *ast.SuperExpression:
+		return g.superExpression(exp.(*ast.SuperExpression))
+	case *ast.AwaitExpression:
+		return g.awaitExpression(exp.(*ast.AwaitExpression))
 	case nil:
 		return nil
 	default:
@@ -80,18 +124,21 @@ func (g *generator) jsxText(jsx *ast.JSXText) error {
 	}
 
 	g.write("\"")
-	g.write(trimmed)
+	g.writeRaw(trimmed)
 	g.write("\"")
 	return nil
 }
 
 func (g *generator) jsxBlockExpression(jsx *ast.JSXBlock) error {
+	if g.options.format {
+		return g.formatJSX(jsx)
+	}
 	g.write("React.createElement(")
 
 	openingElement := jsx.OpeningElement
 	elementName := openingElement.Name.Name
 	if rune(elementName[0]) >= 'a' && rune(elementName[0]) <= 'z' {
-		g.write(escapeKey(elementName))
+		g.write(g.quoted(escapeKey(elementName)))
 	} else {
 		g.write(elementName)
 	}
@@ -118,28 +165,24 @@ func (g *generator) jsxBlockExpression(jsx *ast.JSXBlock) error {
 }
 
 func (g *generator) sequenceExpression(s *ast.SequenceExpression) error {
-	if g.isInExpression() {
-		g.write("(")
-	}
 	for i, e := range s.Sequence {
-		if err := g.generateExpression(e); err != nil {
+		if err := g.subExpression(e, precAssign); err != nil {
 			return err
 		}
 		if i < len(s.Sequence)-1 {
 			g.write(", ")
 		}
 	}
-	if g.isInExpression() {
-		g.write(")")
-	}
-
 	return nil
 }
 
 func (g *generator) bracketExpression(b *ast.BracketExpression) error {
-	if err := g.generateExpression(b.Left); err != nil {
+	if err := g.subExpression(b.Left, precCall); err != nil {
 		return err
 	}
+	if b.Optional {
+		g.write("?.")
+	}
 	g.write("[")
 	if err := g.generateExpression(b.Member); err != nil {
 		return err
@@ -149,40 +192,50 @@ func (g *generator) bracketExpression(b *ast.BracketExpression) error {
 }
 
 func (g *generator) thisExpression(t *ast.ThisExpression) error {
+	if name, ok := g.captured[t]; ok {
+		g.write(name)
+		return nil
+	}
 	g.write("this")
 	return nil
 }
 
 func (g *generator) newExpression(n *ast.NewExpression) error {
 	g.write("new ")
-	if err := g.generateExpression(n.Callee); err != nil {
+	prec := precMember
+	if hasCall(n.Callee) {
+		prec = precPrimary
+	}
+	if err := g.subExpression(n.Callee, prec); err != nil {
 		return err
 	}
 	return g.argumentList(n.ArgumentList)
 }
 
 func (g *generator) conditionalExpression(c *ast.ConditionalExpression) error {
-	if err := g.generateExpression(c.Test); err != nil {
+	if err := g.subExpression(c.Test, precLogicalOr); err != nil {
 		return err
 	}
 	g.write(" ? ")
-	if err := g.generateExpression(c.Consequent); err != nil {
+	if err := g.subExpression(c.Consequent, precAssign); err != nil {
 		return err
 	}
 	g.write(" : ")
-	if err := g.generateExpression(c.Alternate); err != nil {
+	if err := g.subExpression(c.Alternate, precAssign); err != nil {
 		return err
 	}
 	return nil
 }
 
 func (g *generator) assignExpression(a *ast.AssignExpression) error {
-	if g.isInExpression() && !g.isInInitializer {
-		g.write("(")
-	}
-	if err := g.generateExpression(a.Left); err != nil {
+	if err := g.subExpression(a.Left, precCall); err != nil {
 		return err
 	}
+	if a.Operator == token.EXPONENT && !g.supports(es2016) {
+		// a **= b is a = Math.pow(a, b), which evaluates a twice
+		g.write(" = ")
+		return g.exponentiation(a.Left, a.Right)
+	}
 
 	op := ""
 	if a.Operator != token.ASSIGN {
@@ -191,22 +244,24 @@ func (g *generator) assignExpression(a *ast.AssignExpression) error {
 	op += token.ASSIGN.String()
 	g.write(" " + op + " ")
 
-	if err := g.generateExpression(a.Right); err != nil {
-		return err
-	}
-	if g.isInExpression() && !g.isInInitializer {
-		g.write(")")
-	}
-
-	return nil
+	return g.subExpression(a.Right, precAssign)
 }
 
 func (g *generator) dotExpression(d *ast.DotExpression) error {
-	if err := g.generateExpression(d.Left); err != nil {
+	g.usesGlobal(d)
+	if err := g.subExpression(d.Left, precCall); err != nil {
 		return err
 	}
+	if n, ok := d.Left.(*ast.NumberLiteral); ok && isInteger(g.number(n)) {
+		// "1.x" would be read as a number
+		g.writeRaw(" ")
+	}
 
-	g.write(".")
+	if d.Optional {
+		g.write("?.")
+	} else {
+		g.write(".")
+	}
 
 	return g.identifier(d.Identifier)
 }
@@ -223,38 +278,57 @@ func (g *generator) callExpression(c *ast.CallExpression) error {
 				return fmt.Errorf("Expected string argument in call to require")
 			}
 
-			g.write("require('")
-			modulePath, err := g.bundle.resolveModule(requireStr.Value, g.filePath)
-			if err == nil {
-				g.write(modulePath)
-			} else {
-				g.write(requireStr.Value)
+			modulePath, err := g.bundle.resolveModule(requireStr.Value, g.filePath, kindRequire)
+			if err != nil {
+				return err
 			}
-			g.write("')")
+			g.write("require(")
+			g.writeRaw(g.quoted("'" + modulePath + "'"))
+			g.write(")")
 			return nil
 		}
 	}
 
-	g.isCalleeExpression = true
-	if err := g.generateExpression(c.Callee); err != nil {
+	if callsSuper(c) && !g.supports(es2015) {
+		return g.superCall(c)
+	}
+	if err := g.subExpression(c.Callee, precCall); err != nil {
 		return err
 	}
+	if c.Optional {
+		g.write("?.")
+	}
 	return g.argumentList(c.ArgumentList)
 }
 
 func (g *generator) binaryExpression(b *ast.BinaryExpression) error {
-	g.write("(")
-	if err := g.generateExpression(b.Left); err != nil {
+	if b.Operator == token.EXPONENT {
+		return g.exponentiation(b.Left, b.Right)
+	}
+	if b.Operator == token.NULLISH_COALESCING && !g.supports(es2020) {
+		return g.nullishCoalescing(b)
+	}
+	prec := precedence(b)
+	// ?? can't be mixed with || and && without parentheses
+	left, right := prec, prec+1
+	if mixesNullish(b.Operator, b.Left) {
+		left = precPrimary + 1
+	}
+	if mixesNullish(b.Operator, b.Right) {
+		right = precPrimary + 1
+	}
+	if err := g.subExpression(b.Left, left); err != nil {
 		return err
 	}
 
 	g.write(" " + b.Operator.String() + " ")
 
-	if err := g.generateExpression(b.Right); err != nil {
-		return err
-	}
-	g.write(")")
-	return nil
+	return g.subExpression(b.Right, right)
+}
+
+func (g *generator) awaitExpression(a *ast.AwaitExpression) error {
+	g.write("await ")
+	return g.subExpression(a.Argument, precPrefix)
 }
 
 func (g *generator) unaryExpression(u *ast.UnaryExpression) error {
@@ -265,7 +339,12 @@ func (g *generator) unaryExpression(u *ast.UnaryExpression) error {
 		}
 	}
 
-	if err := g.generateExpression(u.Operand); err != nil {
+	prec := precPrefix
+	if u.Postfix {
+		// only references can be incremented
+		prec = precCall
+	}
+	if err := g.subExpression(u.Operand, prec); err != nil {
 		return err
 	}
 
@@ -277,6 +356,10 @@ func (g *generator) unaryExpression(u *ast.UnaryExpression) error {
 }
 
 func (g *generator) identifier(i *ast.Identifier) error {
+	if name, ok := g.captured[i]; ok {
+		g.write(name)
+		return nil
+	}
 	g.write(i.Name)
 	return nil
 }
@@ -287,7 +370,7 @@ func (g *generator) nullLiteral(n *ast.NullLiteral) error {
 }
 
 func (g *generator) regExpLiteral(r *ast.RegExpLiteral) error {
-	g.write("(" + r.Literal + ")")
+	g.writeRaw(r.Literal)
 	return nil
 }
 
@@ -297,48 +380,121 @@ func (g *generator) booleanLiteral(b *ast.BooleanLiteral) error {
 }
 
 func (g *generator) arrayLiteral(a *ast.ArrayLiteral) error {
-	g.write("[")
-	for i, e := range a.Value {
-		if err := g.generateExpression(e); err != nil {
-			return err
-		}
-		if i < len(a.Value)-1 {
-			g.write(", ")
-		}
-	}
-	g.write("]")
-	return nil
+	return g.list("[", "]", a.Value, a, g.options.Print.TrailingCommas)
 }
 
 func (g *generator) stringLiteral(s *ast.StringLiteral) error {
-	g.write(s.Literal)
+	g.writeRaw(g.stringCode(s))
 	return nil
 }
 
 func (g *generator) numberLiteral(n *ast.NumberLiteral) error {
-	g.write(n.Literal)
+	g.writeRaw(g.number(n))
 	return nil
 }
 
+// number returns the literal n is written as.
+func (g *generator) number(n *ast.NumberLiteral) string {
+	if g.options.Minify {
+		return shortNumber(n)
+	}
+	return n.Literal
+}
+
 func (g *generator) property(p ast.Property) error {
+	if fn, ok := p.Value.(*ast.FunctionLiteral); ok && (p.Kind == "get" || p.Kind == "set") {
+		return g.accessor(p.Kind, p.Key, p.Computed, fn)
+	}
+	if p.Computed != nil {
+		g.writeOwnLineComments(g.takeComments(p.Value, isKeyComment))
+		if err := g.computedKey(p.Computed, !g.options.Minify); err != nil {
+			return err
+		}
+		g.write(": ")
+		return g.subExpression(p.Value, precAssign)
+	}
+	if g.isShorthand(p) {
+		g.writeOwnLineComments(g.takeComments(p.Value, isKeyComment))
+		g.mark(p.Value)
+		if g.options.Minify {
+			g.write(p.Key)
+		} else {
+			g.writeIndentation(p.Key)
+		}
+		return nil
+	}
 	if len(p.Key) > 0 {
-		key := escapeKeyIfRequired(p.Key)
+		g.writeOwnLineComments(g.takeComments(p.Value, isKeyComment))
+		key := g.key(p.Key)
 
-		g.writeIndentation(key)
+		if g.options.Minify {
+			g.writeRaw(key)
+		} else {
+			g.writeIndentation(key)
+		}
 		g.write(": ")
+	} else if p.Kind == "spread" && g.supports(es2018) {
+		if g.options.Minify {
+			g.write("...")
+		} else {
+			g.writeIndentation("...")
+		}
 	}
-	return g.generateExpression(p.Value)
+	return g.subExpression(p.Value, precAssign)
+}
+
+// accessor generates the getter or setter of a property, eg. get size() {}.
+// The key is computed if computed is not nil.
+func (g *generator) accessor(kind, key string, computed ast.Expression, fn *ast.FunctionLiteral) error {
+	g.writeOwnLineComments(g.takeComments(fn, isKeyComment))
+	if g.options.Minify {
+		g.write(kind)
+	} else {
+		g.writeIndentation(kind)
+	}
+	g.write(" ")
+	if computed != nil {
+		if err := g.computedKey(computed, false); err != nil {
+			return err
+		}
+	} else {
+		g.writeRaw(g.key(key))
+	}
+	if err := g.parameterList(fn); err != nil {
+		return err
+	}
+	g.write(" ")
+	return g.generateStatement(fn.Body, fn.DeclarationList)
+}
+
+// computedKey generates the computed key of a property or method, eg. [a],
+// indented if indent is set.
+func (g *generator) computedKey(key ast.Expression, indent bool) error {
+	if indent {
+		g.writeIndentation("[")
+	} else {
+		g.write("[")
+	}
+	if err := g.subExpression(key, precAssign); err != nil {
+		return err
+	}
+	g.write("]")
+	return nil
 }
 
 func (g *generator) objectLiteral(o *ast.ObjectLiteral) error {
+	if !g.supports(es2015) && hasComputedKey(o) {
+		return g.definedProperties(o)
+	}
 	spread := false
 	for _, p := range o.Value {
-		if p.Kind == "spread" {
+		if p.Kind == "spread" && !g.supports(es2018) {
 			spread = true
 		}
 	}
 
 	if spread {
+		g.usesBuiltin("Object.assign")
 		g.write("Object.assign({}, ")
 		objectOpen := false
 		for i, p := range o.Value {
@@ -374,35 +530,41 @@ func (g *generator) objectLiteral(o *ast.ObjectLiteral) error {
 			if err := g.property(p); err != nil {
 				return err
 			}
-			if i < len(o.Value)-1 {
+			if i < len(o.Value)-1 || g.options.Print.TrailingCommas && !g.options.Minify {
 				g.write(",")
 			}
+			if i < len(o.Value)-1 {
+				g.writeLineEndComments(o.Value[i+1].Value, isKeyComment)
+			}
 			g.write("\n")
 		}
+		g.writeFinalComments(o)
 		g.indentLevel--
 		g.writeAlone("}")
 	}
 	return nil
 }
 
+// functionLiteral generates f. Named functions start a new line if newline
+// is set.
 func (g *generator) functionLiteral(f *ast.FunctionLiteral, newline bool) error {
-	isAnonymous := f.Name == nil
-
-	if isAnonymous {
-		g.write("(function ")
-		g.isCalleeExpression = false
-		defer g.write(")")
-	} else {
-		if newline {
-			g.writeLine("")
-		}
-		g.write("function ")
+	if f.Arrow && g.supports(es2015) {
+		return g.arrowFunction(f)
+	}
+	if f.Name != nil && newline {
+		g.writeLine("")
+	}
+	if err := g.async(f); err != nil {
+		return err
+	}
+	g.write("function ")
+	if f.Name != nil {
 		if err := g.generateExpression(f.Name); err != nil {
 			return err
 		}
 	}
 
-	if err := g.parameterList(f.ParameterList); err != nil {
+	if err := g.parameterList(f); err != nil {
 		return err
 	}
 	g.write(" ")
@@ -416,7 +578,7 @@ func (g *generator) variableExpression(v *ast.VariableExpression) error {
 		g.write(" = ")
 	}
 
-	if err := g.generateExpression(v.Initializer); err != nil {
+	if err := g.subExpression(v.Initializer, precAssign); err != nil {
 		return err
 	}
 
@@ -424,11 +586,18 @@ func (g *generator) variableExpression(v *ast.VariableExpression) error {
 }
 
 func (g *generator) dynamicStringExpression(d *ast.DynamicStringExpression) error {
+	if g.supports(es2015) && hasTemplateSource(d) {
+		return g.templateString(d)
+	}
 	if len(d.List) == 0 {
 		g.write("''")
 	} else {
 		for i, e := range d.List {
-			if err := g.generateExpression(e); err != nil {
+			prec := precAdditive
+			if i > 0 {
+				prec++
+			}
+			if err := g.subExpression(e, prec); err != nil {
 				return err
 			}
 			if i < len(d.List)-1 {
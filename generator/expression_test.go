@@ -0,0 +1,143 @@
+package generator
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/walesey/go-bundle/ast"
+	"github.com/walesey/go-bundle/file"
+	"github.com/walesey/go-bundle/parser"
+	"github.com/walesey/go-bundle/token"
+)
+
+// parseBody parses src and returns its statements without positions and
+// empty statements, so trees parsed from different code can be compared.
+func parseBody(t *testing.T, src string) []ast.Statement {
+	p, err := parser.ParseFile(nil, "", src, 0)
+	if !assert.NoError(t, err, src) {
+		return nil
+	}
+	var body []ast.Statement
+	for _, stmt := range p.Body {
+		if _, ok := stmt.(*ast.EmptyStatement); !ok {
+			body = append(body, stmt)
+		}
+	}
+	clearPositions(reflect.ValueOf(body))
+	return body
+}
+
+var idxType = reflect.TypeOf(file.Idx(0))
+
+func clearPositions(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			clearPositions(v.Elem())
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			clearPositions(v.Index(i))
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Field(i)
+			switch {
+			case f.Type() == idxType:
+				f.SetInt(0)
+			case v.Type().Field(i).Name == "Source" && f.Kind() == reflect.String:
+				f.SetString("")
+			default:
+				clearPositions(f)
+			}
+		}
+	}
+}
+
+func generateBody(t *testing.T, body []ast.Statement, options Options) string {
+	g, err := generate(&ast.Program{Body: body}, "", nil, options)
+	assert.NoError(t, err)
+	return g.buffer.String()
+}
+
+func TestPrecedence(t *testing.T) {
+	a := &ast.Identifier{Name: "a"}
+	b := &ast.Identifier{Name: "b"}
+	x := &ast.Identifier{Name: "x"}
+	call := func(callee ast.Expression) *ast.CallExpression {
+		return &ast.CallExpression{Callee: callee}
+	}
+	fn := &ast.FunctionLiteral{
+		ParameterList: &ast.ParameterList{},
+		Body:          &ast.BlockStatement{},
+	}
+
+	// trees that are not written by the parser
+	for exp, code := range map[ast.Expression]string{
+		&ast.DotExpression{Left: &ast.SequenceExpression{Sequence: []ast.Expression{a, b}}, Identifier: &ast.Identifier{Name: "c"}}: "(a, b).c",
+		&ast.NewExpression{Callee: call(&ast.Identifier{Name: "f"})}:                                                                "new (f())()",
+		&ast.UnaryExpression{Operator: token.MINUS, Operand: &ast.UnaryExpression{Operator: token.MINUS, Operand: x}}:               "- -x",
+		&ast.UnaryExpression{Operator: token.MINUS, Operand: &ast.UnaryExpression{Operator: token.DECREMENT, Operand: x}}:           "- --x",
+		call(fn): "(function () {\n}());",
+		&ast.BinaryExpression{Operator: token.MINUS, Left: a, Right: &ast.BinaryExpression{Operator: token.MINUS, Left: b, Right: x}}: "a - (b - x)",
+	} {
+		body := []ast.Statement{&ast.ExpressionStatement{Expression: exp}}
+		generated := generateBody(t, body, Options{})
+		assert.Equal(t, strings.TrimSuffix(code, ";")+";", generated)
+		clearPositions(reflect.ValueOf(body))
+		assert.Equal(t, body, parseBody(t, generated), code)
+	}
+
+	// parsed code generates code that parses to the same tree, with only the
+	// parentheses that are required
+	for _, src := range []string{
+		"a = (b, c);",
+		"x = a ? b : c ? d : e;",
+		"x = (a ? b : c) ? d : e;",
+		"x = (a = b) ? c : d;",
+		"x = a || b && c;",
+		"x = (a || b) && c;",
+		"x = a * (b + c) - d / e % f;",
+		"x = a < b == c > d;",
+		"x = typeof (a + b) + typeof a;",
+		"x = !(a instanceof b);",
+		"x = -(-a) + +(+a) - -a;",
+		"x = (a, b);",
+		"f((a, b), c);",
+		"x = [(a, b), c];",
+		"x = { a: (b, c) };",
+		"new a.b();",
+		"new (a().b)();",
+		"new (a.b())();",
+		"(new a()).b;",
+		"(a || b).c();",
+		"(a = b).c;",
+		"(function () {})();",
+		"(function () {}).call(this);",
+		"({ a: 1 }).a;",
+		"x = function () {};",
+		"(1).toString();",
+		"f(1.5.toString(), 1 .x);",
+		"f(/a/.test(b) / 2);",
+		"for (var i = (a in b); i;) {}",
+		"for (x = (a in b) ? c : d; i;) {}",
+		"for (var k in a) {}",
+	} {
+		expected := parseBody(t, src)
+		for _, minify := range []bool{false, true} {
+			generated := generateBody(t, parseBody(t, src), Options{Minify: minify})
+			assert.Equal(t, expected, parseBody(t, generated), src+" -> "+generated)
+		}
+	}
+}
+
+func TestLoadParentheses(t *testing.T) {
+	out, err := Load(strings.NewReader("x = (a + b) * c; y = (a * b) + c; f((function () {}));"))
+	assert.NoError(t, err)
+	code, err := ioutil.ReadAll(out)
+	assert.NoError(t, err)
+	assert.Equal(t, "x = (a + b) * c;\ny = a * b + c;\nf(function () {\n});", string(code))
+}
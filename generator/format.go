@@ -0,0 +1,159 @@
+package generator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/walesey/go-bundle/ast"
+	"github.com/walesey/go-bundle/file"
+	"github.com/walesey/go-bundle/parser"
+)
+
+// Format returns the JavaScript src laid out with the print options.
+// Comments, the blank lines between statements and the place of function
+// declarations are kept, and so is modern syntax, like arrow functions,
+// template strings, destructuring, import and export statements and JSX,
+// which is written as it is in src.
+func Format(src []byte, filename string, print PrintOptions) ([]byte, error) {
+	prog, err := parser.ParseFile(nil, filename, src, parser.StoreComments|parser.IgnoreRegExpErrors)
+	if err != nil {
+		return nil, err
+	}
+
+	print.KeepLayout = true
+	options := Options{Comments: CommentsAll, Print: print, Target: TargetESNext, format: true}
+	gen, err := generate(prog, filename, nil, options)
+	if err != nil {
+		return nil, err
+	}
+	code := strings.TrimLeft(gen.buffer.String(), "\n")
+	if code == "" {
+		return nil, nil
+	}
+	return []byte(code + "\n"), nil
+}
+
+// checkFormat returns an error if the generator would rewrite the syntax of
+// node when formatting.
+func (g *generator) checkFormat(node ast.Node) error {
+	if !g.options.format {
+		return nil
+	}
+	if d, ok := node.(*ast.DynamicStringExpression); ok && !hasTemplateSource(d) {
+		line, column := g.position(d.Idx0())
+		return fmt.Errorf("Cannot format %v:%v:%v: a template string is not supported", g.filePath, line, column)
+	}
+	return nil
+}
+
+// formatImport generates i as an import statement, for Format.
+func (g *generator) formatImport(i *ast.ImportStatement) error {
+	g.writeLine("import ")
+	var names []string
+	if i.Default != nil {
+		names = append(names, i.Default.Name)
+	}
+	if i.All != nil {
+		names = append(names, "* as "+i.All.Name)
+	}
+	if i.List != nil {
+		var list []string
+		for _, ident := range i.List {
+			if ident.As.Name == ident.Name.Name {
+				list = append(list, ident.Name.Name)
+			} else {
+				list = append(list, ident.Name.Name+" as "+ident.As.Name)
+			}
+		}
+		names = append(names, "{ "+strings.Join(list, ", ")+" }")
+	}
+	if len(names) > 0 {
+		g.write(strings.Join(names, ", ") + " from ")
+	}
+	if err := g.stringLiteral(i.Path); err != nil {
+		return err
+	}
+	g.endStatement()
+	return nil
+}
+
+// formatExport generates e as an export statement, for Format.
+func (g *generator) formatExport(e *ast.ExportStatement) error {
+	g.writeLine("export ")
+	g.continueLine = true
+	return g.generateStatement(e.Statement, nil)
+}
+
+// formatExportDefault generates e as an export default statement, for
+// Format.
+func (g *generator) formatExportDefault(e *ast.ExportDefaultStatement) error {
+	g.writeLine("export default ")
+	if err := g.subExpression(e.Argument, precAssign); err != nil {
+		return err
+	}
+	if _, ok := e.Argument.(*ast.FunctionLiteral); !ok {
+		g.endStatement()
+	}
+	return nil
+}
+
+// formatJSX writes the source of jsx, for Format.
+func (g *generator) formatJSX(jsx *ast.JSXBlock) error {
+	end := jsx.OpeningElement.RightTag
+	if !jsx.OpeningElement.SelfClosing {
+		if jsx.ClosingElement == nil {
+			line, column := g.position(jsx.Idx0())
+			return fmt.Errorf("Cannot format %v:%v:%v: JSX element is not closed", g.filePath, line, column)
+		}
+		end = jsx.ClosingElement.RightTag
+	}
+	// the opening element starts after the <
+	start := strings.LastIndex(g.source[:int(jsx.Idx0())-g.base], "<")
+	g.writeSource(start, int(end)-g.base+1)
+	return nil
+}
+
+// writeSource writes the source from offset start to end as it is. The
+// lines after the first are moved from the indentation of the first line
+// to the current indentation.
+func (g *generator) writeSource(start, end int) {
+	lineStart := strings.LastIndex(g.source[:start], "\n") + 1
+	line := g.source[lineStart:start]
+	indentation := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+	lines := strings.Split(g.source[start:end], "\n")
+	for i := 1; i < len(lines); i++ {
+		if strings.HasPrefix(lines[i], indentation) {
+			lines[i] = g.indentationString() + lines[i][len(indentation):]
+		}
+	}
+	g.writeRaw(strings.Join(lines, "\n"))
+}
+
+// position returns the line and column of idx in the source.
+func (g *generator) position(idx file.Idx) (line, column int) {
+	offset := int(idx) - g.base
+	if offset < 0 || offset > len(g.source) {
+		return 0, 0
+	}
+	before := g.source[:offset]
+	return strings.Count(before, "\n") + 1, offset - strings.LastIndex(before, "\n")
+}
+
+var blankLine = regexp.MustCompile(`\n[ \t\r]*\n`)
+
+// blankLineBetween reports whether the source has a blank line between the
+// statements prev and next, which is kept for KeepLayout.
+func (g *generator) blankLineBetween(prev, next ast.Statement) bool {
+	if !g.options.Print.KeepLayout || g.source == "" {
+		return false
+	}
+	if _, ok := next.(*ast.EmptyStatement); ok {
+		return false
+	}
+	start, end := int(prev.Idx1())-g.base, int(next.Idx0())-g.base
+	if start < 0 || start > end || end > len(g.source) {
+		return false
+	}
+	return blankLine.MatchString(g.source[start:end])
+}
@@ -0,0 +1,158 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat(t *testing.T) {
+	src := `/* header */
+'use strict';
+var a = 1,
+    b = 2;
+
+
+// helpers
+function add(x, y) {
+  return x + y; // sum
+}
+if (a) b(); else c();
+
+var o = { get size() { return 1; } };
+function later() {
+  var c = a;
+
+  return c;
+}
+`
+	formatted, err := Format([]byte(src), "src.js", PrintOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, `/* header */
+'use strict';
+var a = 1,
+  b = 2;
+
+// helpers
+function add(x, y) {
+  return x + y; // sum
+}
+if (a)
+  b();
+else
+  c();
+
+var o = {
+  get size() {
+    return 1;
+  }
+};
+function later() {
+  var c = a;
+
+  return c;
+}
+`, string(formatted))
+
+	again, err := Format(formatted, "src.js", PrintOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, string(formatted), string(again))
+
+	// code without semicolons is read back
+	as := PrintOptions{Semicolons: SemicolonsAsNeeded, Tabs: true}
+	formatted, err = Format([]byte("switch (a) {\ncase 0:\ncase 1: b(); break;\n\ndefault: c(); }\n"), "src.js", as)
+	assert.NoError(t, err)
+	assert.Equal(t, "switch (a) {\n\tcase 0:\n\tcase 1:\n\t\tb()\n\t\tbreak\n\n\tdefault:\n\t\tc()\n}\n", string(formatted))
+	again, err = Format(formatted, "src.js", as)
+	assert.NoError(t, err)
+	assert.Equal(t, string(formatted), string(again))
+
+	formatted, err = Format([]byte("\n"), "empty.js", PrintOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, formatted)
+}
+
+func TestFormatModernSyntax(t *testing.T) {
+	src := "const add = (a, b) => a + b;\nlet o = { ...base, f: x => ({ x }) };\nvar s = `it's ${add(1, 2)}\n  and more`;\n"
+	formatted, err := Format([]byte(src), "src.js", PrintOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "const add = (a, b) => a + b;\nlet o = {\n  ...base,\n  f: x => ({\n    x\n  })\n};\nvar s = `it's ${add(1, 2)}\n  and more`;\n", string(formatted))
+}
+
+func TestFormatModules(t *testing.T) {
+	src := `import a, { b, c as d } from 'bc';
+import * as e from 'e';
+import 'side';
+
+export var f = { a, b: d };
+export function h(x) {
+  const { p, q } = x;
+  return <div className="h">{p}<br/></div>;
+}
+export default h;
+`
+	formatted, err := Format([]byte(src), "src.js", PrintOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, `import a, { b, c as d } from 'bc';
+import * as e from 'e';
+import 'side';
+
+export var f = {
+  a,
+  b: d
+};
+export function h(x) {
+  const { p, q } = x;
+  return <div className="h">{p}<br/></div>;
+}
+export default h;
+`, string(formatted))
+}
+
+func TestFormatTrailingComments(t *testing.T) {
+	src := `var a = 1; // trailing a
+var o = {
+  k: 1, // trailing k
+  m: 2 /* trailing m */
+};
+function f() {
+  g(); // call g
+
+  // own line
+  return /* why */ 1;
+}
+`
+	formatted, err := Format([]byte(src), "src.js", PrintOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, src, string(formatted))
+}
+
+func TestFormatKeepsComments(t *testing.T) {
+	src := `/*! license */
+// about a
+import a from 'a'; // after a
+const {b, c} = a; // after b
+const {d} = a // without semicolon
+var e = 1; // before f
+function f() {
+  let {g} = e; /* block */
+  return g; // after g
+}
+`
+	comments := []string{"/*! license */", "// about a", "// after a", "// after b", "// without semicolon", "// before f", "/* block */", "// after g"}
+	for _, print := range []PrintOptions{{}, {Semicolons: SemicolonsAsNeeded}} {
+		// formatting the formatted code again keeps the comments, and
+		// changes nothing
+		formatted, err := Format([]byte(src), "src.js", print)
+		assert.NoError(t, err)
+		again, err := Format(formatted, "src.js", print)
+		assert.NoError(t, err)
+		assert.Equal(t, string(formatted), string(again))
+		for _, comment := range comments {
+			assert.Contains(t, string(again), comment)
+		}
+	}
+	formatted, err := Format([]byte(src), "src.js", PrintOptions{})
+	assert.NoError(t, err)
+	assert.Contains(t, string(formatted), "const { b, c } = a; // after b\nconst { d } = a; // without semicolon\n")
+}
@@ -0,0 +1,128 @@
+package generator
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// fileSystem is used for all access to the files that are bundled. Paths
+// passed to it are absolute, except for abs.
+type fileSystem interface {
+	abs(path string) (string, error)
+	readFile(path string) ([]byte, error)
+	stat(path string) (fs.FileInfo, error)
+	evalSymlinks(path string) (string, error)
+}
+
+// osFS is the operating system's file system.
+type osFS struct{}
+
+func (osFS) abs(path string) (string, error) {
+	return filepath.Abs(path)
+}
+
+func (osFS) readFile(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+func (osFS) stat(path string) (fs.FileInfo, error) {
+	return os.Stat(path)
+}
+
+func (osFS) evalSymlinks(path string) (string, error) {
+	return filepath.EvalSymlinks(path)
+}
+
+// ioFS is an fs.FS. Its root is "/" in the bundle, and is also the working
+// directory that relative paths are resolved from.
+type ioFS struct {
+	fsys fs.FS
+}
+
+// abs returns the path of name in the bundle. Paths that go above the root
+// do not exist, rather than being read from the root.
+func (f ioFS) abs(name string) (string, error) {
+	name = filepath.ToSlash(name)
+	depth := 0
+	for _, part := range strings.Split(name, "/") {
+		switch part {
+		case "", ".":
+		case "..":
+			if depth--; depth < 0 {
+				return "", &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+			}
+		default:
+			depth++
+		}
+	}
+	return path.Join("/", name), nil
+}
+
+// name converts an absolute path to a name in the fs.FS.
+func (f ioFS) name(abs string) (string, error) {
+	name := strings.TrimPrefix(path.Clean(filepath.ToSlash(abs)), "/")
+	if name == "" {
+		name = "."
+	}
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "open", Path: abs, Err: fs.ErrInvalid}
+	}
+	return name, nil
+}
+
+func (f ioFS) readFile(abs string) ([]byte, error) {
+	name, err := f.name(abs)
+	if err != nil {
+		return nil, err
+	}
+	return fs.ReadFile(f.fsys, name)
+}
+
+func (f ioFS) stat(abs string) (fs.FileInfo, error) {
+	name, err := f.name(abs)
+	if err != nil {
+		return nil, err
+	}
+	return fs.Stat(f.fsys, name)
+}
+
+// evalSymlinks returns path as it is, as links are followed by the fs.FS.
+func (f ioFS) evalSymlinks(abs string) (string, error) {
+	if _, err := f.stat(abs); err != nil {
+		return "", err
+	}
+	return path.Clean(filepath.ToSlash(abs)), nil
+}
+
+func newFileSystem(fsys fs.FS) fileSystem {
+	if fsys == nil {
+		return osFS{}
+	}
+	return ioFS{fsys: fsys}
+}
+
+// isFile reports whether path is a file or a virtual module.
+func (bundle *_bundle) isFile(path string) bool {
+	if _, ok := bundle.virtual[path]; ok {
+		return true
+	}
+	info, err := bundle.fs.stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// isDir reports whether path is a directory or contains a virtual module.
+func (bundle *_bundle) isDir(path string) bool {
+	if info, err := bundle.fs.stat(path); err == nil && info.IsDir() {
+		return true
+	}
+	prefix := path + string(filepath.Separator)
+	for key := range bundle.virtual {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
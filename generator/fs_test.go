@@ -0,0 +1,45 @@
+package generator
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBundleFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"src/index.js":                         {Data: []byte("var pkg = require('pkg');\nvar util = require('components/util');\n")},
+		"src/components/util.js":               {Data: []byte("module.exports = 'util';")},
+		"tsconfig.json":                        {Data: []byte(`{"compilerOptions": {"baseUrl": "./src"}}`)},
+		"node_modules/pkg/package.json":        {Data: []byte(`{"main": "lib/main.js"}`)},
+		"node_modules/pkg/lib/main.js":         {Data: []byte("module.exports = 'pkg';")},
+		"node_modules/pkg/lib/unused/index.js": {Data: []byte("module.exports = 'unused';")},
+	}
+
+	result, err := Build("src/index.js", Config{FS: fsys})
+	assert.NoError(t, err)
+	assert.Contains(t, string(result.Code), "module.exports = 'pkg';")
+	assert.Contains(t, string(result.Code), "module.exports = 'util';")
+	assert.Equal(t, []string{"/node_modules/pkg/lib/main.js", "/src/components/util.js", "/src/index.js"}, result.Dependencies)
+
+	// in-memory edits over the file system
+	result, err = Build("src/index.js", Config{FS: fsys, Virtual: map[string]VirtualModule{
+		"./src/components/util.js": {Contents: "module.exports = 'edited';"},
+	}})
+	assert.NoError(t, err)
+	assert.Contains(t, string(result.Code), "module.exports = 'edited';")
+
+	// files outside of the file system cannot be imported
+	_, err = Build("../index.js", Config{FS: fsys})
+	assert.Error(t, err)
+	_, err = Build("../tsconfig.json", Config{FS: fsys})
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = BuildSource("require('../../tsconfig.json');", "src/index.js", Config{FS: fsys})
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = ioFS{fsys}.abs("src/../../tsconfig.json")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = Build("src/missing.js", Config{FS: fsys})
+	assert.Error(t, err)
+}
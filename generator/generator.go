@@ -2,16 +2,63 @@ package generator
 
 import (
 	"bytes"
-	"fmt"
 	"io"
+	"regexp"
 	"strings"
 	"unicode"
 	"unicode/utf8"
 
 	"github.com/walesey/go-bundle/ast"
+	"github.com/walesey/go-bundle/file"
 	"github.com/walesey/go-bundle/parser"
 )
 
+// Options control the code written by the generator.
+type Options struct {
+	// Comments selects the comments of the source that are kept.
+	Comments CommentMode
+
+	// Minify writes compact code, without optional whitespace, semicolons
+	// and parentheses, and with shorter string and number literals.
+	Minify bool
+
+	// Mangle renames the variables and parameters of functions to short
+	// names. Top level names are kept, as other scripts may use them, and so
+	// are the names of functions that call eval or use with.
+	Mangle bool
+
+	// MangleProperties renames the properties matching the pattern, eg.
+	// "^_", to short names. The same property gets the same name in all
+	// modules of a bundle, so the pattern must only match properties that
+	// are not used by code outside of it.
+	MangleProperties *regexp.Regexp
+
+	// Define replaces global names, eg. "process.env.NODE_ENV" or
+	// "__DEV__", with literals, eg. `"production"` or `false`. The code
+	// that can no longer run, like the branch of if (__DEV__), is removed.
+	Define map[string]string
+
+	// DeadCode removes code that is never run, like statements after
+	// return, and function declarations and variables that are never used.
+	// Variables are only removed if their initializer has no side effects.
+	DeadCode bool
+
+	// DropDebugging removes console.* calls and debugger statements.
+	DropDebugging bool
+
+	// Print controls the layout of the code, like its indentation and
+	// quotes.
+	Print PrintOptions
+
+	// Target is the version of JavaScript the code is written in. Newer
+	// syntax is lowered, eg. arrow functions are written as functions for
+	// TargetES5.
+	Target Target
+
+	// format reports syntax that is rewritten as an error, see Format.
+	format bool
+}
+
 type generator struct {
 	buffer      *bytes.Buffer
 	indentLevel int
@@ -19,58 +66,218 @@ type generator struct {
 	currentLine int
 	currentChar int
 
-	expressionLevel    int
-	isInInitializer    bool
-	isCalleeExpression bool
-	isElseStatement    bool
+	// target is the version of the options' Target
+	target int
+
+	// continueLine is set if the next statement continues the current line,
+	// like after else or a label
+	continueLine bool
+
+	// declared are the function declarations written in place for
+	// KeepLayout
+	declared map[*ast.FunctionLiteral]bool
 
 	filePath string
 	bundle   *_bundle
+	options  Options
+
+	// class is the class whose methods are being lowered, if any
+	class *classContext
+
+	// prologues are the statements written at the start of blocks, like
+	// the assignments of the default parameters of lowered functions
+	prologues map[ast.Node][]ast.Statement
+
+	// captured are the names of the variables that hold the this and
+	// arguments of the functions that define lowered arrow functions, by the
+	// nodes that refer to them
+	captured map[ast.Node]string
+
+	// names are the names of the variables of the program, which the
+	// variables added by lowering must not use, for targets before ES2019
+	names map[string]bool
+
+	// globals are the identifiers that refer to globals, used to find the
+	// built-ins that are polyfilled
+	globals map[*string]bool
+
+	// comment state, comments is nil if no comments are kept
+	comments       ast.CommentMap
+	emitted        map[*ast.Comment]bool
+	trailing       []*ast.Comment
+	newlinePending bool
+	source         string
+	base           int
+
+	// minify state, the precedence required of the next expression and a
+	// semicolon that is only written if more code follows in the block, or
+	// on the same line for SemicolonsAsNeeded, at semicolonAt
+	precedence       int
+	semicolonPending bool
+	semicolonAt      int
+
+	// source map state, sourceLines is nil if no map is generated
+	sourceLines *sourceLines
+	mappings    []mapping
+	pendingIdx  file.Idx
+	column      int
 }
 
 // Load takes an io.Reader to be parsed and
 // generate javascript code.
 func Load(in io.Reader) (io.Reader, error) {
-	prog, err := parser.ParseFile(nil, "<input>", in, parser.IgnoreRegExpErrors&parser.StoreComments)
+	return LoadWithOptions(in, Options{})
+}
+
+// LoadWithOptions is Load with options controlling the generated code.
+func LoadWithOptions(in io.Reader, options Options) (io.Reader, error) {
+	prog, err := parser.ParseFile(nil, "<input>", in, options.parserMode())
 	if err != nil {
 		return nil, err
 	}
 
-	return generate(prog, "<input>", nil)
+	gen, err := generate(prog, "<input>", nil, options)
+	if err != nil {
+		return nil, err
+	}
+	return gen.code(), nil
+}
+
+// parserMode returns the parser mode that reads what the options need.
+func (o Options) parserMode() parser.Mode {
+	mode := parser.IgnoreRegExpErrors
+	if o.Comments != CommentsNone {
+		mode |= parser.StoreComments
+	}
+	return mode
 }
 
-func generate(p *ast.Program, filePath string, bundle *_bundle) (io.Reader, error) {
+func generate(p *ast.Program, filePath string, bundle *_bundle, options Options) (*generator, error) {
 	gen := &generator{
 		buffer:      &bytes.Buffer{},
-		indentation: "  ",
+		declared:    make(map[*ast.FunctionLiteral]bool),
+		indentation: options.Print.indentation(),
 		filePath:    filePath,
 		bundle:      bundle,
+		options:     options,
+	}
+	target, err := options.Target.version()
+	if err != nil {
+		return nil, err
+	}
+	gen.target = target
+	// bundles are verified against the source of their modules
+	mapped := bundle != nil && (bundle.config.SourceMap != SourceMapNone || bundle.config.VerifyTarget)
+	if mapped && p.File != nil {
+		gen.sourceLines = newSourceLines(p.File)
+	}
+	o, err := newOptimizer(options, bundle != nil)
+	if err != nil {
+		return nil, err
+	}
+	if o != nil {
+		o.optimize(p)
+	}
+	if bundle != nil && bundle.polyfills != nil {
+		gen.globals = freeIdentifiers(p)
+	}
+	if options.Comments != CommentsNone && p.File != nil {
+		gen.initComments(p)
+	}
+	if options.Print.KeepLayout && p.File != nil {
+		gen.source, gen.base = p.File.Source(), p.File.Base()
+	}
+	if options.Mangle || options.MangleProperties != nil {
+		var properties *propertyNames
+		if bundle != nil {
+			properties = bundle.properties
+		} else if options.MangleProperties != nil {
+			properties = newPropertyNames(options.MangleProperties)
+		}
+		mangle(p, options.Mangle, properties, gen.supports(es2015))
+	}
+	if !gen.supports(es2019) {
+		gen.names = usedNames(p)
+	}
+	if !gen.supports(es2015) {
+		gen.captureArrowScope(p)
 	}
 
 	if err := gen.generateProgram(p); err != nil {
 		return nil, err
 	}
 
-	return gen.code(), nil
+	return gen, nil
 }
 
 func (g *generator) indentationString() string {
+	if g.options.Minify {
+		return ""
+	}
 	return strings.Repeat(g.indentation, g.indentLevel)
 }
 
+// write writes code. Optional whitespace in s is removed when minifying, so
+// literals that may contain whitespace are written with writeRaw.
 func (g *generator) write(s string) {
+	if g.options.Minify {
+		s = whitespaceRemover.Replace(s)
+	}
+	g.writeRaw(s)
+}
+
+func (g *generator) writeRaw(s string) {
+	if s == "" {
+		return
+	}
+	g.continueLine = false
+	if g.options.Print.ASCIIOnly {
+		s = escapeNonASCII(s)
+	}
+	if g.semicolonPending {
+		s = g.pendingSemicolon(s)
+	}
+	if len(g.trailing) > 0 && s != "" {
+		s = g.writeQueuedComments(s)
+	}
+	if g.newlinePending && s != "" {
+		s = g.startLine(s)
+	}
+	if strings.HasPrefix(s, "\n") && g.options.Print.KeepLayout {
+		g.trimLine()
+	}
+	s = g.separate(s)
+	if g.pendingIdx != 0 {
+		g.addMapping(s)
+	}
 	g.buffer.WriteString(s)
 
 	g.currentLine += strings.Count(s, "\n")
 	if lastIndex := strings.LastIndex(s, "\n"); lastIndex != -1 {
 		g.currentChar = len(s[len("\n")+lastIndex:])
+		g.column = g.currentChar
 	} else {
 		g.currentChar += len(s)
+		g.column += len(s)
 	}
 }
 
+// endStatement writes the semicolon ending a statement.
+func (g *generator) endStatement() {
+	if g.options.Minify || g.options.Print.Semicolons == SemicolonsAsNeeded {
+		g.semicolonPending = true
+		g.semicolonAt = g.buffer.Len()
+		return
+	}
+	g.write(";")
+}
+
 // Ensures that s will be the first statement on a line
 func (g *generator) writeAlone(s string) {
+	if g.options.Minify {
+		g.write(s)
+		return
+	}
 	if g.buffer.Len() <= 0 {
 		return
 	}
@@ -82,7 +289,15 @@ func (g *generator) writeAlone(s string) {
 }
 
 func (g *generator) writeIndentation(s string) {
+	if g.options.Minify {
+		g.write(s)
+		return
+	}
 
+	if g.currentChar == 0 && g.options.Print.Tabs {
+		g.write(g.indentationString() + s)
+		return
+	}
 	if g.currentChar > 0 && g.currentChar%len(g.indentation) == 0 {
 		g.write(s)
 		return
@@ -98,23 +313,80 @@ func (g *generator) writeIndentation(s string) {
 }
 
 func (g *generator) writeLine(s string) {
+	if g.continueLine {
+		g.continueLine = false
+		g.write(s)
+		return
+	}
 	g.write("\n")
 	g.writeIndentation(s)
 }
 
+// trimLine removes the spaces at the end of the current line, before a
+// newline is written.
+func (g *generator) trimLine() {
+	b := g.buffer.Bytes()
+	n := len(bytes.TrimRight(b, " \t"))
+	if g.semicolonPending && n < g.semicolonAt {
+		n = g.semicolonAt
+	}
+	g.buffer.Truncate(n)
+}
+
+// writeBlankLine ends the current line and writes an empty line, which the
+// next statement continues.
+func (g *generator) writeBlankLine() {
+	if !bytes.HasSuffix(g.buffer.Bytes(), []byte("\n")) {
+		g.writeRaw("\n")
+	}
+	g.writeRaw("\n")
+	g.writeIndentation("")
+	g.continueLine = true
+}
+
 func (g *generator) code() io.Reader {
 	return g.buffer
 }
 
 func (g *generator) generateProgram(p *ast.Program) error {
-	for _, dcl := range p.DeclarationList {
-		if err := g.generateDeclaration(dcl); err != nil {
+	if len(p.Body) > 0 && !g.options.Print.KeepLayout {
+		// the comments before the imports, like a license, start the
+		// module rather than follow its functions
+		if _, ok := p.Body[0].(*ast.ImportStatement); ok {
+			g.writeLeadingComments(p.Body[0])
+		}
+	}
+	if err := g.statementList(g.prologues[p]); err != nil {
+		return err
+	}
+	if !g.options.Print.KeepLayout {
+		if err := g.generateDeclarations(p.DeclarationList); err != nil {
+			return err
+		}
+	}
+	if err := g.statementList(p.Body); err != nil {
+		return err
+	}
+	if g.options.Print.KeepLayout {
+		// the declarations that are not statements of the body
+		if err := g.generateDeclarations(p.DeclarationList); err != nil {
 			return err
 		}
 	}
+	g.writeFinalComments(p)
+	g.writeTrailingComments(p)
 
-	for _, stmt := range p.Body {
-		if err := g.generateStatement(stmt, nil); err != nil {
+	// the last semicolon is not needed, but a line comment must be ended
+	g.semicolonPending = false
+	if g.newlinePending {
+		g.writeRaw("\n")
+	}
+	return nil
+}
+
+func (g *generator) generateDeclarations(dcls []ast.Declaration) error {
+	for _, dcl := range dcls {
+		if err := g.generateDeclaration(dcl); err != nil {
 			return err
 		}
 	}
@@ -122,34 +394,50 @@ func (g *generator) generateProgram(p *ast.Program) error {
 }
 
 func (g *generator) generateDeclaration(d ast.Declaration) error {
-	if fn, ok := d.(*ast.FunctionDeclaration); ok {
-		return g.functionLiteral(fn.Function, false)
+	if fn, ok := d.(*ast.FunctionDeclaration); ok && !g.declared[fn.Function] {
+		return g.functionDeclaration(fn.Function)
 	}
-
 	return nil
 }
 
-func (g *generator) parameterList(pl *ast.ParameterList) error {
-	g.write("(")
-	for i, p := range pl.List {
-		if err := g.identifier(p); err != nil {
-			return err
-		}
-		if i < len(pl.List)-1 {
-			g.write(", ")
-		}
+func (g *generator) functionDeclaration(fn *ast.FunctionLiteral) error {
+	g.writeLeadingComments(fn)
+	g.writeAlone("")
+	if err := g.functionLiteral(fn, false); err != nil {
+		return err
 	}
-	g.write(")")
+	g.writeTrailingComments(fn)
 	return nil
 }
 
-func (g *generator) argumentList(exps []ast.Expression) error {
+// parameterList generates the parameters of f. Default and rest parameters
+// are assigned at the start of the body of f before ES2015.
+func (g *generator) parameterList(f *ast.FunctionLiteral) error {
+	pl := f.ParameterList
+	list := pl.List
+	if !g.supports(es2015) {
+		g.lowerParameters(f)
+		if pl.Rest {
+			list = list[:len(list)-1]
+		}
+	}
 	g.write("(")
-	for i, a := range exps {
-		if err := g.generateExpression(a); err != nil {
+	for i, p := range list {
+		g.writeInlineComments(p)
+		if pl.Rest && i == len(pl.List)-1 {
+			g.write("...")
+		}
+		if err := g.identifier(p); err != nil {
 			return err
 		}
-		if i < len(exps)-1 {
+		g.deferTrailingComments(p)
+		if pl.Defaults != nil && pl.Defaults[i] != nil && g.supports(es2015) {
+			g.write(" = ")
+			if err := g.subExpression(pl.Defaults[i], precAssign); err != nil {
+				return err
+			}
+		}
+		if i < len(list)-1 {
 			g.write(", ")
 		}
 	}
@@ -157,20 +445,12 @@ func (g *generator) argumentList(exps []ast.Expression) error {
 	return nil
 }
 
-func (g *generator) isInExpression() bool {
-	return g.expressionLevel > 1
-}
-
-func (g *generator) descentExpression() {
-	g.expressionLevel++
-}
-
-func (g *generator) ascentExpression() {
-	g.expressionLevel--
+func (g *generator) argumentList(exps []ast.Expression) error {
+	return g.list("(", ")", exps, nil, g.options.Print.TrailingCommas && g.supports(es2017))
 }
 
 func escapeKey(k string) string {
-	return fmt.Sprintf("\"%s\"", k)
+	return quoteString(k)
 }
 
 func escapeKeyIfRequired(k string) string {
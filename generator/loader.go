@@ -0,0 +1,115 @@
+package generator
+
+import (
+	"io"
+	"path/filepath"
+)
+
+// Loader transforms the source of a file before it is bundled.
+type Loader interface {
+	Load(in io.Reader) (io.Reader, error)
+}
+
+// ModuleLoader is a loader that is given the context of the module it
+// transforms, so it can resolve other modules, emit assets and declare the
+// files it depends on.
+type ModuleLoader interface {
+	LoadModule(ctx *LoadContext, in io.Reader) (io.Reader, error)
+}
+
+// LoaderFunc is a function used as a ModuleLoader.
+type LoaderFunc func(ctx *LoadContext, in io.Reader) (io.Reader, error)
+
+func (f LoaderFunc) LoadModule(ctx *LoadContext, in io.Reader) (io.Reader, error) {
+	return f(ctx, in)
+}
+
+// AdaptLoader returns a ModuleLoader that calls loader. Loaders that already
+// implement ModuleLoader are returned as they are.
+func AdaptLoader(loader Loader) ModuleLoader {
+	if moduleLoader, ok := loader.(ModuleLoader); ok {
+		return moduleLoader
+	}
+	return LoaderFunc(func(ctx *LoadContext, in io.Reader) (io.Reader, error) {
+		return loader.Load(in)
+	})
+}
+
+// AdaptLoaders converts a map of Loaders, as passed to Bundle, to the
+// ModuleLoaders used by Config.
+func AdaptLoaders(loaders map[string][]Loader) map[string][]ModuleLoader {
+	if loaders == nil {
+		return nil
+	}
+	adapted := make(map[string][]ModuleLoader)
+	for ext, list := range loaders {
+		for _, loader := range list {
+			adapted[ext] = append(adapted[ext], AdaptLoader(loader))
+		}
+	}
+	return adapted
+}
+
+// LoadContext describes the module being loaded.
+type LoadContext struct {
+	// Path is the absolute path of the file.
+	Path string
+
+	// Query is the resource query of the import, including the "?", eg.
+	// "?raw" for import "./icon.svg?raw". It is empty if there is none.
+	Query string
+
+	// Importer is the path of the module that first imported the file. It
+	// is empty for the entry module.
+	Importer string
+
+	bundle    *_bundle
+	sourceMap []byte
+}
+
+// Resolve returns the path of the file importValue refers to when imported
+// from the module.
+func (ctx *LoadContext) Resolve(importValue string) (string, error) {
+	importValue, _ = splitQuery(importValue)
+	return ctx.bundle.resolve(importValue, ctx.Path, kindRequire)
+}
+
+// Require adds the module importValue refers to to the bundle and returns
+// its name, for use in the generated code as require('<name>').
+func (ctx *LoadContext) Require(importValue string) (string, error) {
+	return ctx.bundle.resolveModule(importValue, ctx.Path, kindRequire)
+}
+
+// EmitAsset adds a file to the build output. Emitting an asset with the same
+// name again replaces it.
+func (ctx *LoadContext) EmitAsset(name string, data []byte) {
+	ctx.bundle.emitAsset(filepath.ToSlash(filepath.Clean(name)), data)
+}
+
+// Asset returns the data of the asset called name, if it was emitted
+// earlier in the build, eg. so that a loader can add to it.
+func (ctx *LoadContext) Asset(name string) ([]byte, bool) {
+	name = filepath.ToSlash(filepath.Clean(name))
+	for _, asset := range ctx.bundle.assets {
+		if asset.Name == name {
+			return asset.Data, true
+		}
+	}
+	return nil, false
+}
+
+// AddDependency records a file that the output of the loader depends on,
+// so that the bundle is rebuilt when it changes.
+func (ctx *LoadContext) AddDependency(path string) {
+	if abs, err := ctx.bundle.fs.abs(path); err == nil {
+		path = abs
+	}
+	ctx.bundle.dependencies[path] = true
+}
+
+// SetSourceMap sets the JSON source map of the output of the loader. It maps
+// the output to the input of the loader, and is composed with the maps of
+// the loaders before it.
+func (ctx *LoadContext) SetSourceMap(data []byte) {
+	ctx.sourceMap = data
+}
@@ -0,0 +1,680 @@
+package generator
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/walesey/go-bundle/ast"
+	"github.com/walesey/go-bundle/token"
+)
+
+// reservedWords are the words that cannot be used as names.
+var reservedWords = map[string]bool{
+	"break": true, "case": true, "catch": true, "class": true, "const": true,
+	"continue": true, "debugger": true, "default": true, "delete": true,
+	"do": true, "else": true, "enum": true, "export": true, "extends": true,
+	"false": true, "finally": true, "for": true, "function": true, "if": true,
+	"implements": true, "import": true, "in": true, "instanceof": true,
+	"interface": true, "let": true, "new": true, "null": true, "package": true,
+	"private": true, "protected": true, "public": true, "return": true,
+	"static": true, "super": true, "switch": true, "this": true, "throw": true,
+	"true": true, "try": true, "typeof": true, "var": true, "void": true,
+	"while": true, "with": true, "yield": true,
+	"arguments": true, "eval": true, "undefined": true, "NaN": true, "Infinity": true,
+}
+
+const (
+	nameStart = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ$_"
+	namePart  = nameStart + "0123456789"
+)
+
+// shortName returns the i-th shortest identifier.
+func shortName(i int) string {
+	name := []byte{nameStart[i%len(nameStart)]}
+	for i /= len(nameStart); i > 0; i /= len(namePart) {
+		i--
+		name = append(name, namePart[i%len(namePart)])
+	}
+	return string(name)
+}
+
+// occurrence is a name in the AST. A JSX element name is a variable followed
+// by the rest of the name, eg. "Foo.Bar".
+type occurrence struct {
+	name   *string
+	suffix string
+}
+
+type binding struct {
+	name        string
+	fixed       bool
+	occurrences []occurrence
+
+	// uses counts the references that are not declarations, which are the
+	// *ast.VariableExpression and *ast.FunctionLiteral nodes declaring it
+	uses         int
+	declarations []ast.Node
+}
+
+type reference struct {
+	scope      *scope
+	name       string
+	occurrence occurrence
+
+	// declaration is the node declaring the name, if the reference is a
+	// declaration
+	declaration ast.Node
+}
+
+// scope holds the names declared by a function, a catch clause, a block
+// with let or const declarations or the program. The names of fixed scopes
+// are kept, as code that is not known when mangling may refer to them. The
+// program scope is never renamed, as other scripts may use its names.
+type scope struct {
+	parent   *scope
+	children []*scope
+	function bool
+	fixed    bool
+	bindings map[string]*binding
+	order    []*binding
+
+	// outer are the bindings of enclosing scopes referred to from within
+	// the scope, and free the names that refer to globals. New names must
+	// not capture them.
+	outer map[*binding]bool
+	free  map[string]bool
+}
+
+func newScope(parent *scope, function bool) *scope {
+	s := &scope{
+		parent:   parent,
+		function: function,
+		bindings: make(map[string]*binding),
+		outer:    make(map[*binding]bool),
+		free:     make(map[string]bool),
+	}
+	if parent != nil {
+		parent.children = append(parent.children, s)
+	}
+	return s
+}
+
+func (s *scope) declare(name string) *binding {
+	if b, ok := s.bindings[name]; ok {
+		return b
+	}
+	b := &binding{name: name, fixed: name == "arguments"}
+	s.bindings[name] = b
+	s.order = append(s.order, b)
+	return b
+}
+
+// functionScope returns the scope var and function declarations in s are
+// added to.
+func (s *scope) functionScope() *scope {
+	for !s.function && s.parent != nil {
+		s = s.parent
+	}
+	return s
+}
+
+// fix keeps the names of s and all enclosing scopes.
+func (s *scope) fix() {
+	for ; s != nil; s = s.parent {
+		s.fixed = true
+	}
+}
+
+// mangler renames the local variables of a program to short names.
+type mangler struct {
+	scope      *scope
+	references []reference
+	visited    map[*ast.FunctionLiteral]bool
+
+	// lexical is set if let and const are local to their block
+	lexical bool
+
+	// property mangling, properties is nil if no properties are mangled
+	properties *propertyNames
+	props      []occurrence
+	strings    []*ast.StringLiteral
+
+	// arrow function captures, captures is nil if they are not found. owner
+	// is the innermost function, not an arrow function, or the program, and
+	// arrow is set inside an arrow function
+	captures map[ast.Node]ast.Node
+	owner    ast.Node
+	arrow    bool
+}
+
+// propertyNames are the mangled names of properties. They are shared by
+// all modules of a bundle, so properties keep matching across modules.
+type propertyNames struct {
+	pattern *regexp.Regexp
+	names   map[string]string
+	next    int
+
+	// reserved are the names of the properties that are kept, which the
+	// mangled names must not be
+	reserved map[string]bool
+}
+
+func newPropertyNames(pattern *regexp.Regexp) *propertyNames {
+	return &propertyNames{
+		pattern:  pattern,
+		names:    make(map[string]string),
+		reserved: make(map[string]bool),
+	}
+}
+
+// mangle renames the local variables of p if locals is set, and the
+// properties matching the pattern of properties if it is not nil. let and
+// const are local to their block if lexical is set, and are written as var
+// otherwise.
+func mangle(p *ast.Program, locals bool, properties *propertyNames, lexical bool) {
+	m := analyzeScopes(p, properties, lexical)
+	if locals {
+		m.resolve()
+		renameScope(m.scope)
+	}
+	if properties != nil {
+		m.renameProperties()
+	}
+}
+
+// analyzeScopes collects the scopes of p and the names referred to in them.
+// Blocks have their own scope for let and const if lexical is set.
+func analyzeScopes(p *ast.Program, properties *propertyNames, lexical bool) *mangler {
+	m := &mangler{properties: properties, lexical: lexical, visited: make(map[*ast.FunctionLiteral]bool)}
+	m.program(p)
+	return m
+}
+
+func (m *mangler) program(p *ast.Program) {
+	m.scope = newScope(nil, true)
+	m.owner = p
+	for _, stmt := range p.Body {
+		m.statement(stmt)
+	}
+	m.declarations(p.DeclarationList)
+}
+
+// capture records that node, a this, arguments or call of super, is in an
+// arrow function, and refers to the this of the owner.
+func (m *mangler) capture(node ast.Node) {
+	if m.captures == nil || !m.arrow {
+		return
+	}
+	m.captures[node] = m.owner
+}
+
+// freeIdentifiers returns the identifiers of p that refer to globals.
+func freeIdentifiers(p *ast.Program) map[*string]bool {
+	free := make(map[*string]bool)
+	for _, ref := range analyzeScopes(p, nil, true).references {
+		if ref.binding() == nil {
+			free[ref.occurrence.name] = true
+		}
+	}
+	return free
+}
+
+// usedNames returns the names declared or referred to in p.
+func usedNames(p *ast.Program) map[string]bool {
+	names := make(map[string]bool)
+	for _, ref := range analyzeScopes(p, nil, true).references {
+		names[ref.name] = true
+	}
+	return names
+}
+
+// binding returns the binding ref refers to, or nil for a global.
+func (ref reference) binding() *binding {
+	for s := ref.scope; s != nil; s = s.parent {
+		if b := s.bindings[ref.name]; b != nil {
+			return b
+		}
+	}
+	return nil
+}
+
+// declarations visits the function declarations that are not statements of
+// the body.
+func (m *mangler) declarations(list []ast.Declaration) {
+	for _, dcl := range list {
+		if fn, ok := dcl.(*ast.FunctionDeclaration); ok && !m.visited[fn.Function] {
+			m.functionDeclaration(fn.Function)
+		}
+	}
+}
+
+// resolve links the references to the bindings they refer to.
+func (m *mangler) resolve() {
+	for _, ref := range m.references {
+		b := ref.binding()
+		for inner := ref.scope; inner != nil && (b == nil || inner.bindings[ref.name] != b); inner = inner.parent {
+			if b != nil {
+				inner.outer[b] = true
+			} else {
+				inner.free[ref.name] = true
+			}
+		}
+		if b == nil {
+			continue
+		}
+		b.occurrences = append(b.occurrences, ref.occurrence)
+		if ref.declaration != nil {
+			b.declarations = append(b.declarations, ref.declaration)
+		} else {
+			b.uses++
+		}
+	}
+}
+
+// renameScope renames the bindings of s and the scopes within it. The most
+// used names get the shortest names.
+func renameScope(s *scope) {
+	if !s.fixed && s.parent != nil {
+		taken := make(map[string]bool)
+		for b := range s.outer {
+			taken[b.name] = true
+		}
+		for name := range s.free {
+			taken[name] = true
+		}
+		for _, b := range s.order {
+			if b.fixed {
+				taken[b.name] = true
+			}
+		}
+
+		bindings := append([]*binding{}, s.order...)
+		sort.SliceStable(bindings, func(i, j int) bool {
+			return len(bindings[i].occurrences) > len(bindings[j].occurrences)
+		})
+		next := 0
+		for _, b := range bindings {
+			if b.fixed {
+				continue
+			}
+			name := shortName(next)
+			for taken[name] || reservedWords[name] {
+				next++
+				name = shortName(next)
+			}
+			next++
+			taken[name] = true
+			b.name = name
+			for _, o := range b.occurrences {
+				*o.name = name + o.suffix
+			}
+		}
+	}
+	for _, child := range s.children {
+		renameScope(child)
+	}
+}
+
+func (m *mangler) refer(name *string) {
+	m.references = append(m.references, reference{
+		scope:      m.scope,
+		name:       *name,
+		occurrence: occurrence{name: name},
+	})
+}
+
+// declareVar adds a var or function declaration of name by node.
+func (m *mangler) declareVar(name *string, node ast.Node) {
+	m.scope.functionScope().declare(*name)
+	m.refer(name)
+	m.references[len(m.references)-1].declaration = node
+}
+
+// declareLexical adds a let or const declaration of name by node to the
+// current block.
+func (m *mangler) declareLexical(name *string, node ast.Node) {
+	m.scope.declare(*name)
+	m.refer(name)
+	m.references[len(m.references)-1].declaration = node
+}
+
+// declaresLexically reports whether list declares let or const variables
+// or classes, which are local to the block of list.
+func declaresLexically(list []ast.Statement) bool {
+	for _, stmt := range list {
+		if v, ok := stmt.(*ast.VariableStatement); ok && (v.Token == token.LET || v.Token == token.CONST) {
+			return true
+		}
+		if _, ok := stmt.(*ast.ClassStatement); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *mangler) functionDeclaration(fn *ast.FunctionLiteral) {
+	if fn.Name != nil {
+		m.declareVar(&fn.Name.Name, fn)
+	}
+	m.function(fn, false)
+}
+
+func (m *mangler) function(fn *ast.FunctionLiteral, expression bool) {
+	m.visited[fn] = true
+	if m.captures != nil {
+		owner, arrow := m.owner, m.arrow
+		defer func() { m.owner, m.arrow = owner, arrow }()
+		if !fn.Arrow {
+			m.owner = fn
+		}
+		m.arrow = fn.Arrow
+	}
+	parent := m.scope
+	m.scope = newScope(parent, true)
+	if expression && fn.Name != nil {
+		m.scope.declare(fn.Name.Name)
+		m.refer(&fn.Name.Name)
+	}
+	if fn.ParameterList != nil {
+		for _, p := range fn.ParameterList.List {
+			m.scope.declare(p.Name)
+			m.refer(&p.Name)
+		}
+		m.expressions(fn.ParameterList.Defaults)
+	}
+	m.statement(fn.Body)
+	m.declarations(fn.DeclarationList)
+	m.scope = parent
+}
+
+// class visits the superclass and the methods of c. The name of a class
+// expression is local to the class.
+func (m *mangler) class(c *ast.ClassLiteral, expression bool) {
+	m.expression(c.SuperClass)
+	parent := m.scope
+	if expression && c.Name != nil {
+		m.scope = newScope(parent, false)
+		m.scope.declare(c.Name.Name)
+		m.refer(&c.Name.Name)
+	}
+	for i := range c.Body {
+		if c.Body[i].Computed != nil {
+			m.expression(c.Body[i].Computed)
+		} else if c.Body[i].Kind != "constructor" {
+			m.property(&c.Body[i].Key)
+		}
+		m.function(c.Body[i].Value, false)
+	}
+	m.scope = parent
+}
+
+func (m *mangler) statements(list []ast.Statement) {
+	for _, stmt := range list {
+		m.statement(stmt)
+	}
+}
+
+func (m *mangler) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.BlockStatement:
+		if !m.lexical || !declaresLexically(stmt.List) {
+			m.statements(stmt.List)
+			break
+		}
+		parent := m.scope
+		m.scope = newScope(parent, false)
+		m.statements(stmt.List)
+		m.scope = parent
+	case *ast.CaseStatement:
+		m.expression(stmt.Test)
+		m.statements(stmt.Consequent)
+	case *ast.CatchStatement:
+		parent := m.scope
+		m.scope = newScope(parent, false)
+		if stmt.Parameter != nil {
+			m.scope.declare(stmt.Parameter.Name)
+			m.refer(&stmt.Parameter.Name)
+		}
+		m.statement(stmt.Body)
+		m.scope = parent
+	case *ast.DoWhileStatement:
+		m.statement(stmt.Body)
+		m.expression(stmt.Test)
+	case *ast.ExpressionStatement:
+		m.expression(stmt.Expression)
+	case *ast.ForInStatement:
+		m.expression(stmt.Into)
+		m.expression(stmt.Source)
+		m.statement(stmt.Body)
+	case *ast.ForOfStatement:
+		m.expression(stmt.Source)
+		v, ok := stmt.Into.(*ast.VariableExpression)
+		if !ok || !m.lexical || stmt.Token != token.LET && stmt.Token != token.CONST {
+			m.expression(stmt.Into)
+			m.statement(stmt.Body)
+			break
+		}
+		parent := m.scope
+		m.scope = newScope(parent, false)
+		m.declareLexical(&v.Name, v)
+		m.statement(stmt.Body)
+		m.scope = parent
+	case *ast.ForStatement:
+		m.expression(stmt.Initializer)
+		m.expression(stmt.Test)
+		m.expression(stmt.Update)
+		m.statement(stmt.Body)
+	case *ast.FunctionStatement:
+		m.functionDeclaration(stmt.Function)
+	case *ast.ClassStatement:
+		// classes are written as var before ES2015
+		if m.lexical {
+			m.declareLexical(&stmt.Class.Name.Name, stmt)
+		} else {
+			m.declareVar(&stmt.Class.Name.Name, stmt)
+		}
+		m.class(stmt.Class, false)
+	case *ast.IfStatement:
+		m.expression(stmt.Test)
+		m.statement(stmt.Consequent)
+		m.statement(stmt.Alternate)
+	case *ast.LabelledStatement:
+		m.statement(stmt.Statement)
+	case *ast.ReturnStatement:
+		m.expression(stmt.Argument)
+	case *ast.SwitchStatement:
+		m.expression(stmt.Discriminant)
+		parent := m.scope
+		for _, c := range stmt.Body {
+			if m.lexical && declaresLexically(c.Consequent) {
+				// the cases share one block
+				m.scope = newScope(parent, false)
+				break
+			}
+		}
+		for _, c := range stmt.Body {
+			m.statement(c)
+		}
+		m.scope = parent
+	case *ast.ThrowStatement:
+		m.expression(stmt.Argument)
+	case *ast.TryStatement:
+		m.statement(stmt.Body)
+		if stmt.Catch != nil {
+			m.statement(stmt.Catch)
+		}
+		m.statement(stmt.Finally)
+	case *ast.VariableStatement:
+		if !m.lexical || stmt.Token != token.LET && stmt.Token != token.CONST {
+			m.expressions(stmt.List)
+			break
+		}
+		for _, exp := range stmt.List {
+			if v, ok := exp.(*ast.VariableExpression); ok {
+				m.declareLexical(&v.Name, v)
+				m.expression(v.Initializer)
+			}
+		}
+	case *ast.WhileStatement:
+		m.expression(stmt.Test)
+		m.statement(stmt.Body)
+	case *ast.WithStatement:
+		// names in the body may be properties of the object
+		m.scope.fix()
+		m.expression(stmt.Object)
+		m.statement(stmt.Body)
+	case *ast.ExportStatement:
+		declared := len(m.references)
+		m.statement(stmt.Statement)
+		// exported declarations are used by the exports
+		for _, ref := range m.references[declared:] {
+			if ref.declaration != nil && ref.scope == m.scope {
+				ref.declaration = nil
+				m.references = append(m.references, ref)
+			}
+		}
+	case *ast.ExportDefaultStatement:
+		m.expression(stmt.Argument)
+	}
+}
+
+func (m *mangler) expressions(list []ast.Expression) {
+	for _, exp := range list {
+		m.expression(exp)
+	}
+}
+
+func (m *mangler) expression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		if exp.Name == "arguments" {
+			m.capture(exp)
+		}
+		m.refer(&exp.Name)
+	case *ast.ThisExpression:
+		m.capture(exp)
+	case *ast.VariableExpression:
+		m.declareVar(&exp.Name, exp)
+		m.expression(exp.Initializer)
+	case *ast.FunctionLiteral:
+		m.function(exp, true)
+	case *ast.ClassLiteral:
+		m.class(exp, true)
+	case *ast.AwaitExpression:
+		m.expression(exp.Argument)
+	case *ast.ArrayLiteral:
+		m.expressions(exp.Value)
+	case *ast.AssignExpression:
+		m.expression(exp.Left)
+		m.expression(exp.Right)
+	case *ast.BinaryExpression:
+		m.expression(exp.Left)
+		m.expression(exp.Right)
+	case *ast.BracketExpression:
+		m.expression(exp.Left)
+		m.expression(exp.Member)
+		if s, ok := exp.Member.(*ast.StringLiteral); ok && m.property(&s.Value) {
+			m.strings = append(m.strings, s)
+		}
+	case *ast.CallExpression:
+		if callee, ok := exp.Callee.(*ast.Identifier); ok && callee.Name == "eval" {
+			// direct eval can refer to any name in scope
+			m.scope.fix()
+		}
+		if callsSuper(exp) {
+			m.capture(exp)
+		}
+		m.expression(exp.Callee)
+		m.expressions(exp.ArgumentList)
+	case *ast.ConditionalExpression:
+		m.expression(exp.Test)
+		m.expression(exp.Consequent)
+		m.expression(exp.Alternate)
+	case *ast.DotExpression:
+		m.expression(exp.Left)
+		m.property(&exp.Identifier.Name)
+	case *ast.NewExpression:
+		m.expression(exp.Callee)
+		m.expressions(exp.ArgumentList)
+	case *ast.ObjectLiteral:
+		for i := range exp.Value {
+			if exp.Value[i].Computed != nil {
+				m.expression(exp.Value[i].Computed)
+			} else if exp.Value[i].Kind != "spread" {
+				m.property(&exp.Value[i].Key)
+			}
+			m.expression(exp.Value[i].Value)
+		}
+	case *ast.SequenceExpression:
+		m.expressions(exp.Sequence)
+	case *ast.UnaryExpression:
+		m.expression(exp.Operand)
+	case *ast.DynamicStringExpression:
+		m.expressions(exp.List)
+	case *ast.JSXBlock:
+		m.jsxElement(exp.OpeningElement)
+		m.expressions(exp.Body)
+	case *ast.JSXExpression:
+		m.expression(exp.Identifier)
+	}
+}
+
+// jsxElement refers to the variable of a component, whose name is not
+// lower case.
+func (m *mangler) jsxElement(e *ast.JSXElement) {
+	if name := e.Name.Name; name != "" && !('a' <= name[0] && name[0] <= 'z') {
+		variable := name
+		suffix := ""
+		if i := strings.IndexByte(name, '.'); i != -1 {
+			variable, suffix = name[:i], name[i:]
+		}
+		m.references = append(m.references, reference{
+			scope:      m.scope,
+			name:       variable,
+			occurrence: occurrence{name: &e.Name.Name, suffix: suffix},
+		})
+	}
+	for _, p := range e.PropertyList {
+		m.expression(p.Value)
+	}
+}
+
+// property records a property name, and reports whether it is mangled.
+// Names that are not mangled are reserved, so mangled names do not collide
+// with them.
+func (m *mangler) property(name *string) bool {
+	if m.properties == nil {
+		return false
+	}
+	if !m.properties.pattern.MatchString(*name) {
+		m.properties.reserved[*name] = true
+		return false
+	}
+	m.props = append(m.props, occurrence{name: name})
+	return true
+}
+
+func (m *mangler) renameProperties() {
+	for _, o := range m.props {
+		*o.name = m.properties.name(*o.name)
+	}
+	for _, s := range m.strings {
+		s.Literal = s.Literal[:1] + s.Value + s.Literal[:1]
+	}
+}
+
+// name returns the mangled name of the property called name.
+func (p *propertyNames) name(name string) string {
+	if mangled, ok := p.names[name]; ok {
+		return mangled
+	}
+	mangled := shortName(p.next)
+	for p.reserved[mangled] || reservedWords[mangled] {
+		p.next++
+		mangled = shortName(p.next)
+	}
+	p.next++
+	p.names[name] = mangled
+	return mangled
+}
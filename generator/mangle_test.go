@@ -0,0 +1,111 @@
+package generator
+
+import (
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShortName(t *testing.T) {
+	assert.Equal(t, "a", shortName(0))
+	assert.Equal(t, "_", shortName(53))
+	assert.Equal(t, "aa", shortName(54))
+	assert.Equal(t, "ba", shortName(55))
+	assert.Equal(t, "ab", shortName(108))
+	assert.Equal(t, "_9", shortName(54+54*64-1))
+	assert.Equal(t, "aaa", shortName(54+54*64))
+}
+
+func TestLoadMangle(t *testing.T) {
+	load := func(src string, options Options) string {
+		options.Minify = true
+		out, err := LoadWithOptions(strings.NewReader(src), options)
+		assert.NoError(t, err)
+		code, err := ioutil.ReadAll(out)
+		assert.NoError(t, err)
+		return string(code)
+	}
+	mangled := func(src string) string {
+		return load(src, Options{Mangle: true})
+	}
+
+	// top level names are kept, the most used locals get the shortest names
+	assert.Equal(t,
+		"function add(b,a){a=a+a;return total+b+a}var total=0",
+		mangled("var total = 0; function add(value, twice) { twice = twice + twice; return total + value + twice; }"))
+
+	// globals and outer variables used in a function are not captured
+	assert.Equal(t,
+		"function f(b){return function(c){return a+b+c}}",
+		mangled("function f(x) { return function (y) { return a + x + y; }; }"))
+
+	// hoisted functions, function expression names and catch parameters
+	assert.Equal(t,
+		"function f(){return a(b);var b=function b(a){return a?b(a-1):0};function a(a){return a}}",
+		mangled("function f() { return g(h); function g(v) { return v; } var h = function r(n) { return n ? r(n - 1) : 0; }; }"))
+	assert.Equal(t,
+		"function f(a){try{a()}catch(b){a(b)}}",
+		mangled("function f(cb) { try { cb(); } catch (err) { cb(err); } }"))
+
+	// eval and with may refer to any name in scope
+	assert.Equal(t,
+		"function f(x){return function(){return eval('x')}}",
+		mangled("function f(x) { return function () { return eval('x'); }; }"))
+	assert.Equal(t,
+		"function f(x){with(o){x++}}",
+		mangled("function f(x) { with (o) { x++; } }"))
+
+	// arguments keeps its meaning
+	assert.Equal(t,
+		"function f(a){return arguments[0]+a}",
+		mangled("function f(first) { return arguments[0] + first; }"))
+
+	// let and const are local to their block when they are kept
+	assert.Equal(t,
+		"function f(a){if(a){let a=1;g(a)}return foo}",
+		load("function f(x) { if (x) { let foo = 1; g(foo); } return foo; }", Options{Mangle: true, Target: TargetES2015}))
+	assert.Equal(t,
+		"function f(a){switch(a){case 1:const b=a;return b}return b}",
+		load("function f(x) { switch (x) { case 1: const y = x; return y; } return b; }", Options{Mangle: true, Target: TargetES2015}))
+	assert.Equal(t,
+		"function f(b){if(b){var a=1;g(a)}return a}",
+		mangled("function f(x) { if (x) { let foo = 1; g(foo); } return foo; }"))
+
+	// properties
+	assert.Equal(t,
+		"var o={b:1,a:2};o.c=o.b+o['b']",
+		load("var o = { _x: 1, a: 2 }; o._y = o._x + o['_x'];", Options{MangleProperties: regexp.MustCompile("^_")}))
+}
+
+func TestBundleMangle(t *testing.T) {
+	result, err := Build("./testdata/mangle/index.js", Config{
+		Minify:           true,
+		Mangle:           true,
+		MangleProperties: regexp.MustCompile("^_"),
+	})
+	assert.NoError(t, err)
+	code := string(result.Code)
+	assert.NotContains(t, code, "_count")
+	assert.NotContains(t, code, "counter")
+	assert.Contains(t, code, "function Counter(a){this.a=a}")
+	assert.Contains(t, code, "prototype.increment=function(a){var b=this.a+a;this.a=b;return this}")
+	assert.Contains(t, code, "function count(c){var a=new Counter(0);for(var b=0;b<c;b++){a.increment(1)}return a['a']}")
+}
+
+func TestBundleMangleKeptProperties(t *testing.T) {
+	// the property a, which is kept, is in a module loaded after _x is
+	// renamed, and _x must not be renamed to it
+	result, err := BuildSource("var o = { _x: 1 };\nrequire('./b');", "./index.js", Config{
+		MangleProperties: regexp.MustCompile("^_"),
+		Virtual: map[string]VirtualModule{
+			"./b.js": {Contents: "var p = { a: 1, _x: 2 };"},
+		},
+	})
+	assert.NoError(t, err)
+	code := string(result.Code)
+	assert.Contains(t, code, "var o = {\n  b: 1\n};")
+	assert.Contains(t, code, "var p = {\n  a: 1,\n  b: 2\n};")
+}
@@ -0,0 +1,275 @@
+package generator
+
+import (
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/walesey/go-bundle/ast"
+	"github.com/walesey/go-bundle/parser"
+	"github.com/walesey/go-bundle/token"
+)
+
+// Operator precedences, from the loosest to the tightest binding. An
+// expression is parenthesized when it binds looser than its position allows.
+const (
+	precLowest = iota
+	precSequence
+	precAssign
+	precConditional
+	precLogicalOr
+	precLogicalAnd
+	precBitwiseOr
+	precBitwiseXor
+	precBitwiseAnd
+	precEquality
+	precRelational
+	precShift
+	precAdditive
+	precMultiplicative
+	precExponent
+	precPrefix
+	precPostfix
+	precCall
+	precMember
+	precPrimary
+)
+
+var binaryPrecedence = map[token.Token]int{
+	token.NULLISH_COALESCING:   precLogicalOr,
+	token.LOGICAL_OR:           precLogicalOr,
+	token.LOGICAL_AND:          precLogicalAnd,
+	token.OR:                   precBitwiseOr,
+	token.EXCLUSIVE_OR:         precBitwiseXor,
+	token.AND:                  precBitwiseAnd,
+	token.EQUAL:                precEquality,
+	token.NOT_EQUAL:            precEquality,
+	token.STRICT_EQUAL:         precEquality,
+	token.STRICT_NOT_EQUAL:     precEquality,
+	token.LESS:                 precRelational,
+	token.GREATER:              precRelational,
+	token.LESS_OR_EQUAL:        precRelational,
+	token.GREATER_OR_EQUAL:     precRelational,
+	token.INSTANCEOF:           precRelational,
+	token.IN:                   precRelational,
+	token.SHIFT_LEFT:           precShift,
+	token.SHIFT_RIGHT:          precShift,
+	token.UNSIGNED_SHIFT_RIGHT: precShift,
+	token.PLUS:                 precAdditive,
+	token.MINUS:                precAdditive,
+	token.MULTIPLY:             precMultiplicative,
+	token.SLASH:                precMultiplicative,
+	token.REMAINDER:            precMultiplicative,
+	token.EXPONENT:             precExponent,
+}
+
+// precedence returns how tightly exp binds.
+func precedence(exp ast.Expression) int {
+	switch exp := exp.(type) {
+	case *ast.SequenceExpression:
+		return precSequence
+	case *ast.AssignExpression:
+		return precAssign
+	case *ast.ConditionalExpression:
+		return precConditional
+	case *ast.BinaryExpression:
+		if prec, ok := binaryPrecedence[exp.Operator]; ok {
+			return prec
+		}
+		return precLowest
+	case *ast.DynamicStringExpression:
+		switch len(exp.List) {
+		case 0:
+			return precPrimary
+		case 1:
+			return precedence(exp.List[0])
+		}
+		return precAdditive
+	case *ast.UnaryExpression:
+		if exp.Postfix {
+			return precPostfix
+		}
+		return precPrefix
+	case *ast.AwaitExpression:
+		return precPrefix
+	case *ast.CallExpression:
+		return precCall
+	case *ast.DotExpression, *ast.BracketExpression, *ast.NewExpression:
+		return precMember
+	}
+	return precPrimary
+}
+
+// hasCall reports whether the member expression exp contains a call, which
+// must be parenthesized as the callee of new.
+func hasCall(exp ast.Expression) bool {
+	switch exp := exp.(type) {
+	case *ast.CallExpression:
+		return true
+	case *ast.DotExpression:
+		return hasCall(exp.Left)
+	case *ast.BracketExpression:
+		return hasCall(exp.Left)
+	}
+	return false
+}
+
+// hasIn reports whether exp contains an in operator that is not
+// parenthesized when exp is generated.
+func hasIn(exp ast.Expression) bool {
+	switch exp := exp.(type) {
+	case *ast.BinaryExpression:
+		return exp.Operator == token.IN || hasIn(exp.Left) || hasIn(exp.Right)
+	case *ast.AssignExpression:
+		return hasIn(exp.Right)
+	case *ast.ConditionalExpression:
+		return hasIn(exp.Test) || hasIn(exp.Consequent) || hasIn(exp.Alternate)
+	case *ast.SequenceExpression:
+		for _, e := range exp.Sequence {
+			if hasIn(e) {
+				return true
+			}
+		}
+	case *ast.UnaryExpression:
+		return hasIn(exp.Operand)
+	}
+	return false
+}
+
+// startsStatementAmbiguously reports whether the code of exp starts with
+// "function" or "{", which would be read as a declaration or a block at the
+// start of a statement. Classes start with "class", or "function" when
+// they are lowered.
+func startsStatementAmbiguously(exp ast.Expression) bool {
+	switch exp := exp.(type) {
+	case *ast.FunctionLiteral, *ast.ObjectLiteral, *ast.ClassLiteral:
+		return true
+	case *ast.CallExpression:
+		return startsStatementAmbiguously(exp.Callee)
+	case *ast.DotExpression:
+		return startsStatementAmbiguously(exp.Left)
+	case *ast.BracketExpression:
+		return startsStatementAmbiguously(exp.Left)
+	case *ast.BinaryExpression:
+		return startsStatementAmbiguously(exp.Left)
+	case *ast.AssignExpression:
+		return startsStatementAmbiguously(exp.Left)
+	case *ast.ConditionalExpression:
+		return startsStatementAmbiguously(exp.Test)
+	case *ast.SequenceExpression:
+		return len(exp.Sequence) > 0 && startsStatementAmbiguously(exp.Sequence[0])
+	case *ast.UnaryExpression:
+		return exp.Postfix && startsStatementAmbiguously(exp.Operand)
+	}
+	return false
+}
+
+// needsSpace reports whether a space must separate code ending in last from
+// code starting with next, so they are not read as one token.
+func needsSpace(last, next rune) bool {
+	switch {
+	case isIdentifierPart(last) && isIdentifierPart(next):
+		return true
+	case last == '+' && next == '+', last == '-' && next == '-':
+		return true
+	case last == '/' && (next == '/' || next == '*'):
+		return true
+	}
+	return false
+}
+
+// separate prefixes s with a space if it cannot directly follow the code
+// written so far.
+func (g *generator) separate(s string) string {
+	if s == "" || g.buffer.Len() == 0 {
+		return s
+	}
+	last, _ := utf8.DecodeLastRune(g.buffer.Bytes())
+	next, _ := utf8.DecodeRuneInString(s)
+	if needsSpace(last, next) {
+		return " " + s
+	}
+	return s
+}
+
+var whitespaceRemover = strings.NewReplacer(" ", "", "\n", "")
+
+// shortNumber returns the shortest literal of the number n.
+func shortNumber(n *ast.NumberLiteral) string {
+	var value float64
+	switch v := n.Value.(type) {
+	case float64:
+		value = v
+	case int64:
+		value = float64(v)
+	default:
+		return n.Literal
+	}
+	if math.IsInf(value, 0) || math.IsNaN(value) || value < 0 {
+		return n.Literal
+	}
+
+	best := n.Literal
+	mantissa, exponent := splitExponent(strconv.FormatFloat(value, 'e', -1, 64))
+	candidates := []string{
+		strconv.FormatFloat(value, 'f', -1, 64),
+		mantissa + "e" + strconv.Itoa(exponent),
+	}
+	for _, c := range candidates {
+		if strings.HasPrefix(c, "0.") {
+			c = c[1:]
+		}
+		if len(c) < len(best) {
+			best = c
+		}
+	}
+	return best
+}
+
+// isInteger reports whether the number literal s has no fraction or
+// exponent, so a "." following it would be read as part of it.
+func isInteger(s string) bool {
+	return strings.IndexAny(s, ".eExXoObB") == -1
+}
+
+func splitExponent(s string) (string, int) {
+	i := strings.IndexByte(s, 'e')
+	exponent, _ := strconv.Atoi(s[i+1:])
+	return s[:i], exponent
+}
+
+// shortString returns the string literal with the quotes that need the
+// fewest escapes.
+func shortString(literal string) string {
+	if len(literal) < 2 {
+		return literal
+	}
+	if swapped := swapQuotes(literal); len(swapped) < len(literal) {
+		return swapped
+	}
+	return literal
+}
+
+// minifyJS returns the code of the bundle runtime in the compact form.
+func minifyJS(src string) string {
+	return formatJS(src, Options{Minify: true})
+}
+
+// formatJS returns the code of the bundle runtime generated with options.
+func formatJS(src string, options Options) string {
+	prog, err := parser.ParseFile(nil, "", src, parser.IgnoreRegExpErrors)
+	if err != nil {
+		return src
+	}
+	gen, err := generate(prog, "", nil, options)
+	if err != nil {
+		return src
+	}
+	code, err := ioutil.ReadAll(gen.code())
+	if err != nil {
+		return src
+	}
+	return string(code)
+}
@@ -0,0 +1,89 @@
+package generator
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/walesey/go-bundle/ast"
+	"github.com/walesey/go-bundle/parser"
+	"gopkg.in/sourcemap.v1"
+)
+
+func TestShortNumber(t *testing.T) {
+	for literal, short := range map[string]string{
+		"0":        "0",
+		"1.50":     "1.5",
+		"0.5":      ".5",
+		"1000000":  "1e6",
+		"100":      "100",
+		"1000":     "1e3",
+		"0.000001": "1e-6",
+		"0xff":     "255",
+		"1e21":     "1e21",
+		"123.456":  "123.456",
+	} {
+		prog, err := parser.ParseFile(nil, "", literal, 0)
+		assert.NoError(t, err)
+		n := prog.Body[0].(*ast.ExpressionStatement).Expression.(*ast.NumberLiteral)
+		assert.Equal(t, short, shortNumber(n), literal)
+	}
+}
+
+func TestShortString(t *testing.T) {
+	assert.Equal(t, `'a'`, shortString(`'a'`))
+	assert.Equal(t, `"a"`, shortString(`"a"`))
+	assert.Equal(t, `"it's"`, shortString(`'it\'s'`))
+	assert.Equal(t, `'say "hi"'`, shortString(`"say \"hi\""`))
+	assert.Equal(t, `'a\n\\'`, shortString(`'a\n\\'`))
+}
+
+func TestLoadMinify(t *testing.T) {
+	load := func(src string, options Options) string {
+		out, err := LoadWithOptions(strings.NewReader(src), options)
+		assert.NoError(t, err)
+		code, err := ioutil.ReadAll(out)
+		assert.NoError(t, err)
+		return string(code)
+	}
+
+	for src, minified := range map[string]string{
+		"var a = (b + c) * d, e = b + (c * d);":   "var a=(b+c)*d,e=b+c*d",
+		"a - (b - c); a - -b; a + ++b; a++ + b;":  "a-(b-c);a- -b;a+ ++b;a++ +b",
+		"x = a ? b : (c ? d : e); (a ? b : c)();": "x=a?b:c?d:e;(a?b:c)()",
+		"(function () { return 1; })();":          "(function(){return 1}())",
+		"({ a: 1 }).a; new (f())(); new (a.b)();": "({a:1}.a);new(f())();new a.b()",
+		"1..toString(); 1.5.toFixed(); 'a' in o;": "1 .toString();1.5.toFixed();'a'in o",
+		"if (a) { b(); } else c(); while (a);":    "if(a){b()}else c();while(a);",
+		"x = /a b/.test(\"it's\"); y = a / /b/;":  "x=/a b/.test(\"it's\");y=a/ /b/",
+	} {
+		assert.Equal(t, minified, load(src, Options{Minify: true}), src)
+	}
+
+	// the minified code is the same program
+	src, err := os.ReadFile("testdata/minify/input.js")
+	assert.NoError(t, err)
+	minified := load(string(src), Options{Minify: true})
+	assert.NotContains(t, minified, "\n")
+	assert.Equal(t, load(string(src), Options{}), load(minified, Options{}))
+}
+
+func TestBundleMinify(t *testing.T) {
+	result, err := Build("./testdata/sourcemap/index.js", Config{Minify: true, SourceMap: SourceMapExternal})
+	assert.NoError(t, err)
+	code := string(result.Code)
+	assert.NotContains(t, code, "\n")
+	assert.NotContains(t, code, "// ")
+	assert.Contains(t, code, "=function(){var exports={},module={exports:exports};exports.compute=function(n){")
+
+	// mappings on the line of the module wrappers
+	consumer, err := sourcemap.Parse("index.js.map", result.SourceMap)
+	assert.NoError(t, err)
+	source, _, line, column, ok := consumer.Source(1, strings.Index(code, "throw"))
+	assert.True(t, ok)
+	assert.Equal(t, "testdata/sourcemap/helper.js", source)
+	assert.Equal(t, 4, line)
+	assert.Equal(t, 4, column)
+}
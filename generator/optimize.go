@@ -0,0 +1,651 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/walesey/go-bundle/ast"
+	"github.com/walesey/go-bundle/file"
+	"github.com/walesey/go-bundle/parser"
+	"github.com/walesey/go-bundle/token"
+)
+
+// defines maps global names, eg. "process.env.NODE_ENV", to the literals
+// they are replaced with.
+type defines map[string]ast.Expression
+
+// parseDefines parses the values of define, which must be string, number,
+// boolean or null literals, eg. `"production"` or `false`.
+func parseDefines(define map[string]string) (defines, error) {
+	d := make(defines, len(define))
+	for name, value := range define {
+		for _, part := range strings.Split(name, ".") {
+			if part == "" || escapeKeyIfRequired(part) != part {
+				return nil, fmt.Errorf("Invalid define %v: not a global name", name)
+			}
+		}
+		prog, err := parser.ParseFile(nil, "", value, 0)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid define %v: %v", name, err)
+		}
+		var literal ast.Expression
+		if len(prog.Body) == 1 {
+			if stmt, ok := prog.Body[0].(*ast.ExpressionStatement); ok && isPrimitive(stmt.Expression) {
+				literal = stmt.Expression
+			}
+		}
+		if literal == nil {
+			return nil, fmt.Errorf("Invalid define %v: %v is not a string, number, boolean or null", name, value)
+		}
+		d[name] = literal
+	}
+	return d, nil
+}
+
+// optimizer rewrites a program, replacing defines and removing the code
+// that is never run.
+type optimizer struct {
+	defines defines
+
+	// deadCode removes unreachable code and unused declarations, and
+	// dropDebugging removes console calls and debugger statements
+	deadCode      bool
+	dropDebugging bool
+
+	// moduleScope is set if the top level names of the program are local
+	// to a module of a bundle, so unused ones can be removed
+	moduleScope bool
+
+	free map[*string]bool
+
+	// unused are the declarations that are removed, and removed counts
+	// the ones removed by a pass
+	unused  map[ast.Node]bool
+	removed int
+}
+
+// newOptimizer returns the optimizer for options, or nil if the options do
+// not change the program.
+func newOptimizer(options Options, moduleScope bool) (*optimizer, error) {
+	if len(options.Define) == 0 && !options.DeadCode && !options.DropDebugging {
+		return nil, nil
+	}
+	o := &optimizer{
+		deadCode:      options.DeadCode,
+		dropDebugging: options.DropDebugging,
+		moduleScope:   moduleScope,
+	}
+	if len(options.Define) > 0 {
+		d, err := parseDefines(options.Define)
+		if err != nil {
+			return nil, err
+		}
+		o.defines = d
+	}
+	return o, nil
+}
+
+// optimize replaces the defines in p, folds the constants that result and
+// removes dead code. Unused declarations are removed until none are left,
+// as removing one may leave others unused.
+func (o *optimizer) optimize(p *ast.Program) {
+	o.free = freeIdentifiers(p)
+	o.program(p)
+	for o.deadCode {
+		o.unused = o.unusedDeclarations(p)
+		if len(o.unused) == 0 {
+			break
+		}
+		o.removed = 0
+		o.program(p)
+		if o.removed == 0 {
+			break
+		}
+	}
+}
+
+func (o *optimizer) program(p *ast.Program) {
+	p.Body = o.statements(p.Body)
+	p.DeclarationList = o.declarations(p.DeclarationList)
+}
+
+func (o *optimizer) statements(list []ast.Statement) []ast.Statement {
+	result := make([]ast.Statement, 0, len(list))
+	unreachable := false
+	for _, stmt := range list {
+		stmt = o.statement(stmt)
+		if unreachable {
+			if vars := hoistedVars(stmt); vars != nil {
+				result = append(result, vars)
+			}
+			continue
+		}
+		switch s := stmt.(type) {
+		case *ast.BlockStatement:
+			if s.LeftBrace == 0 || o.deadCode && len(s.List) == 0 {
+				// the statements left of an if that was removed
+				result = append(result, s.List...)
+				continue
+			}
+		case *ast.EmptyStatement:
+			continue
+		case *ast.ReturnStatement, *ast.ThrowStatement, *ast.BranchStatement:
+			unreachable = o.deadCode
+		}
+		result = append(result, stmt)
+	}
+	return result
+}
+
+// declarations removes the unused function declarations from list.
+func (o *optimizer) declarations(list []ast.Declaration) []ast.Declaration {
+	if len(o.unused) == 0 {
+		return list
+	}
+	result := make([]ast.Declaration, 0, len(list))
+	for _, dcl := range list {
+		if fn, ok := dcl.(*ast.FunctionDeclaration); ok && o.unused[fn.Function] {
+			continue
+		}
+		result = append(result, dcl)
+	}
+	return result
+}
+
+func (o *optimizer) statement(stmt ast.Statement) ast.Statement {
+	switch stmt := stmt.(type) {
+	case *ast.BlockStatement:
+		stmt.List = o.statements(stmt.List)
+	case *ast.CaseStatement:
+		stmt.Test = o.expression(stmt.Test)
+		stmt.Consequent = o.statements(stmt.Consequent)
+	case *ast.CatchStatement:
+		stmt.Body = o.statement(stmt.Body)
+	case *ast.ForOfStatement:
+		stmt.Into = o.target(stmt.Into)
+		stmt.Source = o.expression(stmt.Source)
+		stmt.Body = o.statement(stmt.Body)
+	case *ast.DoWhileStatement:
+		stmt.Body = o.statement(stmt.Body)
+		stmt.Test = o.expression(stmt.Test)
+	case *ast.DebuggerStatement:
+		if o.dropDebugging {
+			return &ast.EmptyStatement{Semicolon: stmt.Debugger}
+		}
+	case *ast.ExpressionStatement:
+		if o.dropDebugging && o.isConsoleCall(stmt.Expression) {
+			return &ast.EmptyStatement{Semicolon: stmt.Idx0()}
+		}
+		stmt.Expression = o.expression(stmt.Expression)
+	case *ast.ForInStatement:
+		stmt.Into = o.target(stmt.Into)
+		stmt.Source = o.expression(stmt.Source)
+		stmt.Body = o.statement(stmt.Body)
+	case *ast.ForStatement:
+		stmt.Initializer = o.expression(stmt.Initializer)
+		stmt.Test = o.expression(stmt.Test)
+		stmt.Update = o.expression(stmt.Update)
+		stmt.Body = o.statement(stmt.Body)
+	case *ast.FunctionStatement:
+		if o.unused[stmt.Function] {
+			o.removed++
+			return &ast.EmptyStatement{Semicolon: stmt.Idx0()}
+		}
+		o.function(stmt.Function)
+	case *ast.ClassStatement:
+		o.class(stmt.Class)
+	case *ast.IfStatement:
+		return o.ifStatement(stmt)
+	case *ast.LabelledStatement:
+		stmt.Statement = o.statement(stmt.Statement)
+	case *ast.ReturnStatement:
+		stmt.Argument = o.expression(stmt.Argument)
+	case *ast.SwitchStatement:
+		stmt.Discriminant = o.expression(stmt.Discriminant)
+		for _, c := range stmt.Body {
+			o.statement(c)
+		}
+	case *ast.ThrowStatement:
+		stmt.Argument = o.expression(stmt.Argument)
+	case *ast.TryStatement:
+		stmt.Body = o.statement(stmt.Body)
+		if stmt.Catch != nil {
+			o.statement(stmt.Catch)
+		}
+		stmt.Finally = o.statement(stmt.Finally)
+	case *ast.VariableStatement:
+		stmt.List = o.variables(stmt.List)
+		if len(stmt.List) == 0 {
+			return &ast.EmptyStatement{Semicolon: stmt.Var}
+		}
+	case *ast.WhileStatement:
+		stmt.Test = o.expression(stmt.Test)
+		stmt.Body = o.statement(stmt.Body)
+	case *ast.WithStatement:
+		stmt.Object = o.expression(stmt.Object)
+		stmt.Body = o.statement(stmt.Body)
+	case *ast.ExportStatement:
+		stmt.Statement = o.statement(stmt.Statement)
+	case *ast.ExportDefaultStatement:
+		stmt.Argument = o.expression(stmt.Argument)
+	}
+	return stmt
+}
+
+// ifStatement removes the branch of an if that is never run. The var
+// declarations in it are kept, as they are hoisted. The statements that are
+// left are returned in a block without braces, which statements adds to its
+// list.
+func (o *optimizer) ifStatement(stmt *ast.IfStatement) ast.Statement {
+	stmt.Test = o.expression(stmt.Test)
+	stmt.Consequent = o.statement(stmt.Consequent)
+	stmt.Alternate = o.statement(stmt.Alternate)
+
+	truthy, ok := truthiness(stmt.Test)
+	if !ok {
+		if o.deadCode {
+			return o.removeEmptyBranches(stmt)
+		}
+		return stmt
+	}
+	taken, removed := stmt.Consequent, stmt.Alternate
+	if !truthy {
+		taken, removed = removed, taken
+	}
+	var list []ast.Statement
+	if block, ok := taken.(*ast.BlockStatement); ok && !blockScoped(block.List) {
+		list = append(list, block.List...)
+	} else if taken != nil {
+		list = append(list, taken)
+	}
+	if vars := hoistedVars(removed); vars != nil {
+		list = append(list, vars)
+	}
+	switch len(list) {
+	case 0:
+		return &ast.EmptyStatement{Semicolon: stmt.If}
+	case 1:
+		return list[0]
+	}
+	return &ast.BlockStatement{List: list}
+}
+
+// blockScoped reports whether list declares names that are local to its
+// block, so its braces are kept: let, const, classes and functions.
+func blockScoped(list []ast.Statement) bool {
+	for _, stmt := range list {
+		if _, ok := stmt.(*ast.FunctionStatement); ok {
+			return true
+		}
+	}
+	return declaresLexically(list)
+}
+
+// hoistedVars returns a declaration of the variables declared in stmt, or
+// nil if it declares none. Function declarations are not included, as they
+// are written where they are hoisted to.
+func hoistedVars(stmt ast.Statement) ast.Statement {
+	if stmt == nil {
+		return nil
+	}
+	m := &mangler{lexical: true, visited: make(map[*ast.FunctionLiteral]bool)}
+	m.scope = newScope(nil, true)
+	m.statement(stmt)
+	functions := make(map[string]bool)
+	for fn := range m.visited {
+		if fn.Name != nil {
+			functions[fn.Name.Name] = true
+		}
+	}
+	var list []ast.Expression
+	for _, b := range m.scope.order {
+		if !functions[b.name] {
+			list = append(list, &ast.VariableExpression{Name: b.name})
+		}
+	}
+	if list == nil {
+		return nil
+	}
+	return &ast.VariableStatement{Var: stmt.Idx0(), List: list}
+}
+
+func (o *optimizer) function(fn *ast.FunctionLiteral) {
+	if fn.ParameterList != nil {
+		o.expressions(fn.ParameterList.Defaults)
+	}
+	fn.Body = o.statement(fn.Body)
+	fn.DeclarationList = o.declarations(fn.DeclarationList)
+}
+
+func (o *optimizer) class(c *ast.ClassLiteral) {
+	c.SuperClass = o.expression(c.SuperClass)
+	for i := range c.Body {
+		c.Body[i].Computed = o.expression(c.Body[i].Computed)
+		o.function(c.Body[i].Value)
+	}
+}
+
+// variables rewrites the declarations of a var statement, removing the
+// unused ones.
+func (o *optimizer) variables(list []ast.Expression) []ast.Expression {
+	result := make([]ast.Expression, 0, len(list))
+	for _, exp := range list {
+		if o.unused[exp] {
+			o.removed++
+			continue
+		}
+		result = append(result, o.expression(exp))
+	}
+	return result
+}
+
+func (o *optimizer) expressions(list []ast.Expression) {
+	for i, exp := range list {
+		list[i] = o.expression(exp)
+	}
+}
+
+// target rewrites an expression that is assigned to. Defines are only
+// replaced in its parts.
+func (o *optimizer) target(exp ast.Expression) ast.Expression {
+	switch exp := exp.(type) {
+	case *ast.DotExpression:
+		exp.Left = o.expression(exp.Left)
+	case *ast.BracketExpression:
+		exp.Left = o.expression(exp.Left)
+		exp.Member = o.expression(exp.Member)
+	case *ast.Identifier:
+	default:
+		return o.expression(exp)
+	}
+	return exp
+}
+
+func (o *optimizer) expression(exp ast.Expression) ast.Expression {
+	if literal := o.define(exp); literal != nil {
+		return literal
+	}
+	switch exp := exp.(type) {
+	case *ast.ArrayLiteral:
+		o.expressions(exp.Value)
+	case *ast.AssignExpression:
+		exp.Left = o.target(exp.Left)
+		exp.Right = o.expression(exp.Right)
+	case *ast.BinaryExpression:
+		exp.Left = o.expression(exp.Left)
+		exp.Right = o.expression(exp.Right)
+		return foldBinary(exp)
+	case *ast.BracketExpression:
+		exp.Left = o.expression(exp.Left)
+		exp.Member = o.expression(exp.Member)
+	case *ast.CallExpression:
+		if o.dropDebugging && o.isConsoleCall(exp) {
+			return newUndefined(exp.Idx0())
+		}
+		exp.Callee = o.expression(exp.Callee)
+		o.expressions(exp.ArgumentList)
+	case *ast.ConditionalExpression:
+		exp.Test = o.expression(exp.Test)
+		exp.Consequent = o.expression(exp.Consequent)
+		exp.Alternate = o.expression(exp.Alternate)
+		if truthy, ok := truthiness(exp.Test); ok {
+			if truthy {
+				return exp.Consequent
+			}
+			return exp.Alternate
+		}
+	case *ast.DotExpression:
+		exp.Left = o.expression(exp.Left)
+	case *ast.FunctionLiteral:
+		o.function(exp)
+	case *ast.ClassLiteral:
+		o.class(exp)
+	case *ast.AwaitExpression:
+		exp.Argument = o.expression(exp.Argument)
+	case *ast.NewExpression:
+		exp.Callee = o.expression(exp.Callee)
+		o.expressions(exp.ArgumentList)
+	case *ast.ObjectLiteral:
+		for i := range exp.Value {
+			exp.Value[i].Computed = o.expression(exp.Value[i].Computed)
+			exp.Value[i].Value = o.expression(exp.Value[i].Value)
+		}
+	case *ast.SequenceExpression:
+		o.expressions(exp.Sequence)
+	case *ast.UnaryExpression:
+		switch exp.Operator {
+		case token.INCREMENT, token.DECREMENT, token.DELETE:
+			exp.Operand = o.target(exp.Operand)
+		default:
+			exp.Operand = o.expression(exp.Operand)
+		}
+		return foldUnary(exp)
+	case *ast.VariableExpression:
+		exp.Initializer = o.expression(exp.Initializer)
+	case *ast.DynamicStringExpression:
+		o.expressions(exp.List)
+	case *ast.JSXBlock:
+		for i := range exp.OpeningElement.PropertyList {
+			p := &exp.OpeningElement.PropertyList[i]
+			p.Value = o.expression(p.Value)
+		}
+		o.expressions(exp.Body)
+	case *ast.JSXExpression:
+		exp.Identifier = o.expression(exp.Identifier)
+	}
+	return exp
+}
+
+// define returns the literal replacing exp, or nil if exp is not a define.
+func (o *optimizer) define(exp ast.Expression) ast.Expression {
+	if len(o.defines) == 0 {
+		return nil
+	}
+	name, ok := o.globalName(exp)
+	if !ok {
+		return nil
+	}
+	literal, ok := o.defines[name]
+	if !ok {
+		return nil
+	}
+	return copyLiteral(literal, exp.Idx0())
+}
+
+// globalName returns the dotted name of a global, eg. "process.env.NODE_ENV"
+// for process.env.NODE_ENV or process.env["NODE_ENV"].
+func (o *optimizer) globalName(exp ast.Expression) (string, bool) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		return exp.Name, o.free[&exp.Name]
+	case *ast.DotExpression:
+		left, ok := o.globalName(exp.Left)
+		return left + "." + exp.Identifier.Name, ok
+	case *ast.BracketExpression:
+		if member, ok := exp.Member.(*ast.StringLiteral); ok {
+			left, ok := o.globalName(exp.Left)
+			return left + "." + member.Value, ok
+		}
+	}
+	return "", false
+}
+
+// copyLiteral returns a copy of the literal exp at idx, so the same node is
+// not shared by several parts of the program.
+func copyLiteral(exp ast.Expression, idx file.Idx) ast.Expression {
+	switch exp := exp.(type) {
+	case *ast.StringLiteral:
+		c := *exp
+		c.Idx = idx
+		return &c
+	case *ast.NumberLiteral:
+		c := *exp
+		c.Idx = idx
+		return &c
+	case *ast.BooleanLiteral:
+		c := *exp
+		c.Idx = idx
+		return &c
+	case *ast.NullLiteral:
+		c := *exp
+		c.Idx = idx
+		return &c
+	}
+	return exp
+}
+
+// isPrimitive reports whether exp is a string, number, boolean or null
+// literal, which has no side effects.
+func isPrimitive(exp ast.Expression) bool {
+	switch exp.(type) {
+	case *ast.StringLiteral, *ast.NumberLiteral, *ast.BooleanLiteral, *ast.NullLiteral:
+		return true
+	}
+	return false
+}
+
+// truthiness reports whether the primitive literal exp is truthy. ok is
+// false if exp is not a primitive literal.
+func truthiness(exp ast.Expression) (truthy bool, ok bool) {
+	switch exp := exp.(type) {
+	case *ast.StringLiteral:
+		return exp.Value != "", true
+	case *ast.NumberLiteral:
+		n, ok := numberValue(exp)
+		return ok && n != 0 && !math.IsNaN(n), ok
+	case *ast.BooleanLiteral:
+		return exp.Value, true
+	case *ast.NullLiteral:
+		return false, true
+	}
+	return false, false
+}
+
+func numberValue(n *ast.NumberLiteral) (float64, bool) {
+	switch v := n.Value.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func foldUnary(exp *ast.UnaryExpression) ast.Expression {
+	switch exp.Operator {
+	case token.NOT:
+		if truthy, ok := truthiness(exp.Operand); ok {
+			return newBoolean(!truthy, exp.Idx)
+		}
+	case token.TYPEOF:
+		var typ string
+		switch exp.Operand.(type) {
+		case *ast.StringLiteral:
+			typ = "string"
+		case *ast.NumberLiteral:
+			typ = "number"
+		case *ast.BooleanLiteral:
+			typ = "boolean"
+		case *ast.NullLiteral:
+			typ = "object"
+		default:
+			return exp
+		}
+		return newString(typ, exp.Idx)
+	}
+	return exp
+}
+
+func foldBinary(exp *ast.BinaryExpression) ast.Expression {
+	switch exp.Operator {
+	case token.LOGICAL_AND, token.LOGICAL_OR:
+		truthy, ok := truthiness(exp.Left)
+		if !ok {
+			return exp
+		}
+		if truthy == (exp.Operator == token.LOGICAL_AND) {
+			return exp.Right
+		}
+		return exp.Left
+	case token.NULLISH_COALESCING:
+		if !isPrimitive(exp.Left) {
+			return exp
+		}
+		if _, ok := exp.Left.(*ast.NullLiteral); ok {
+			return exp.Right
+		}
+		return exp.Left
+	case token.STRICT_EQUAL, token.STRICT_NOT_EQUAL, token.EQUAL, token.NOT_EQUAL:
+		equal, ok := primitiveEqual(exp.Left, exp.Right)
+		strict := exp.Operator == token.STRICT_EQUAL || exp.Operator == token.STRICT_NOT_EQUAL
+		if !ok && strict && isPrimitive(exp.Left) && isPrimitive(exp.Right) &&
+			reflect.TypeOf(exp.Left) != reflect.TypeOf(exp.Right) {
+			// values of different types are never strictly equal
+			equal, ok = false, true
+		}
+		if !ok {
+			return exp
+		}
+		if exp.Operator == token.STRICT_NOT_EQUAL || exp.Operator == token.NOT_EQUAL {
+			equal = !equal
+		}
+		return newBoolean(equal, exp.Idx0())
+	case token.PLUS:
+		left, ok := exp.Left.(*ast.StringLiteral)
+		right, ok2 := exp.Right.(*ast.StringLiteral)
+		if ok && ok2 {
+			return newString(left.Value+right.Value, left.Idx)
+		}
+	}
+	return exp
+}
+
+// primitiveEqual compares two primitive literals of the same type, for
+// which == and === are the same. ok is false if they cannot be compared.
+func primitiveEqual(a, b ast.Expression) (equal bool, ok bool) {
+	switch a := a.(type) {
+	case *ast.StringLiteral:
+		if b, ok := b.(*ast.StringLiteral); ok {
+			return a.Value == b.Value, true
+		}
+	case *ast.NumberLiteral:
+		if b, ok := b.(*ast.NumberLiteral); ok {
+			x, okA := numberValue(a)
+			y, okB := numberValue(b)
+			return x == y, okA && okB
+		}
+	case *ast.BooleanLiteral:
+		if b, ok := b.(*ast.BooleanLiteral); ok {
+			return a.Value == b.Value, true
+		}
+	case *ast.NullLiteral:
+		_, ok := b.(*ast.NullLiteral)
+		return ok, ok
+	}
+	return false, false
+}
+
+func newBoolean(value bool, idx file.Idx) *ast.BooleanLiteral {
+	literal := "false"
+	if value {
+		literal = "true"
+	}
+	return &ast.BooleanLiteral{Idx: idx, Literal: literal, Value: value}
+}
+
+func newString(value string, idx file.Idx) *ast.StringLiteral {
+	return &ast.StringLiteral{Idx: idx, Literal: quoteString(value), Value: value}
+}
+
+// quoteString returns s as a string literal.
+func quoteString(s string) string {
+	var quoted bytes.Buffer
+	enc := json.NewEncoder(&quoted)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSpace(quoted.String())
+}
@@ -0,0 +1,81 @@
+package generator
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadDefine(t *testing.T) {
+	define := map[string]string{
+		"process.env.NODE_ENV": `"production"`,
+		"__DEV__":              "false",
+		"VERSION":              "2",
+	}
+	load := func(src string) string {
+		out, err := LoadWithOptions(strings.NewReader(src), Options{Define: define, Minify: true})
+		assert.NoError(t, err)
+		code, err := ioutil.ReadAll(out)
+		assert.NoError(t, err)
+		return string(code)
+	}
+
+	for src, optimized := range map[string]string{
+		// replaced names
+		"a(process.env.NODE_ENV, process.env['NODE_ENV'], __DEV__, VERSION);": `a("production","production",false,2)`,
+		"a(process.env.OTHER, process.env);":                                  "a(process.env.OTHER,process.env)",
+
+		// only globals are replaced, and not when they are assigned to
+		"function f(__DEV__, process) { return __DEV__ + process.env.NODE_ENV; }": "function f(__DEV__,process){return __DEV__+process.env.NODE_ENV}",
+		"__DEV__ = true; process.env.NODE_ENV = 'test'; __DEV__++;":               "__DEV__=true;process.env.NODE_ENV='test';__DEV__++",
+
+		// folded conditions
+		"if (process.env.NODE_ENV !== 'production') { a(); } else { b(); }": "b()",
+		"if (process.env.NODE_ENV === 'production') { a(); }":               "a()",
+		"if (!__DEV__) a(); c();":                                           "a();c()",
+		"if (__DEV__) { a(); } c();":                                        "c()",
+		"if (__DEV__) a(); else if (VERSION == 2) b(); else c();":           "b()",
+		"x = __DEV__ && a(); y = __DEV__ || a(); z = VERSION && a();":       "x=false;y=a();z=a()",
+		"x = __DEV__ ? a() : b(); y = typeof __DEV__ + '!';":                `x=b();y="boolean!"`,
+		"x = VERSION === '2'; y = null == null;":                            "x=false;y=true",
+
+		// hoisted declarations of removed branches are kept
+		"function f() { if (__DEV__) { var a = 1, b; function g() {} } return a; }": "function f(){var a,b;return a;function g(){}}",
+	} {
+		assert.Equal(t, optimized, load(src), src)
+	}
+
+	// the block of a branch that declares block scoped names is kept, and
+	// they are not hoisted from removed branches
+	out, err := LoadWithOptions(strings.NewReader("let x = 1; if (!__DEV__) { let x = 2; a(x); } else { const y = 3; } b(x);"),
+		Options{Define: define, Minify: true, Target: TargetES2015})
+	assert.NoError(t, err)
+	code, err := ioutil.ReadAll(out)
+	assert.NoError(t, err)
+	assert.Equal(t, "let x=1;{let x=2;a(x)}b(x)", string(code))
+
+	for name, value := range map[string]string{
+		"process.env.": `"x"`,
+		"a-b":          `"x"`,
+		"__DEV__":      "a()",
+		"VERSION":      "'",
+	} {
+		_, err := LoadWithOptions(strings.NewReader("a;"), Options{Define: map[string]string{name: value}})
+		assert.Error(t, err, name)
+	}
+}
+
+func TestBundleDefine(t *testing.T) {
+	result, err := Build("./testdata/define/index.js", Config{
+		Define: map[string]string{"process.env.NODE_ENV": `"production"`, "__DEV__": "false"},
+	})
+	assert.NoError(t, err)
+	code := string(result.Code)
+	assert.NotContains(t, code, "development build")
+	assert.Contains(t, code, "module.exports = 'prod';")
+
+	// the process shim is only added if process is still used
+	assert.NotContains(t, code, "var process")
+}
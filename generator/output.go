@@ -0,0 +1,157 @@
+package generator
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// OutputConfig controls how Write stores the output of a build.
+type OutputConfig struct {
+	// Dir is the directory the files are written to.
+	Dir string
+
+	// EntryNames is the file name of the javascript bundle. "[name]" is
+	// replaced with the name of the entry file without its extension, and
+	// "[hash]" with a hash of the contents. The default is "[name].js".
+	EntryNames string
+
+	// AssetNames is the file name of each emitted asset. "[dir]" is
+	// replaced with the directory of the asset name including the trailing
+	// slash, "[name]" with the base name without extension, "[ext]" with the
+	// extension including the dot, and "[hash]" with a hash of the contents.
+	// The default is "[dir][name][ext]".
+	AssetNames string
+
+	// Manifest is the name of the manifest file written to Dir. The default
+	// is "manifest.json". Set to "-" to not write a manifest.
+	Manifest string
+}
+
+// Manifest maps the logical names of the outputs of a build, eg. "index.js"
+// or "styles.css", to the files written.
+type Manifest map[string]ManifestEntry
+
+// ManifestEntry describes a file written by Write.
+type ManifestEntry struct {
+	// File is the slash separated path of the file relative to Dir.
+	File string `json:"file"`
+
+	// Size is the size of the file in bytes.
+	Size int `json:"size"`
+
+	// Integrity is the subresource integrity hash of the file.
+	Integrity string `json:"integrity"`
+}
+
+// Write writes the bundle and its assets to the output directory, and
+// returns the manifest describing them.
+func (r *Result) Write(config OutputConfig) (Manifest, error) {
+	if config.EntryNames == "" {
+		config.EntryNames = "[name].js"
+	}
+	if config.AssetNames == "" {
+		config.AssetNames = "[dir][name][ext]"
+	}
+	if config.Manifest == "" {
+		config.Manifest = "manifest.json"
+	}
+
+	manifest := Manifest{}
+	write := func(logicalName, name string, data []byte) error {
+		manifest[logicalName] = ManifestEntry{
+			File:      name,
+			Size:      len(data),
+			Integrity: integrity(data),
+		}
+		path, err := outputPath(config.Dir, name)
+		if err != nil {
+			return err
+		}
+		return writeFile(path, data)
+	}
+
+	// the hash in the name of the bundle does not include the link to its
+	// source map, which is named after the bundle
+	code := r.Code
+	codeName := outputName(config.EntryNames, r.Name+".js", r.Code)
+	if r.SourceMap != nil {
+		mapName := codeName + ".map"
+		code = append(append([]byte{}, code...), sourceMappingURL(path.Base(mapName))...)
+		if err := write(r.Name+".js.map", mapName, r.SourceMap); err != nil {
+			return nil, err
+		}
+	}
+	if err := write(r.Name+".js", codeName, code); err != nil {
+		return nil, err
+	}
+
+	for _, asset := range r.Assets {
+		if err := write(asset.Name, outputName(config.AssetNames, asset.Name, asset.Data), asset.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.Manifest != "-" {
+		data, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := writeFile(filepath.Join(config.Dir, config.Manifest), append(data, '\n')); err != nil {
+			return nil, err
+		}
+	}
+	return manifest, nil
+}
+
+// outputName fills in the placeholders of an output file name pattern for
+// the file with the logical name and contents data.
+func outputName(pattern, logicalName string, data []byte) string {
+	dir, base := path.Split(logicalName)
+	ext := path.Ext(base)
+	name := strings.TrimSuffix(base, ext)
+	replacer := strings.NewReplacer(
+		"[dir]", dir,
+		"[name]", name,
+		"[ext]", ext,
+		"[hash]", contentHash(data),
+	)
+	return replacer.Replace(pattern)
+}
+
+// contentHash returns a short hash of data for use in file names.
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:8]
+}
+
+// integrity returns the subresource integrity hash of data.
+func integrity(data []byte) string {
+	sum := sha512.Sum384(data)
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// outputPath returns the path of the file with the slash separated name in
+// dir. Names that lead out of dir are an error.
+func outputPath(dir, name string) (string, error) {
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	rel, err := filepath.Rel(filepath.Join(dir, "."), path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("Invalid output name %v: outside of the output directory", name)
+	}
+	return path, nil
+}
+
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,66 @@
+package generator
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResultWrite(t *testing.T) {
+	result := &Result{
+		Name: "index",
+		Code: []byte("require('m1');"),
+		Assets: []Asset{
+			{Name: "styles.css", Data: []byte(".a {}")},
+			{Name: "images/logo.svg", Data: []byte("<svg></svg>")},
+		},
+	}
+
+	dir := t.TempDir()
+	manifest, err := result.Write(OutputConfig{Dir: dir})
+	assert.NoError(t, err)
+	for _, name := range []string{"index.js", "styles.css", "images/logo.svg"} {
+		assert.Equal(t, name, manifest[name].File)
+		assert.FileExists(t, filepath.Join(dir, name))
+	}
+
+	sum := sha512.Sum384([]byte(".a {}"))
+	assert.Equal(t, ManifestEntry{
+		File:      "styles.css",
+		Size:      5,
+		Integrity: "sha384-" + base64.StdEncoding.EncodeToString(sum[:]),
+	}, manifest["styles.css"])
+
+	data, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	assert.NoError(t, err)
+	var written Manifest
+	assert.NoError(t, json.Unmarshal(data, &written))
+	assert.Equal(t, manifest, written)
+
+	// content hashed names
+	dir = t.TempDir()
+	manifest, err = result.Write(OutputConfig{
+		Dir:        dir,
+		EntryNames: "js/[name].[hash].js",
+		AssetNames: "assets/[name].[hash][ext]",
+		Manifest:   "-",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "js/index."+contentHash(result.Code)+".js", manifest["index.js"].File)
+	assert.Equal(t, "assets/logo."+contentHash([]byte("<svg></svg>"))+".svg", manifest["images/logo.svg"].File)
+	assert.FileExists(t, filepath.Join(dir, "js/index."+contentHash(result.Code)+".js"))
+	assert.NoFileExists(t, filepath.Join(dir, "manifest.json"))
+
+	// assets cannot be written outside of the output directory
+	dir = filepath.Join(t.TempDir(), "out")
+	result.Assets = []Asset{{Name: "../escaped.css", Data: []byte(".a {}")}}
+	_, err = result.Write(OutputConfig{Dir: dir})
+	assert.EqualError(t, err, "Invalid output name ../escaped.css: outside of the output directory")
+	assert.EqualError(t, result.WriteAssets(dir), "Invalid output name ../escaped.css: outside of the output directory")
+	assert.NoFileExists(t, filepath.Join(dir, "../escaped.css"))
+}
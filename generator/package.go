@@ -0,0 +1,276 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// jsonObject is a decoded JSON object that remembers the order of its keys.
+// The order matters for the conditions in a package.json "exports" map.
+type jsonObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (o *jsonObject) get(key string) (interface{}, bool) {
+	v, ok := o.values[key]
+	return v, ok
+}
+
+type packageJSON struct {
+	dir     string
+	name    string
+	fields  *jsonObject
+	exports interface{}
+	browser *jsonObject
+}
+
+// parsePackageJSON parses the contents of the package.json in dir.
+func parsePackageJSON(dir string, data []byte) (*packageJSON, error) {
+	value, err := decodeOrderedJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid package.json in %v: %v", dir, err)
+	}
+
+	fields, ok := value.(*jsonObject)
+	if !ok {
+		return nil, fmt.Errorf("Invalid package.json in %v: expected an object", dir)
+	}
+
+	pkg := &packageJSON{dir: dir, fields: fields}
+	if name, ok := fields.values["name"].(string); ok {
+		pkg.name = name
+	}
+	if exports, ok := fields.get("exports"); ok {
+		pkg.exports = exports
+	}
+	if browser, ok := fields.values["browser"].(*jsonObject); ok {
+		pkg.browser = browser
+	}
+	return pkg, nil
+}
+
+// mainField returns the entry point named by the first of fields that is
+// set to a string.
+func (pkg *packageJSON) mainField(fields []string) (string, bool) {
+	for _, field := range fields {
+		if main, ok := pkg.fields.values[field].(string); ok && main != "" {
+			return main, true
+		}
+	}
+	return "", false
+}
+
+// resolveExports maps a package subpath ("." or "./name") to a file using
+// the package.json "exports" field, following the algorithm used by Node.js.
+func (pkg *packageJSON) resolveExports(subpath string, conditions []string) (string, error) {
+	exports := pkg.exports
+
+	if obj, ok := exports.(*jsonObject); ok {
+		subpathKeys := 0
+		for _, key := range obj.keys {
+			if strings.HasPrefix(key, ".") {
+				subpathKeys++
+			}
+		}
+		if subpathKeys > 0 && subpathKeys != len(obj.keys) {
+			return "", fmt.Errorf("Invalid package configuration in %v: \"exports\" cannot mix subpaths and conditions", pkg.dir)
+		}
+		if subpathKeys > 0 {
+			return pkg.resolveExportsSubpath(obj, subpath, conditions)
+		}
+	}
+
+	// strings, arrays and condition objects only define the main export
+	if subpath == "." {
+		if target, err := pkg.resolveExportsTarget(exports, "", conditions); err != nil || target != "" {
+			return target, err
+		}
+	}
+	return "", fmt.Errorf("Package subpath '%v' is not defined by \"exports\" in %v", subpath, pkg.dir)
+}
+
+func (pkg *packageJSON) resolveExportsSubpath(exports *jsonObject, subpath string, conditions []string) (string, error) {
+	target, found := exports.get(subpath)
+	match := ""
+
+	if !found || strings.Contains(subpath, "*") {
+		// find the most specific pattern mapping, eg. "./lib/*"
+		found = false
+		bestKey := ""
+		for _, key := range exports.keys {
+			star := strings.Index(key, "*")
+			if star == -1 || strings.Count(key, "*") > 1 {
+				continue
+			}
+			prefix, suffix := key[:star], key[star+1:]
+			if strings.HasPrefix(subpath, prefix) && subpath != prefix &&
+				strings.HasSuffix(subpath, suffix) && len(subpath) >= len(key) &&
+				patternKeyCompare(bestKey, key) > 0 {
+				bestKey = key
+				match = subpath[len(prefix) : len(subpath)-len(suffix)]
+			}
+		}
+		if bestKey != "" {
+			target, found = exports.values[bestKey], true
+		}
+	}
+
+	if found {
+		resolved, err := pkg.resolveExportsTarget(target, match, conditions)
+		if err != nil || resolved != "" {
+			return resolved, err
+		}
+	}
+	return "", fmt.Errorf("Package subpath '%v' is not defined by \"exports\" in %v", subpath, pkg.dir)
+}
+
+// patternKeyCompare orders "exports" pattern keys by specificity: it returns
+// a positive number if b is more specific than a.
+func patternKeyCompare(a, b string) int {
+	if a == "" {
+		return 1
+	}
+	aBase, bBase := strings.Index(a, "*")+1, strings.Index(b, "*")+1
+	if aBase != bBase {
+		return bBase - aBase
+	}
+	return len(b) - len(a)
+}
+
+// resolveExportsTarget resolves a single "exports" target, which may be a
+// path, an array of fallbacks, a conditions object or null. match replaces
+// the "*" in pattern targets. An empty result with no error means the target
+// did not match.
+func (pkg *packageJSON) resolveExportsTarget(target interface{}, match string, conditions []string) (string, error) {
+	switch target := target.(type) {
+	case string:
+		if !strings.HasPrefix(target, "./") {
+			return "", fmt.Errorf("Invalid \"exports\" target '%v' in %v", target, pkg.dir)
+		}
+		if match != "" {
+			target = strings.Replace(target, "*", match, -1)
+		}
+		resolved := filepath.Join(pkg.dir, filepath.FromSlash(target))
+		if rel, err := filepath.Rel(pkg.dir, resolved); err != nil || strings.HasPrefix(rel, "..") {
+			return "", fmt.Errorf("Invalid \"exports\" target '%v' in %v", target, pkg.dir)
+		}
+		return resolved, nil
+
+	case []interface{}:
+		var lastErr error
+		for _, fallback := range target {
+			resolved, err := pkg.resolveExportsTarget(fallback, match, conditions)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			if resolved != "" {
+				return resolved, nil
+			}
+		}
+		return "", lastErr
+
+	case *jsonObject:
+		for _, key := range target.keys {
+			if key != "default" && !containsString(conditions, key) {
+				continue
+			}
+			resolved, err := pkg.resolveExportsTarget(target.values[key], match, conditions)
+			if err != nil || resolved != "" {
+				return resolved, err
+			}
+		}
+		return "", nil
+
+	case nil:
+		return "", nil
+	}
+
+	return "", fmt.Errorf("Invalid \"exports\" target in %v", pkg.dir)
+}
+
+// browserReplacement looks up key in the package.json "browser" map. The
+// result is false if the module is replaced with an empty module.
+func (pkg *packageJSON) browserReplacement(key string) (interface{}, bool) {
+	if pkg == nil || pkg.browser == nil {
+		return nil, false
+	}
+	value, ok := pkg.browser.get(key)
+	if !ok {
+		return nil, false
+	}
+	switch value.(type) {
+	case string, bool:
+		return value, true
+	}
+	return nil, false
+}
+
+// decodeOrderedJSON decodes data like json.Unmarshal into an interface{},
+// except that objects are decoded as *jsonObject.
+func decodeOrderedJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	value, err := decodeJSONValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err == nil {
+		return nil, fmt.Errorf("unexpected data after top-level value")
+	}
+	return value, nil
+}
+
+func decodeJSONValue(dec *json.Decoder) (interface{}, error) {
+	tkn, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tkn {
+	case json.Delim('{'):
+		obj := &jsonObject{values: make(map[string]interface{})}
+		for dec.More() {
+			keyTkn, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := keyTkn.(string)
+			value, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := obj.values[key]; !ok {
+				obj.keys = append(obj.keys, key)
+			}
+			obj.values[key] = value
+		}
+		_, err := dec.Token()
+		return obj, err
+	case json.Delim('['):
+		list := []interface{}{}
+		for dec.More() {
+			value, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err := dec.Token()
+		return list, err
+	}
+
+	return tkn, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
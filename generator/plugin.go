@@ -0,0 +1,157 @@
+package generator
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/walesey/go-bundle/ast"
+)
+
+// Plugin extends the bundler. Plugins implement one or more of
+// ResolvePlugin, LoadPlugin, TransformPlugin and BuildEndPlugin, and their
+// hooks run in the order the plugins are listed in Config.Plugins.
+type Plugin interface {
+	// Name identifies the plugin in errors.
+	Name() string
+}
+
+// ResolveArgs describes an import being resolved.
+type ResolveArgs struct {
+	// Path is the import specifier, eg. "./button" or "react".
+	Path string
+
+	// Importer is the path of the importing module, or empty for the entry.
+	Importer string
+
+	// Kind is "import" for import statements and "require" for calls to
+	// require.
+	Kind string
+}
+
+// ResolvePlugin resolves imports before the default resolver. The first
+// plugin that returns ok decides the path. A path that is not absolute
+// names a virtual module, which must be loaded by a LoadPlugin.
+type ResolvePlugin interface {
+	Plugin
+	Resolve(args ResolveArgs) (path string, ok bool, err error)
+}
+
+// LoadArgs describes a module being loaded.
+type LoadArgs struct {
+	// Path is the resolved path of the module.
+	Path string
+
+	// Query is the resource query of the import, eg. "?raw".
+	Query string
+}
+
+// LoadResult is the source of a module returned by a LoadPlugin.
+type LoadResult struct {
+	Contents []byte
+
+	// Type overrides the module type chosen by the loader rules.
+	Type ModuleType
+
+	// SourceMap is the JSON source map of Contents, if it was compiled from
+	// other sources.
+	SourceMap []byte
+}
+
+// LoadPlugin reads modules instead of the bundler. The first plugin that
+// returns a result provides the source, which is then transformed by the
+// matching loader rule.
+type LoadPlugin interface {
+	Plugin
+	Load(args LoadArgs) (*LoadResult, error)
+}
+
+// TransformPlugin changes the syntax tree of each javascript module before
+// the code is generated.
+type TransformPlugin interface {
+	Plugin
+	Transform(program *ast.Program, path string) error
+}
+
+// BuildEndPlugin inspects or rewrites the output of the build.
+type BuildEndPlugin interface {
+	Plugin
+	BuildEnd(result *Result) error
+}
+
+// PluginError is returned when a plugin hook fails.
+type PluginError struct {
+	Plugin string
+	Hook   string
+	Err    error
+}
+
+func (e *PluginError) Error() string {
+	return fmt.Sprintf("Plugin %v failed in %v: %v", e.Plugin, e.Hook, e.Err)
+}
+
+func (kind importKind) String() string {
+	if kind == kindRequire {
+		return "require"
+	}
+	return "import"
+}
+
+// isVirtualPath reports whether path was resolved by a plugin to a module
+// that is not a file.
+func isVirtualPath(path string) bool {
+	return !filepath.IsAbs(path) && !isInternalPath(path)
+}
+
+func (bundle *_bundle) pluginResolve(importValue, currentPath string, kind importKind) (string, bool, error) {
+	args := ResolveArgs{Path: importValue, Importer: currentPath, Kind: kind.String()}
+	for _, plugin := range bundle.config.Plugins {
+		if resolver, ok := plugin.(ResolvePlugin); ok {
+			path, ok, err := resolver.Resolve(args)
+			if err != nil {
+				return "", false, &PluginError{Plugin: plugin.Name(), Hook: "resolve", Err: err}
+			}
+			if ok {
+				return path, true, nil
+			}
+		}
+	}
+	return "", false, nil
+}
+
+func (bundle *_bundle) pluginLoad(path, query string) (*LoadResult, error) {
+	args := LoadArgs{Path: path, Query: query}
+	for _, plugin := range bundle.config.Plugins {
+		if loader, ok := plugin.(LoadPlugin); ok {
+			result, err := loader.Load(args)
+			if err != nil {
+				return nil, &PluginError{Plugin: plugin.Name(), Hook: "load", Err: err}
+			}
+			if result != nil {
+				return result, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (bundle *_bundle) pluginTransform(program *ast.Program, path string) error {
+	for _, plugin := range bundle.config.Plugins {
+		if transformer, ok := plugin.(TransformPlugin); ok {
+			if err := transformer.Transform(program, path); err != nil {
+				return &PluginError{Plugin: plugin.Name(), Hook: "transform", Err: err}
+			}
+		}
+	}
+	return nil
+}
+
+func (bundle *_bundle) pluginBuildEnd(result *Result) error {
+	for _, plugin := range bundle.config.Plugins {
+		if buildEnd, ok := plugin.(BuildEndPlugin); ok {
+			if err := buildEnd.BuildEnd(result); err != nil {
+				return &PluginError{Plugin: plugin.Name(), Hook: "build end", Err: err}
+			}
+		}
+	}
+	return nil
+}
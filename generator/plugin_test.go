@@ -0,0 +1,91 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/walesey/go-bundle/ast"
+	"github.com/walesey/go-bundle/parser"
+)
+
+type testPlugin struct {
+	name  string
+	calls *[]string
+	err   error
+}
+
+func (p testPlugin) Name() string {
+	return p.name
+}
+
+func (p testPlugin) Resolve(args ResolveArgs) (string, bool, error) {
+	*p.calls = append(*p.calls, p.name+" resolve "+args.Path+" "+args.Kind)
+	if strings.HasPrefix(args.Path, "virtual:") {
+		return args.Path, true, p.err
+	}
+	return "", false, nil
+}
+
+func (p testPlugin) Load(args LoadArgs) (*LoadResult, error) {
+	*p.calls = append(*p.calls, p.name+" load")
+	if args.Path == "virtual:routes" {
+		return &LoadResult{Contents: []byte("module.exports = ['/home', '/about'];")}, nil
+	}
+	return nil, nil
+}
+
+func (p testPlugin) Transform(program *ast.Program, path string) error {
+	*p.calls = append(*p.calls, p.name+" transform")
+	extra, err := parser.ParseFile(nil, "", fmt.Sprintf("exports.%v = true;", p.name), 0)
+	if err != nil {
+		return err
+	}
+	program.Body = append(program.Body, extra.Body...)
+	return nil
+}
+
+type buildEndPlugin struct{}
+
+func (buildEndPlugin) Name() string {
+	return "banner"
+}
+
+func (buildEndPlugin) BuildEnd(result *Result) error {
+	result.Code = append([]byte("/* banner */"), result.Code...)
+	return nil
+}
+
+func TestPlugins(t *testing.T) {
+	var calls []string
+	result, err := Build("testdata/plugins/index.js", Config{Plugins: []Plugin{
+		testPlugin{name: "first", calls: &calls},
+		testPlugin{name: "second", calls: &calls},
+		buildEndPlugin{},
+	}})
+	assert.NoError(t, err)
+
+	code := string(result.Code)
+	assert.True(t, strings.HasPrefix(code, "/* banner */"))
+	assert.Contains(t, code, "// virtual:routes\n")
+	assert.Contains(t, code, "module.exports = ['/home', '/about'];")
+	assert.Contains(t, code, "exports.first = true;\nexports.second = true;")
+
+	// virtual modules are resolved by the first plugin, files are passed on
+	assert.Contains(t, calls, "first resolve virtual:routes require")
+	assert.NotContains(t, calls, "second resolve virtual:routes require")
+	assert.Contains(t, calls, "second resolve ./page.js require")
+
+	// errors name the plugin
+	_, err = Build("testdata/plugins/index.js", Config{Plugins: []Plugin{
+		testPlugin{name: "broken", calls: &calls, err: fmt.Errorf("no routes")},
+	}})
+	if assert.Error(t, err) {
+		pluginErr, ok := err.(*PluginError)
+		if assert.True(t, ok, err.Error()) {
+			assert.Equal(t, "broken", pluginErr.Plugin)
+			assert.Equal(t, "resolve", pluginErr.Hook)
+		}
+	}
+}
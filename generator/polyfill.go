@@ -0,0 +1,809 @@
+package generator
+
+import (
+	"strings"
+
+	"github.com/walesey/go-bundle/ast"
+)
+
+// polyfill implements a built-in for engines that lack it.
+type polyfill struct {
+	// name is the global, static method or method, eg. "Promise",
+	// "Array.from" or "Array.prototype.includes"
+	name string
+
+	// version is the version of JavaScript that added the built-in
+	version int
+
+	source string
+}
+
+// polyfills are the built-ins that are polyfilled, in the order they are
+// written to the bundle.
+var polyfills = []polyfill{
+	{"Symbol", es2015, symbolPolyfill},
+	{"Promise", es2015, promisePolyfill},
+	{"Map", es2015, mapPolyfill},
+	{"Set", es2015, setPolyfill},
+	{"WeakMap", es2015, weakMapPolyfill},
+	{"Object.assign", es2015, objectAssignPolyfill},
+	{"Object.entries", es2017, objectEntriesPolyfill},
+	{"Object.values", es2017, objectValuesPolyfill},
+	{"Array.from", es2015, arrayFromPolyfill},
+	{"Array.of", es2015, arrayOfPolyfill},
+	{"Number.isNaN", es2015, numberIsNaNPolyfill},
+	{"Number.isInteger", es2015, numberIsIntegerPolyfill},
+	{"Array.prototype.find", es2015, arrayFindPolyfill},
+	{"Array.prototype.findIndex", es2015, arrayFindIndexPolyfill},
+	{"Array.prototype.includes", es2016, arrayIncludesPolyfill},
+	{"String.prototype.includes", es2015, stringIncludesPolyfill},
+	{"String.prototype.startsWith", es2015, stringStartsWithPolyfill},
+	{"String.prototype.endsWith", es2015, stringEndsWithPolyfill},
+	{"String.prototype.padStart", es2017, stringPadStartPolyfill},
+	{"String.prototype.padEnd", es2017, stringPadEndPolyfill},
+}
+
+// polyfillUses are the built-ins the polyfills use, which are polyfilled
+// with them. Iterables are read through Symbol.iterator.
+var polyfillUses = map[string][]string{
+	"Map":        {"Symbol"},
+	"Set":        {"Symbol"},
+	"Array.from": {"Symbol"},
+}
+
+// usesBuiltin records that the generated code uses the built-in name, so
+// its polyfill is added to the bundle if the target lacks it.
+func (g *generator) usesBuiltin(name string) {
+	if g.bundle == nil || g.bundle.polyfills == nil {
+		return
+	}
+	for _, p := range polyfills {
+		if p.name == name && !g.supports(p.version) {
+			g.bundle.polyfills[name] = true
+			for _, used := range polyfillUses[name] {
+				g.usesBuiltin(used)
+			}
+		}
+	}
+}
+
+// usesGlobal records the use of the built-in that exp refers to, if it is
+// a global like Promise or a static method like Array.from.
+func (g *generator) usesGlobal(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		if g.globals[&exp.Name] {
+			g.usesBuiltin(exp.Name)
+		}
+	case *ast.DotExpression:
+		if left, ok := exp.Left.(*ast.Identifier); ok && g.globals[&left.Name] {
+			g.usesBuiltin(left.Name + "." + exp.Identifier.Name)
+		}
+		g.usesMethod(exp.Identifier.Name)
+	}
+}
+
+// usesMethod records the use of the method name on an object whose type is
+// unknown, so the method is polyfilled on all the prototypes that have it.
+func (g *generator) usesMethod(name string) {
+	for _, p := range polyfills {
+		if strings.HasSuffix(p.name, ".prototype."+name) {
+			g.usesBuiltin(p.name)
+		}
+	}
+}
+
+// polyfillSource returns the polyfills of the built-ins used by the
+// modules of the bundle.
+func (b *_bundle) polyfillSource() string {
+	var src string
+	global := false
+	for _, p := range polyfills {
+		if b.polyfills[p.name] {
+			src += b.runtime(p.source)
+			global = global || !strings.Contains(p.name, ".")
+		}
+	}
+	if global {
+		src = b.runtime(globalObjectJS) + src
+	}
+	return src
+}
+
+// globalObjectJS finds the global object, which the polyfills of globals
+// are added to, as the global of the bundle is a plain object.
+const globalObjectJS = `
+var __go_bundle_global__ = typeof globalThis !== 'undefined' ? globalThis :
+  typeof self !== 'undefined' ? self :
+  typeof window !== 'undefined' ? window : Function('return this')();
+`
+
+// symbolPolyfill creates symbols as unique strings, so they can be used as
+// property keys.
+const symbolPolyfill = `
+if (typeof Symbol !== 'function') {
+  __go_bundle_global__.Symbol = (function () {
+    var counter = 0;
+    var Symbol = function (description) {
+      counter++;
+      return '@@' + (description === undefined ? '' : description) + '@' + counter;
+    };
+    Symbol.iterator = '@@iterator';
+    Symbol.asyncIterator = '@@asyncIterator';
+    return Symbol;
+  })();
+}
+`
+
+const promisePolyfill = `
+if (typeof Promise !== 'function') {
+  __go_bundle_global__.Promise = (function () {
+    var Promise = function (executor) {
+      var promise = this;
+      var done = false;
+      promise.state = 'pending';
+      promise.callbacks = [];
+      var resolve = function (value) {
+        if (!done) {
+          done = true;
+          promise.adopt(value);
+        }
+      };
+      var reject = function (reason) {
+        if (!done) {
+          done = true;
+          promise.settle('rejected', reason);
+        }
+      };
+      try {
+        executor(resolve, reject);
+      } catch (e) {
+        reject(e);
+      }
+    };
+
+    Promise.prototype.adopt = function (value) {
+      var promise = this;
+      if (value === promise) {
+        promise.settle('rejected', new TypeError('A promise cannot be resolved with itself'));
+        return;
+      }
+      if (value !== null && (typeof value === 'object' || typeof value === 'function')) {
+        var called = false;
+        try {
+          var then = value.then;
+          if (typeof then === 'function') {
+            then.call(value, function (result) {
+              if (!called) {
+                called = true;
+                promise.adopt(result);
+              }
+            }, function (reason) {
+              if (!called) {
+                called = true;
+                promise.settle('rejected', reason);
+              }
+            });
+            return;
+          }
+        } catch (e) {
+          if (!called) {
+            called = true;
+            promise.settle('rejected', e);
+          }
+          return;
+        }
+      }
+      promise.settle('fulfilled', value);
+    };
+
+    Promise.prototype.settle = function (state, value) {
+      var callbacks = this.callbacks;
+      this.state = state;
+      this.value = value;
+      this.callbacks = [];
+      for (var i = 0; i < callbacks.length; i++) {
+        this.handle(callbacks[i]);
+      }
+    };
+
+    Promise.prototype.handle = function (callback) {
+      var promise = this;
+      if (promise.state === 'pending') {
+        promise.callbacks.push(callback);
+        return;
+      }
+      setTimeout(function () {
+        var fulfilled = promise.state === 'fulfilled';
+        var handler = fulfilled ? callback.onFulfilled : callback.onRejected;
+        if (typeof handler !== 'function') {
+          if (fulfilled) {
+            callback.resolve(promise.value);
+          } else {
+            callback.reject(promise.value);
+          }
+          return;
+        }
+        try {
+          callback.resolve(handler(promise.value));
+        } catch (e) {
+          callback.reject(e);
+        }
+      }, 0);
+    };
+
+    Promise.prototype.then = function (onFulfilled, onRejected) {
+      var promise = this;
+      return new Promise(function (resolve, reject) {
+        promise.handle({ onFulfilled: onFulfilled, onRejected: onRejected, resolve: resolve, reject: reject });
+      });
+    };
+
+    Promise.prototype['catch'] = function (onRejected) {
+      return this.then(null, onRejected);
+    };
+
+    Promise.resolve = function (value) {
+      if (value instanceof Promise) {
+        return value;
+      }
+      return new Promise(function (resolve) {
+        resolve(value);
+      });
+    };
+
+    Promise.reject = function (reason) {
+      return new Promise(function (resolve, reject) {
+        reject(reason);
+      });
+    };
+
+    Promise.all = function (values) {
+      return new Promise(function (resolve, reject) {
+        var results = [];
+        var remaining = values.length;
+        if (remaining === 0) {
+          resolve(results);
+        }
+        var settle = function (i) {
+          Promise.resolve(values[i]).then(function (value) {
+            results[i] = value;
+            remaining--;
+            if (remaining === 0) {
+              resolve(results);
+            }
+          }, reject);
+        };
+        for (var i = 0; i < values.length; i++) {
+          settle(i);
+        }
+      });
+    };
+
+    Promise.race = function (values) {
+      return new Promise(function (resolve, reject) {
+        for (var i = 0; i < values.length; i++) {
+          Promise.resolve(values[i]).then(resolve, reject);
+        }
+      });
+    };
+
+    return Promise;
+  })();
+}
+`
+
+// mapPolyfill keeps the keys and values in arrays, so lookups are linear.
+// Keys are compared like ===, except that NaN is equal to itself.
+const mapPolyfill = `
+if (typeof Map !== 'function') {
+  __go_bundle_global__.Map = (function () {
+    var indexOf = function (list, key) {
+      for (var i = 0; i < list.length; i++) {
+        if (list[i] === key || list[i] !== list[i] && key !== key) {
+          return i;
+        }
+      }
+      return -1;
+    };
+    var iterator = function (map, read) {
+      var i = 0;
+      var it = {
+        next: function () {
+          if (i < map.keyList.length) {
+            i++;
+            return { value: read(i - 1), done: false };
+          }
+          return { value: undefined, done: true };
+        }
+      };
+      if (typeof Symbol === 'function') {
+        it[Symbol.iterator] = function () {
+          return it;
+        };
+      }
+      return it;
+    };
+
+    var Map = function (entries) {
+      var map = this;
+      map.keyList = [];
+      map.valueList = [];
+      if (entries != null) {
+        var add = function (entry) {
+          map.set(entry[0], entry[1]);
+        };
+        if (typeof Symbol === 'function' && typeof entries[Symbol.iterator] === 'function') {
+          var it = entries[Symbol.iterator]();
+          for (var step = it.next(); !step.done; step = it.next()) {
+            add(step.value);
+          }
+        } else {
+          for (var i = 0; i < entries.length; i++) {
+            add(entries[i]);
+          }
+        }
+      }
+    };
+
+    Object.defineProperty(Map.prototype, 'size', {
+      get: function () {
+        return this.keyList.length;
+      },
+      configurable: true
+    });
+
+    Map.prototype.get = function (key) {
+      var i = indexOf(this.keyList, key);
+      return i < 0 ? undefined : this.valueList[i];
+    };
+
+    Map.prototype.set = function (key, value) {
+      var i = indexOf(this.keyList, key);
+      if (i < 0) {
+        this.keyList.push(key);
+        this.valueList.push(value);
+      } else {
+        this.valueList[i] = value;
+      }
+      return this;
+    };
+
+    Map.prototype.has = function (key) {
+      return indexOf(this.keyList, key) >= 0;
+    };
+
+    Map.prototype['delete'] = function (key) {
+      var i = indexOf(this.keyList, key);
+      if (i < 0) {
+        return false;
+      }
+      this.keyList.splice(i, 1);
+      this.valueList.splice(i, 1);
+      return true;
+    };
+
+    Map.prototype.clear = function () {
+      this.keyList = [];
+      this.valueList = [];
+    };
+
+    Map.prototype.forEach = function (callback, thisArg) {
+      for (var i = 0; i < this.keyList.length; i++) {
+        callback.call(thisArg, this.valueList[i], this.keyList[i], this);
+      }
+    };
+
+    Map.prototype.keys = function () {
+      var map = this;
+      return iterator(map, function (i) {
+        return map.keyList[i];
+      });
+    };
+
+    Map.prototype.values = function () {
+      var map = this;
+      return iterator(map, function (i) {
+        return map.valueList[i];
+      });
+    };
+
+    Map.prototype.entries = function () {
+      var map = this;
+      return iterator(map, function (i) {
+        return [map.keyList[i], map.valueList[i]];
+      });
+    };
+
+    if (typeof Symbol === 'function') {
+      Map.prototype[Symbol.iterator] = Map.prototype.entries;
+    }
+
+    return Map;
+  })();
+}
+`
+
+// setPolyfill keeps the values in an array, like mapPolyfill.
+const setPolyfill = `
+if (typeof Set !== 'function') {
+  __go_bundle_global__.Set = (function () {
+    var indexOf = function (list, value) {
+      for (var i = 0; i < list.length; i++) {
+        if (list[i] === value || list[i] !== list[i] && value !== value) {
+          return i;
+        }
+      }
+      return -1;
+    };
+    var iterator = function (set, read) {
+      var i = 0;
+      var it = {
+        next: function () {
+          if (i < set.valueList.length) {
+            i++;
+            return { value: read(set.valueList[i - 1]), done: false };
+          }
+          return { value: undefined, done: true };
+        }
+      };
+      if (typeof Symbol === 'function') {
+        it[Symbol.iterator] = function () {
+          return it;
+        };
+      }
+      return it;
+    };
+
+    var Set = function (values) {
+      var set = this;
+      set.valueList = [];
+      if (values != null) {
+        if (typeof Symbol === 'function' && typeof values[Symbol.iterator] === 'function') {
+          var it = values[Symbol.iterator]();
+          for (var step = it.next(); !step.done; step = it.next()) {
+            set.add(step.value);
+          }
+        } else {
+          for (var i = 0; i < values.length; i++) {
+            set.add(values[i]);
+          }
+        }
+      }
+    };
+
+    Object.defineProperty(Set.prototype, 'size', {
+      get: function () {
+        return this.valueList.length;
+      },
+      configurable: true
+    });
+
+    Set.prototype.add = function (value) {
+      if (indexOf(this.valueList, value) < 0) {
+        this.valueList.push(value);
+      }
+      return this;
+    };
+
+    Set.prototype.has = function (value) {
+      return indexOf(this.valueList, value) >= 0;
+    };
+
+    Set.prototype['delete'] = function (value) {
+      var i = indexOf(this.valueList, value);
+      if (i < 0) {
+        return false;
+      }
+      this.valueList.splice(i, 1);
+      return true;
+    };
+
+    Set.prototype.clear = function () {
+      this.valueList = [];
+    };
+
+    Set.prototype.forEach = function (callback, thisArg) {
+      for (var i = 0; i < this.valueList.length; i++) {
+        callback.call(thisArg, this.valueList[i], this.valueList[i], this);
+      }
+    };
+
+    Set.prototype.values = function () {
+      return iterator(this, function (value) {
+        return value;
+      });
+    };
+
+    Set.prototype.keys = Set.prototype.values;
+
+    Set.prototype.entries = function () {
+      return iterator(this, function (value) {
+        return [value, value];
+      });
+    };
+
+    if (typeof Symbol === 'function') {
+      Set.prototype[Symbol.iterator] = Set.prototype.values;
+    }
+
+    return Set;
+  })();
+}
+`
+
+// weakMapPolyfill stores the values on the keys, in a property that is not
+// enumerable and is named after the map, so keys that are not extensible
+// can't be used.
+const weakMapPolyfill = `
+if (typeof WeakMap !== 'function') {
+  __go_bundle_global__.WeakMap = (function () {
+    var counter = 0;
+    var check = function (key) {
+      if (key === null || typeof key !== 'object' && typeof key !== 'function') {
+        throw new TypeError('Invalid value used as weak map key');
+      }
+    };
+
+    var WeakMap = function (entries) {
+      counter++;
+      this.property = '@@WeakMap@' + counter;
+      if (entries != null) {
+        for (var i = 0; i < entries.length; i++) {
+          this.set(entries[i][0], entries[i][1]);
+        }
+      }
+    };
+
+    WeakMap.prototype.get = function (key) {
+      return this.has(key) ? key[this.property] : undefined;
+    };
+
+    WeakMap.prototype.set = function (key, value) {
+      check(key);
+      Object.defineProperty(key, this.property, { value: value, writable: true, configurable: true });
+      return this;
+    };
+
+    WeakMap.prototype.has = function (key) {
+      return key === Object(key) && Object.prototype.hasOwnProperty.call(key, this.property);
+    };
+
+    WeakMap.prototype['delete'] = function (key) {
+      return this.has(key) && delete key[this.property];
+    };
+
+    return WeakMap;
+  })();
+}
+`
+
+const objectAssignPolyfill = `
+if (typeof Object.assign !== 'function') {
+  Object.assign = function (target) {
+    var to = Object(target);
+    for (var i = 1; i < arguments.length; i++) {
+      var source = arguments[i];
+      if (source != null) {
+        for (var key in source) {
+          if (Object.prototype.hasOwnProperty.call(source, key)) {
+            to[key] = source[key];
+          }
+        }
+      }
+    }
+    return to;
+  };
+}
+`
+
+const objectEntriesPolyfill = `
+if (typeof Object.entries !== 'function') {
+  Object.entries = function (object) {
+    return Object.keys(object).map(function (key) {
+      return [key, object[key]];
+    });
+  };
+}
+`
+
+const objectValuesPolyfill = `
+if (typeof Object.values !== 'function') {
+  Object.values = function (object) {
+    return Object.keys(object).map(function (key) {
+      return object[key];
+    });
+  };
+}
+`
+
+// arrayFromPolyfill reads iterables if the engine has symbols, and array
+// like objects otherwise.
+const arrayFromPolyfill = `
+if (typeof Array.from !== 'function') {
+  Array.from = function (items, mapFn, thisArg) {
+    var result = [];
+    if (items != null && typeof Symbol === 'function' && typeof items[Symbol.iterator] === 'function') {
+      var iterator = items[Symbol.iterator]();
+      for (var step = iterator.next(); !step.done; step = iterator.next()) {
+        result.push(step.value);
+      }
+    } else {
+      var list = Object(items);
+      for (var i = 0; i < list.length; i++) {
+        result.push(list[i]);
+      }
+    }
+    return mapFn ? result.map(mapFn, thisArg) : result;
+  };
+}
+`
+
+const arrayOfPolyfill = `
+if (typeof Array.of !== 'function') {
+  Array.of = function () {
+    return Array.prototype.slice.call(arguments);
+  };
+}
+`
+
+const numberIsNaNPolyfill = `
+if (typeof Number.isNaN !== 'function') {
+  Number.isNaN = function (value) {
+    return typeof value === 'number' && value !== value;
+  };
+}
+`
+
+const numberIsIntegerPolyfill = `
+if (typeof Number.isInteger !== 'function') {
+  Number.isInteger = function (value) {
+    return typeof value === 'number' && isFinite(value) && Math.floor(value) === value;
+  };
+}
+`
+
+// Methods are defined so that they are not enumerable, as they would be
+// listed by for-in loops otherwise.
+
+const arrayFindPolyfill = `
+if (typeof Array.prototype.find !== 'function') {
+  Object.defineProperty(Array.prototype, 'find', {
+    value: function (predicate, thisArg) {
+      var list = Object(this);
+      for (var i = 0; i < list.length; i++) {
+        if (predicate.call(thisArg, list[i], i, list)) {
+          return list[i];
+        }
+      }
+      return undefined;
+    },
+    writable: true,
+    configurable: true
+  });
+}
+`
+
+const arrayFindIndexPolyfill = `
+if (typeof Array.prototype.findIndex !== 'function') {
+  Object.defineProperty(Array.prototype, 'findIndex', {
+    value: function (predicate, thisArg) {
+      var list = Object(this);
+      for (var i = 0; i < list.length; i++) {
+        if (predicate.call(thisArg, list[i], i, list)) {
+          return i;
+        }
+      }
+      return -1;
+    },
+    writable: true,
+    configurable: true
+  });
+}
+`
+
+// arrayIncludesPolyfill finds NaN, unlike indexOf.
+const arrayIncludesPolyfill = `
+if (typeof Array.prototype.includes !== 'function') {
+  Object.defineProperty(Array.prototype, 'includes', {
+    value: function (search, fromIndex) {
+      var list = Object(this);
+      var i = fromIndex | 0;
+      if (i < 0) {
+        i = Math.max(list.length + i, 0);
+      }
+      for (; i < list.length; i++) {
+        if (list[i] === search || list[i] !== list[i] && search !== search) {
+          return true;
+        }
+      }
+      return false;
+    },
+    writable: true,
+    configurable: true
+  });
+}
+`
+
+const stringIncludesPolyfill = `
+if (typeof String.prototype.includes !== 'function') {
+  Object.defineProperty(String.prototype, 'includes', {
+    value: function (search, position) {
+      return String(this).indexOf(search, position) !== -1;
+    },
+    writable: true,
+    configurable: true
+  });
+}
+`
+
+const stringStartsWithPolyfill = `
+if (typeof String.prototype.startsWith !== 'function') {
+  Object.defineProperty(String.prototype, 'startsWith', {
+    value: function (search, position) {
+      var start = Math.max(position | 0, 0);
+      search = String(search);
+      return String(this).slice(start, start + search.length) === search;
+    },
+    writable: true,
+    configurable: true
+  });
+}
+`
+
+const stringEndsWithPolyfill = `
+if (typeof String.prototype.endsWith !== 'function') {
+  Object.defineProperty(String.prototype, 'endsWith', {
+    value: function (search, endPosition) {
+      var string = String(this);
+      var end = endPosition === undefined ? string.length : Math.min(Math.max(endPosition | 0, 0), string.length);
+      search = String(search);
+      return end >= search.length && string.slice(end - search.length, end) === search;
+    },
+    writable: true,
+    configurable: true
+  });
+}
+`
+
+const stringPadStartPolyfill = `
+if (typeof String.prototype.padStart !== 'function') {
+  Object.defineProperty(String.prototype, 'padStart', {
+    value: function (targetLength, padString) {
+      var string = String(this);
+      var fill = padString === undefined ? ' ' : String(padString);
+      var length = (targetLength | 0) - string.length;
+      if (length <= 0 || fill === '') {
+        return string;
+      }
+      while (fill.length < length) {
+        fill += fill;
+      }
+      return fill.slice(0, length) + string;
+    },
+    writable: true,
+    configurable: true
+  });
+}
+`
+
+const stringPadEndPolyfill = `
+if (typeof String.prototype.padEnd !== 'function') {
+  Object.defineProperty(String.prototype, 'padEnd', {
+    value: function (targetLength, padString) {
+      var string = String(this);
+      var fill = padString === undefined ? ' ' : String(padString);
+      var length = (targetLength | 0) - string.length;
+      if (length <= 0 || fill === '') {
+        return string;
+      }
+      while (fill.length < length) {
+        fill += fill;
+      }
+      return string + fill.slice(0, length);
+    },
+    writable: true,
+    configurable: true
+  });
+}
+`
@@ -0,0 +1,66 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBundlePolyfill(t *testing.T) {
+	fsys := fstest.MapFS{
+		"src/index.js": {Data: []byte("import * as util from './util.js';\n" +
+			"var Symbol = 'local';\n" +
+			"Promise.resolve(Array.from(util.list)).then(function (list) { return Object.values(list); });\n")},
+		"src/util.js":  {Data: []byte("import * as other from './other.js';\nexport var list = [Symbol];\nexport var found = list.includes(1);\n")},
+		"src/other.js": {Data: []byte("export var other = Number.isNaN(NaN) || new Set(['a']).has('a'.padStart(2));\n")},
+	}
+	polyfilled := func(config Config) []string {
+		config.FS = fsys
+		result, err := Build("src/index.js", config)
+		assert.NoError(t, err)
+		var names []string
+		for _, p := range polyfills {
+			if n := strings.Count(string(result.Code), strings.TrimSpace(p.source)); n > 0 {
+				assert.Equal(t, 1, n, p.name)
+				names = append(names, p.name)
+			}
+		}
+		return names
+	}
+
+	// Object.assign is used by the generated code of import * as, the local
+	// Symbol is not the built-in, and methods are polyfilled on all the
+	// prototypes that have them
+	assert.Equal(t, []string{"Symbol", "Promise", "Set", "Object.assign", "Object.values", "Array.from", "Number.isNaN",
+		"Array.prototype.includes", "String.prototype.includes", "String.prototype.padStart"}, polyfilled(Config{Polyfill: true}))
+	assert.Equal(t, []string{"Object.values", "Array.prototype.includes", "String.prototype.padStart"}, polyfilled(Config{Polyfill: true, Target: TargetES2015}))
+	assert.Nil(t, polyfilled(Config{Polyfill: true, Target: TargetES2017}))
+	assert.Nil(t, polyfilled(Config{Polyfill: true, Platform: PlatformNode}))
+	assert.Nil(t, polyfilled(Config{}))
+
+	// lowered for-of loops read iterables with Array.from, which reads them
+	// through Symbol.iterator
+	loop := fstest.MapFS{"src/index.js": {Data: []byte("for (var value of new Set([1])) {}\n")}}
+	result, err := Build("src/index.js", Config{FS: loop, Polyfill: true})
+	assert.NoError(t, err)
+	for _, name := range []string{"Symbol", "Set", "Array.from"} {
+		assert.Contains(t, string(result.Code), "if (typeof "+name+" !== 'function')")
+	}
+
+	// globals are added to the global object, not declared, so they are
+	// found from any scope
+	result, err = Build("src/index.js", Config{FS: fsys, Polyfill: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(result.Code), "var __go_bundle_global__ = "))
+	assert.Contains(t, string(result.Code), "if (typeof Promise !== 'function') {\n  __go_bundle_global__.Promise = (function () {")
+	result, err = Build("src/index.js", Config{FS: fsys, Polyfill: true, Target: TargetES2015})
+	assert.NoError(t, err)
+	assert.NotContains(t, string(result.Code), "__go_bundle_global__")
+
+	// polyfills are minified with the bundle
+	result, err = Build("src/index.js", Config{FS: fsys, Polyfill: true, Minify: true})
+	assert.NoError(t, err)
+	assert.Contains(t, string(result.Code), "if(typeof Object.assign!=='function'){Object.assign=function(target){")
+}
@@ -0,0 +1,320 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/walesey/go-bundle/ast"
+)
+
+// PrintOptions control the layout of the generated code. The zero value
+// indents with two spaces, keeps the quotes of the source and ends every
+// statement with a semicolon. Except for Quotes and ASCIIOnly, they are
+// ignored when minifying.
+type PrintOptions struct {
+	// Indent is the number of spaces of each indentation level, 2 if zero.
+	// With Tabs, it is the width of a tab when lines are wrapped.
+	Indent int
+
+	// Tabs indents with one tab per level instead of spaces.
+	Tabs bool
+
+	// Quotes selects the quotes of string literals and quoted keys.
+	Quotes QuoteStyle
+
+	// TrailingCommas adds a comma after the last property of objects and
+	// the last element of wrapped arrays. Wrapped argument lists only get
+	// one for targets from ES2017, as it is not valid before.
+	TrailingCommas bool
+
+	// Semicolons selects where statements end with a semicolon.
+	Semicolons SemicolonStyle
+
+	// LineWidth wraps argument and array lists that would make a line
+	// longer than this many columns, writing one element per line. Lists
+	// are never wrapped if zero.
+	LineWidth int
+
+	// ASCIIOnly escapes the characters outside of ASCII, so the code can be
+	// served without a charset. Characters outside of the basic plane are
+	// escaped as surrogate pairs, which is only valid in strings and regular
+	// expressions, not in names.
+	ASCIIOnly bool
+
+	// KeepLayout writes function declarations where they are in the source
+	// instead of first in their function, and keeps the blank lines between
+	// statements.
+	KeepLayout bool
+}
+
+// QuoteStyle selects the quotes of string literals.
+type QuoteStyle string
+
+const (
+	// QuotesPreserve keeps the quotes of the source. Keys that must be
+	// quoted get double quotes.
+	QuotesPreserve QuoteStyle = ""
+
+	// QuotesDouble writes "string".
+	QuotesDouble QuoteStyle = "double"
+
+	// QuotesSingle writes 'string'.
+	QuotesSingle QuoteStyle = "single"
+)
+
+// SemicolonStyle selects where statements end with a semicolon.
+type SemicolonStyle string
+
+const (
+	// SemicolonsAlways ends every statement with a semicolon.
+	SemicolonsAlways SemicolonStyle = ""
+
+	// SemicolonsAsNeeded leaves out the semicolons at the end of a line,
+	// unless the next line starts with a character that would continue
+	// the statement, like "(" or "[".
+	SemicolonsAsNeeded SemicolonStyle = "as-needed"
+)
+
+// indentation returns the code of one indentation level.
+func (p PrintOptions) indentation() string {
+	if p.Tabs {
+		return "\t"
+	}
+	if p.Indent > 0 {
+		return strings.Repeat(" ", p.Indent)
+	}
+	return "  "
+}
+
+// quote returns the quote character of string literals, or 0 if the quotes
+// of the source are kept.
+func (p PrintOptions) quote() byte {
+	switch p.Quotes {
+	case QuotesDouble:
+		return '"'
+	case QuotesSingle:
+		return '\''
+	}
+	return 0
+}
+
+// stringCode returns the literal s is written as.
+func (g *generator) stringCode(s *ast.StringLiteral) string {
+	if g.options.Minify && g.options.Print.Quotes == QuotesPreserve {
+		return shortString(s.Literal)
+	}
+	return g.quoted(s.Literal)
+}
+
+// key returns the code of a property key, quoting keys that are not names.
+func (g *generator) key(k string) string {
+	return g.quoted(escapeKeyIfRequired(k))
+}
+
+// quoted returns the string literal with the quotes of the options.
+func (g *generator) quoted(literal string) string {
+	if quote := g.options.Print.quote(); quote != 0 {
+		return requote(literal, quote)
+	}
+	return literal
+}
+
+// requote returns literal with the quote character quote.
+func requote(literal string, quote byte) string {
+	if len(literal) < 2 || literal[0] == quote {
+		return literal
+	}
+	return swapQuotes(literal)
+}
+
+// swapQuotes returns the string literal with the other quote character,
+// changing which quotes in it are escaped.
+func swapQuotes(literal string) string {
+	quote := literal[0]
+	if quote != '"' && quote != '\'' {
+		return literal
+	}
+	other := byte('"')
+	if quote == '"' {
+		other = '\''
+	}
+
+	body := literal[1 : len(literal)-1]
+	var swapped bytes.Buffer
+	swapped.WriteByte(other)
+	for i := 0; i < len(body); i++ {
+		switch c := body[i]; {
+		case c == '\\' && i+1 < len(body):
+			if body[i+1] == quote {
+				swapped.WriteByte(quote)
+			} else {
+				swapped.WriteByte(c)
+				swapped.WriteByte(body[i+1])
+			}
+			i++
+		case c == other:
+			swapped.WriteByte('\\')
+			swapped.WriteByte(c)
+		default:
+			swapped.WriteByte(c)
+		}
+	}
+	swapped.WriteByte(other)
+	return swapped.String()
+}
+
+// escapeNonASCII replaces the characters of s outside of ASCII with \u
+// escapes.
+func escapeNonASCII(s string) string {
+	i := 0
+	for i < len(s) && s[i] < utf8.RuneSelf {
+		i++
+	}
+	if i == len(s) {
+		return s
+	}
+	var escaped bytes.Buffer
+	escaped.WriteString(s[:i])
+	for _, r := range s[i:] {
+		switch {
+		case r < utf8.RuneSelf:
+			escaped.WriteRune(r)
+		case r > 0xffff:
+			r1, r2 := utf16.EncodeRune(r)
+			fmt.Fprintf(&escaped, "\\u%04x\\u%04x", r1, r2)
+		default:
+			fmt.Fprintf(&escaped, "\\u%04x", r)
+		}
+	}
+	return escaped.String()
+}
+
+// continuesStatement reports whether a line starting with c would continue
+// the statement of the previous line if its semicolon was left out.
+func continuesStatement(c byte) bool {
+	return strings.IndexByte("([`+-/*,.=<>?:&|", c) != -1
+}
+
+// pendingSemicolon decides, when s is about to be written, whether the
+// semicolon left out at the end of the last statement is needed. s is
+// returned with the semicolon if it can be written before s.
+func (g *generator) pendingSemicolon(s string) string {
+	if g.options.Minify {
+		g.semicolonPending = false
+		if s[0] != '}' {
+			return ";" + s
+		}
+		return s
+	}
+
+	code := strings.TrimLeft(s, " \t\n")
+	if code == "" || strings.HasPrefix(code, "//") || strings.HasPrefix(code, "/*") {
+		// wait for the code of the next statement
+		return s
+	}
+	g.semicolonPending = false
+
+	newline := bytes.IndexByte(g.buffer.Bytes()[g.semicolonAt:], '\n') != -1 ||
+		strings.IndexByte(s[:len(s)-len(code)], '\n') != -1
+	if code[0] == '}' || newline && !continuesStatement(code[0]) {
+		return s
+	}
+	if g.semicolonAt == g.buffer.Len() {
+		return ";" + s
+	}
+
+	// only whitespace and comments were written since the statement ended
+	b := g.buffer.Bytes()
+	tail := string(b[g.semicolonAt:])
+	g.buffer.Truncate(g.semicolonAt)
+	g.buffer.WriteString(";" + tail)
+	if strings.IndexByte(tail, '\n') == -1 {
+		g.currentChar++
+		g.column++
+	}
+	return s
+}
+
+// wrapsList reports whether the list of exps between open and close makes
+// the line longer than the line width, so it must be wrapped.
+func (g *generator) wrapsList(open, close string, exps []ast.Expression) bool {
+	width := g.options.Print.LineWidth
+	if g.options.Minify || width <= 0 || len(exps) == 0 {
+		return false
+	}
+
+	// write the list on one line with a generator that has no side effects
+	m := &generator{
+		buffer:      &bytes.Buffer{},
+		indentLevel: g.indentLevel,
+		indentation: g.indentation,
+		options:     g.options,
+		target:      g.target,
+	}
+	m.options.Comments = CommentsNone
+	m.options.Print.LineWidth = 0
+	if err := m.list(open, close, exps, nil, false); err != nil {
+		return false
+	}
+	line := m.buffer.String()
+	if i := strings.IndexByte(line, '\n'); i != -1 {
+		line = line[:i]
+	}
+	return g.lineLength()+g.textWidth(line) > width
+}
+
+// lineLength returns the width of the code written on the current line.
+func (g *generator) lineLength() int {
+	b := g.buffer.Bytes()
+	return g.textWidth(string(b[bytes.LastIndexByte(b, '\n')+1:]))
+}
+
+// textWidth returns the number of columns s takes, counting a tab as an
+// indentation level.
+func (g *generator) textWidth(s string) int {
+	tab := g.options.Print.Indent
+	if tab <= 0 {
+		tab = 2
+	}
+	return utf8.RuneCountInString(s) + strings.Count(s, "\t")*(tab-1)
+}
+
+// list writes exps, the elements of an argument or array list, between
+// open and close, followed by the final comments of node. Lists that do not
+// fit on the line are wrapped.
+func (g *generator) list(open, close string, exps []ast.Expression, node ast.Node, trailingComma bool) error {
+	wrap := g.wrapsList(open, close, exps)
+	g.write(open)
+	if wrap {
+		g.indentLevel++
+	}
+	for i, e := range exps {
+		if wrap {
+			g.writeLine("")
+		}
+		if err := g.subExpression(e, precAssign); err != nil {
+			return err
+		}
+		if i < len(exps)-1 {
+			if wrap {
+				g.write(",")
+			} else {
+				g.write(", ")
+			}
+		} else if wrap && trailingComma {
+			g.write(",")
+		}
+	}
+	if wrap {
+		g.indentLevel--
+		g.writeLine("")
+	}
+	for _, c := range g.takeComments(node, isFinalComment) {
+		g.writeUnmapped(" " + inlineComment(c))
+	}
+	g.write(close)
+	return nil
+}
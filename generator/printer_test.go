@@ -0,0 +1,77 @@
+package generator
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadPrint(t *testing.T) {
+	load := func(src string, options Options) string {
+		out, err := LoadWithOptions(strings.NewReader(src), options)
+		assert.NoError(t, err)
+		code, err := ioutil.ReadAll(out)
+		assert.NoError(t, err)
+		return string(code)
+	}
+	print := func(src string, p PrintOptions) string {
+		return load(src, Options{Print: p})
+	}
+
+	// indentation
+	src := "function f() { if (a) { b(); } }"
+	assert.Equal(t, "function f() {\n    if (a) {\n        b();\n    }\n}", print(src, PrintOptions{Indent: 4}))
+	assert.Equal(t, "function f() {\n\tif (a) {\n\t\tb();\n\t}\n}", print(src, PrintOptions{Tabs: true}))
+
+	// quotes
+	src = `x = ['a', "b", 'it\'s', "say \"hi\""]; o = { 'a-b': 1 };`
+	assert.Equal(t,
+		"x = [\"a\", \"b\", \"it's\", \"say \\\"hi\\\"\"];\no = {\n  \"a-b\": 1\n};",
+		print(src, PrintOptions{Quotes: QuotesDouble}))
+	assert.Equal(t,
+		"x = ['a', 'b', 'it\\'s', 'say \"hi\"'];\no = {\n  'a-b': 1\n};",
+		print(src, PrintOptions{Quotes: QuotesSingle}))
+	assert.Equal(t,
+		"x=['a','b','it\\'s','say \"hi\"'];o={'a-b':1}",
+		load(src, Options{Minify: true, Print: PrintOptions{Quotes: QuotesSingle}}))
+
+	// trailing commas
+	assert.Equal(t, "o = {\n  a: 1,\n  b: [1, 2],\n};", print("o = { a: 1, b: [1, 2] };", PrintOptions{TrailingCommas: true}))
+
+	// semicolons are only kept where the next line would continue the
+	// statement
+	assert.Equal(t,
+		"a = 1\nb();\n(c || d).e()\nif (a) {\n  f()\n}\nvar h = function () {\n  return g\n};\n[1].map(i)",
+		print("a = 1; b(); (c || d).e(); if (a) { f(); } var h = function () { return g; }; [1].map(i);",
+			PrintOptions{Semicolons: SemicolonsAsNeeded}))
+
+	// line width
+	src = "f(first, second, [third, fourth], function () { return 1; });"
+	assert.Equal(t, "f(first, second, [third, fourth], function () {\n  return 1;\n});", print(src, PrintOptions{LineWidth: 80}))
+	assert.Equal(t,
+		"f(\n  first,\n  second,\n  [third, fourth],\n  function () {\n    return 1;\n  }\n);",
+		print(src, PrintOptions{LineWidth: 40}))
+	assert.Equal(t,
+		"x = [\n  first,\n  second,\n];",
+		print("x = [first, second];", PrintOptions{LineWidth: 10, TrailingCommas: true}))
+
+	// ASCII only
+	assert.Equal(t,
+		"\nvar caf\\u00e9 = \"\\u00fcber \\ud83d\\ude00\";",
+		print(`var café = "über 😀";`, PrintOptions{ASCIIOnly: true}))
+}
+
+func TestBundlePrint(t *testing.T) {
+	result, err := Build("./testdata/dce/index.js", Config{
+		Print: PrintOptions{Tabs: true, Quotes: QuotesDouble, Semicolons: SemicolonsAsNeeded},
+	})
+	assert.NoError(t, err)
+	code := string(result.Code)
+	assert.Contains(t, code, "function repeat(s, n) {\n\tvar result = \"\"\n")
+	assert.Contains(t, code, "require(\"m2\")\n")
+
+	// the runtime is laid out like the modules
+	assert.Contains(t, code, "\tvar result = __go_bundle_module_cache__[module]\n")
+}
@@ -0,0 +1,441 @@
+package generator
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ResolveConfig controls how import specifiers are resolved to files.
+type ResolveConfig struct {
+	// Conditions are the condition names matched against the package.json
+	// "exports" field. "import" or "require" is added depending on how the
+	// module is imported, and "default" always matches.
+	Conditions []string
+
+	// MainFields are the package.json fields used to find the entry point of
+	// a package without "exports", in order of priority. The "browser" field
+	// replacement map is only applied if "browser" is listed here.
+	MainFields []string
+
+	// Alias replaces the start of import specifiers, eg. "@app" -> "./src"
+	// resolves "@app/button" as "./src/button". Relative targets are
+	// relative to the working directory.
+	Alias map[string]string
+
+	// Extensions are tried in order when an import does not name a file.
+	Extensions []string
+
+	// MainFiles are the file names, without extension, used when an import
+	// names a directory.
+	MainFiles []string
+
+	// ModuleDirectories are searched for bare module names. Relative names
+	// are looked up in the importing directory and each of its parents,
+	// absolute paths are searched directly.
+	ModuleDirectories []string
+
+	// TSConfigFiles are the names of the config files whose "baseUrl" and
+	// "paths" compiler options are applied to bare imports. The nearest one
+	// to the importing module is used. Set to an empty slice to disable.
+	TSConfigFiles []string
+
+	// PreserveSymlinks keeps the symlinked path of resolved modules instead
+	// of their real path. By default a module reached through a symlink,
+	// eg. a workspace package in node_modules, is identified by its real
+	// path so it is only bundled once and resolves its own dependencies.
+	PreserveSymlinks bool
+
+	// Builtins overrides how Node.js core modules, eg. "fs" or "node:path",
+	// are bundled for the browser. A module maps to an import resolved
+	// instead of the core module, relative to the working directory if it
+	// starts with ".", or to "" for an empty module. Core modules that are
+	// not listed use the bundled shim if there is one and an empty module
+	// otherwise, unless a package with the same name is installed.
+	Builtins map[string]string
+}
+
+// DefaultResolveConfig is the resolve configuration used by Bundle. Fields
+// left nil in a ResolveConfig take their value from here.
+var DefaultResolveConfig = ResolveConfig{
+	Conditions:        []string{"browser"},
+	MainFields:        []string{"browser", "module", "main"},
+	Extensions:        []string{".js", ".json"},
+	MainFiles:         []string{"index"},
+	ModuleDirectories: []string{"node_modules"},
+	TSConfigFiles:     []string{"tsconfig.json", "jsconfig.json"},
+}
+
+func (c ResolveConfig) withDefaults() ResolveConfig {
+	if c.Conditions == nil {
+		c.Conditions = DefaultResolveConfig.Conditions
+	}
+	if c.MainFields == nil {
+		c.MainFields = DefaultResolveConfig.MainFields
+	}
+	if c.Extensions == nil {
+		c.Extensions = DefaultResolveConfig.Extensions
+	}
+	if c.MainFiles == nil {
+		c.MainFiles = DefaultResolveConfig.MainFiles
+	}
+	if c.ModuleDirectories == nil {
+		c.ModuleDirectories = DefaultResolveConfig.ModuleDirectories
+	}
+	if c.TSConfigFiles == nil {
+		c.TSConfigFiles = DefaultResolveConfig.TSConfigFiles
+	}
+	return c
+}
+
+// importKind tells the resolver whether a module is loaded with an import
+// statement or a call to require.
+type importKind int
+
+const (
+	kindImport importKind = iota
+	kindRequire
+)
+
+// emptyModulePath is the module key used for modules replaced with false in
+// a package.json "browser" field.
+const emptyModulePath = "<empty>"
+
+// isInternalPath reports whether path is a module key that does not refer to
+// a file, such as emptyModulePath.
+func isInternalPath(path string) bool {
+	return strings.HasPrefix(path, "<")
+}
+
+// resolve finds the file that importValue refers to when imported from
+// currentPath, asking the resolve plugins first.
+func (bundle *_bundle) resolve(importValue, currentPath string, kind importKind) (string, error) {
+	if path, ok, err := bundle.pluginResolve(importValue, currentPath, kind); err != nil || ok {
+		return path, err
+	}
+
+	resolved, err := bundle.resolvePath(importValue, currentPath, kind)
+	if err != nil || isInternalPath(resolved) || bundle.config.Resolve.PreserveSymlinks {
+		return resolved, err
+	}
+	if _, ok := bundle.virtual[resolved]; ok || isVirtualPath(resolved) {
+		return resolved, nil
+	}
+	return bundle.fs.evalSymlinks(resolved)
+}
+
+func (bundle *_bundle) resolvePath(importValue, currentPath string, kind importKind) (string, error) {
+	originalValue := importValue
+	if aliased, ok := bundle.alias(importValue); ok {
+		importValue = aliased
+	}
+
+	// virtual modules registered by name
+	if _, ok := bundle.virtual[importValue]; ok && !isPathSpecifier(importValue) {
+		return importValue, nil
+	}
+
+	//use relative path
+	if isPathSpecifier(importValue) {
+		path := importValue
+		if !filepath.IsAbs(path) {
+			// not cleaned, so that the file system sees the paths that go
+			// above its root
+			path = filepath.Dir(currentPath) + string(filepath.Separator) + importValue
+		}
+		path, err := bundle.fs.abs(path)
+		if err != nil {
+			return "", err
+		}
+		resolved, ok := bundle.resolveFileOrDirectory(path)
+		if !ok {
+			return "", fmt.Errorf("Cannot find module: %v", originalValue)
+		}
+		return bundle.browserFile(resolved)
+	}
+
+	// bare module names can be remapped by the importing package
+	if bundle.useBrowserField() {
+		if pkg := bundle.nearestPackage(filepath.Dir(currentPath)); pkg != nil {
+			if replacement, ok := pkg.browserReplacement(importValue); ok {
+				return bundle.browserTarget(pkg, replacement)
+			}
+		}
+	}
+
+	// tsconfig.json / jsconfig.json path mappings
+	if resolved, ok, err := bundle.resolveTSConfigPaths(importValue, currentPath); err != nil || ok {
+		if err != nil {
+			return "", err
+		}
+		return bundle.browserFile(resolved)
+	}
+
+	// core modules are bundled as shims unless a package of the same name
+	// is installed; "node:" always refers to the core module
+	builtin := strings.TrimPrefix(importValue, "node:")
+	if isNodeBuiltin(builtin) && bundle.preferBuiltin(importValue, builtin) {
+		return bundle.resolveBuiltin(builtin, currentPath, kind)
+	}
+
+	resolved, ok, err := bundle.resolveNodeModules(importValue, currentPath, kind)
+	if err != nil || ok {
+		return resolved, err
+	}
+
+	if isNodeBuiltin(builtin) {
+		return bundle.resolveBuiltin(builtin, currentPath, kind)
+	}
+	return "", fmt.Errorf("Cannot find module: %v", originalValue)
+}
+
+// resolveNodeModules looks for the package named by importValue in the
+// module directories.
+func (bundle *_bundle) resolveNodeModules(importValue, currentPath string, kind importKind) (string, bool, error) {
+	searchPath, err := bundle.fs.abs(filepath.Dir(currentPath))
+	if err != nil {
+		return "", false, err
+	}
+
+	name, subpath := splitPackageName(importValue)
+
+	for _, moduleDir := range bundle.moduleDirectories(searchPath) {
+		resolved, ok, err := bundle.resolvePackage(filepath.Join(moduleDir, name), subpath, kind)
+		if err != nil {
+			return "", false, err
+		}
+		if ok {
+			resolved, err = bundle.browserFile(resolved)
+			return resolved, err == nil, err
+		}
+	}
+	return "", false, nil
+}
+
+// alias applies the longest matching alias to importValue.
+func (bundle *_bundle) alias(importValue string) (string, bool) {
+	match := ""
+	for key := range bundle.config.Resolve.Alias {
+		if (importValue == key || strings.HasPrefix(importValue, key+"/")) && len(key) > len(match) {
+			match = key
+		}
+	}
+	if match == "" {
+		return importValue, false
+	}
+
+	target := bundle.config.Resolve.Alias[match]
+	if strings.HasPrefix(target, ".") {
+		if abs, err := bundle.fs.abs(target); err == nil {
+			target = abs
+		}
+	}
+	return target + importValue[len(match):], true
+}
+
+// moduleDirectories lists the directories searched for bare module names
+// imported from dir, nearest first.
+func (bundle *_bundle) moduleDirectories(dir string) []string {
+	var dirs []string
+	for _, name := range bundle.config.Resolve.ModuleDirectories {
+		if filepath.IsAbs(name) {
+			dirs = append(dirs, name)
+			continue
+		}
+		for searchPath := dir; ; {
+			if filepath.Base(searchPath) != name {
+				if path := filepath.Join(searchPath, name); bundle.isDir(path) {
+					dirs = append(dirs, path)
+				}
+			}
+			parent := filepath.Dir(searchPath)
+			if parent == searchPath {
+				break
+			}
+			searchPath = parent
+		}
+	}
+	return dirs
+}
+
+// resolvePackage resolves subpath within the package in dir. ok is false if
+// there is no such package.
+func (bundle *_bundle) resolvePackage(dir, subpath string, kind importKind) (string, bool, error) {
+	pkg := bundle.packageJSON(dir)
+	if pkg != nil && pkg.exports != nil {
+		resolved, err := pkg.resolveExports(subpath, bundle.conditions(kind))
+		if err != nil {
+			return "", false, err
+		}
+		if !bundle.isFile(resolved) {
+			return "", false, fmt.Errorf("Cannot find module: %v", resolved)
+		}
+		return resolved, true, nil
+	}
+
+	path := filepath.Join(dir, filepath.FromSlash(subpath))
+	resolved, ok := bundle.resolveFileOrDirectory(path)
+	return resolved, ok, nil
+}
+
+// resolveFileOrDirectory finds the file path refers to, either directly, by
+// adding an extension, or as a directory with a package.json or index file.
+func (bundle *_bundle) resolveFileOrDirectory(path string) (string, bool) {
+	if resolved, ok := bundle.resolveFile(path); ok {
+		return resolved, true
+	}
+	return bundle.resolveDirectory(path)
+}
+
+func (bundle *_bundle) resolveFile(path string) (string, bool) {
+	if bundle.isFile(path) {
+		return path, true
+	}
+	for _, ext := range bundle.config.Resolve.Extensions {
+		if bundle.isFile(path + ext) {
+			return path + ext, true
+		}
+	}
+	return "", false
+}
+
+func (bundle *_bundle) resolveDirectory(path string) (string, bool) {
+	if !bundle.isDir(path) {
+		return "", false
+	}
+
+	if pkg := bundle.packageJSON(path); pkg != nil {
+		if main, ok := pkg.mainField(bundle.config.Resolve.MainFields); ok {
+			mainPath := filepath.Join(path, filepath.FromSlash(main))
+			if resolved, ok := bundle.resolveFile(mainPath); ok {
+				return resolved, true
+			}
+			if resolved, ok := bundle.resolveIndex(mainPath); ok {
+				return resolved, true
+			}
+		}
+	}
+
+	return bundle.resolveIndex(path)
+}
+
+func (bundle *_bundle) resolveIndex(path string) (string, bool) {
+	for _, mainFile := range bundle.config.Resolve.MainFiles {
+		if resolved, ok := bundle.resolveFile(filepath.Join(path, mainFile)); ok {
+			return resolved, true
+		}
+	}
+	return "", false
+}
+
+// browserFile applies the "browser" field of the package containing path,
+// which may replace the file with another one or with an empty module.
+func (bundle *_bundle) browserFile(path string) (string, error) {
+	if !bundle.useBrowserField() {
+		return path, nil
+	}
+	pkg := bundle.nearestPackage(filepath.Dir(path))
+	if pkg == nil || pkg.browser == nil {
+		return path, nil
+	}
+
+	// the first key naming the file wins, like in the package.json
+	for _, key := range pkg.browser.keys {
+		if !strings.HasPrefix(key, ".") {
+			continue
+		}
+		keyPath, ok := bundle.resolveFile(filepath.Join(pkg.dir, filepath.FromSlash(key)))
+		if !ok || keyPath != path {
+			continue
+		}
+		switch replacement := pkg.browser.values[key]; replacement.(type) {
+		case string, bool:
+			return bundle.browserTarget(pkg, replacement)
+		}
+	}
+	return path, nil
+}
+
+// browserTarget resolves the value of a "browser" field mapping.
+func (bundle *_bundle) browserTarget(pkg *packageJSON, replacement interface{}) (string, error) {
+	switch replacement := replacement.(type) {
+	case bool:
+		if !replacement {
+			return emptyModulePath, nil
+		}
+	case string:
+		if strings.HasPrefix(replacement, ".") {
+			path := filepath.Join(pkg.dir, filepath.FromSlash(replacement))
+			if resolved, ok := bundle.resolveFileOrDirectory(path); ok {
+				return resolved, nil
+			}
+			return "", fmt.Errorf("Cannot find module: %v", replacement)
+		}
+		return bundle.resolve(replacement, filepath.Join(pkg.dir, "package.json"), kindRequire)
+	}
+	return "", fmt.Errorf("Invalid \"browser\" field in %v", pkg.dir)
+}
+
+func (bundle *_bundle) useBrowserField() bool {
+	return containsString(bundle.config.Resolve.MainFields, "browser")
+}
+
+func (bundle *_bundle) conditions(kind importKind) []string {
+	conditions := append([]string{}, bundle.config.Resolve.Conditions...)
+	if kind == kindRequire {
+		return append(conditions, "require")
+	}
+	return append(conditions, "import")
+}
+
+// packageJSON returns the parsed package.json in dir, or nil if it does
+// not exist or cannot be parsed.
+func (bundle *_bundle) packageJSON(dir string) *packageJSON {
+	if pkg, ok := bundle.packages[dir]; ok {
+		return pkg
+	}
+	var pkg *packageJSON
+	if data, err := bundle.fs.readFile(filepath.Join(dir, "package.json")); err == nil {
+		if pkg, err = parsePackageJSON(dir, data); err != nil {
+			pkg = nil
+		}
+	}
+	bundle.packages[dir] = pkg
+	return pkg
+}
+
+// nearestPackage returns the package.json closest to dir.
+func (bundle *_bundle) nearestPackage(dir string) *packageJSON {
+	dir, err := bundle.fs.abs(dir)
+	if err != nil {
+		return nil
+	}
+	for {
+		if pkg := bundle.packageJSON(dir); pkg != nil {
+			return pkg
+		}
+		if filepath.Base(dir) == "node_modules" {
+			return nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+}
+
+// splitPackageName splits a bare module name into the package name and the
+// subpath within the package, eg. "@scope/pkg/lib/a" -> "@scope/pkg", "./lib/a".
+func splitPackageName(importValue string) (string, string) {
+	parts := strings.SplitN(importValue, "/", 3)
+	n := 1
+	if strings.HasPrefix(importValue, "@") && len(parts) > 1 {
+		n = 2
+	}
+	if len(parts) <= n {
+		return importValue, "."
+	}
+	name := strings.Join(parts[:n], "/")
+	return name, "./" + strings.TrimPrefix(importValue, name+"/")
+}
@@ -0,0 +1,239 @@
+package generator
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolve(t *testing.T) {
+	root := testdataPath(t, "resolve")
+
+	from := filepath.Join(root, "src/index.js")
+	fromBr := filepath.Join(root, "node_modules/br/index.js")
+
+	tests := []struct {
+		importValue string
+		from        string
+		kind        importKind
+		expected    string
+	}{
+		{"./helper", from, kindImport, "src/helper.js"},
+		{"exp", from, kindImport, "node_modules/exp/esm.js"},
+		{"exp", from, kindRequire, "node_modules/exp/cjs.js"},
+		{"exp/features/a", from, kindImport, "node_modules/exp/features/a.js"},
+		{"mod", from, kindImport, "node_modules/mod/module.js"},
+		{"badmain", from, kindImport, "node_modules/badmain/index.js"},
+		{"@scope/pkg/sub", from, kindImport, "node_modules/@scope/pkg/sub.js"},
+		{"./lib/node", fromBr, kindRequire, "node_modules/br/lib/browser.js"},
+		{"./lib/server", fromBr, kindRequire, emptyModulePath},
+		{"fs", fromBr, kindRequire, emptyModulePath},
+	}
+
+	for _, test := range tests {
+		bundle := newBundle(Config{Resolve: DefaultResolveConfig})
+		resolved, err := bundle.resolve(test.importValue, test.from, test.kind)
+		assert.NoError(t, err, test.importValue)
+
+		expected := test.expected
+		if expected != emptyModulePath {
+			expected = filepath.Join(root, expected)
+		}
+		assert.Equal(t, expected, resolved, test.importValue)
+	}
+
+	// the first key of the browser field that names a file replaces it
+	for i := 0; i < 10; i++ {
+		bundle := newBundle(Config{Resolve: DefaultResolveConfig})
+		resolved, err := bundle.resolve("./other", filepath.Join(root, "node_modules/br/lib/node.js"), kindRequire)
+		assert.NoError(t, err)
+		assert.Equal(t, filepath.Join(root, "node_modules/br/lib/browser.js"), resolved)
+	}
+
+	// without the browser main field, the node files are used
+	bundle := newBundle(Config{Resolve: ResolveConfig{MainFields: []string{"main"}}})
+	resolved, err := bundle.resolve("./lib/node", fromBr, kindRequire)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "node_modules/br/lib/node.js"), resolved)
+	resolved, err = bundle.resolve("mod", from, kindImport)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "node_modules/mod/main.js"), resolved)
+
+	// subpaths that are not exported cannot be imported
+	for _, importValue := range []string{"exp/cjs.js", "exp/features/private/b", "@scope/pkg"} {
+		_, err := bundle.resolve(importValue, from, kindImport)
+		assert.Error(t, err, importValue)
+	}
+
+	// configured aliases, extensions, main files and module directories
+	bundle = newBundle(Config{Resolve: ResolveConfig{
+		Alias:             map[string]string{"@app": "./testdata/resolve/src"},
+		Extensions:        []string{".js", ".jsx"},
+		MainFiles:         []string{"index", "default"},
+		ModuleDirectories: []string{filepath.Join(root, "vendor"), "node_modules"},
+	}})
+	configTests := map[string]string{
+		"@app/helper":            "src/helper.js",
+		"@app/components/Button": "src/components/Button.jsx",
+		"./widgets":              "src/widgets/default.js",
+		"shared":                 "vendor/shared/index.js",
+		"mod":                    "node_modules/mod/module.js",
+	}
+	for importValue, expected := range configTests {
+		resolved, err := bundle.resolve(importValue, from, kindImport)
+		assert.NoError(t, err, importValue)
+		assert.Equal(t, filepath.Join(root, expected), resolved, importValue)
+	}
+}
+
+func TestResolveTSConfig(t *testing.T) {
+	root := testdataPath(t, "tsconfig")
+
+	from := filepath.Join(root, "src/app/index.js")
+	tests := map[string]string{
+		"components/Button":    "src/components/Button.js",
+		"@shared/utils/format": "shared/utils/format.js",
+		"config":               "src/app/config.js",
+	}
+
+	bundle := newBundle(Config{})
+	for importValue, expected := range tests {
+		resolved, err := bundle.resolve(importValue, from, kindImport)
+		assert.NoError(t, err, importValue)
+		assert.Equal(t, filepath.Join(root, expected), resolved, importValue)
+	}
+
+	bundle = newBundle(Config{Resolve: ResolveConfig{TSConfigFiles: []string{}}})
+	_, err := bundle.resolve("components/Button", from, kindImport)
+	assert.Error(t, err)
+}
+
+func TestResolveTSConfigPackages(t *testing.T) {
+	fsys := fstest.MapFS{
+		"node_modules/@org/config/tsconfig.json": {Data: []byte(`{"compilerOptions": {"paths": {"lib": ["./lib.js"]}}}`)},
+		"node_modules/@org/config/lib.js":        {Data: []byte("")},
+		"app/tsconfig.json":                      {Data: []byte(`{"extends": "@org/config"}`)},
+		"app/index.js":                           {Data: []byte("")},
+		"other/tsconfig.json":                    {Data: []byte(`{"extends": "@org/missing/tsconfig.json"}`)},
+		"other/index.js":                         {Data: []byte("")},
+		"node_modules/@org/base/tsconfig.json":   {Data: []byte(`{"compilerOptions": {"paths": {"lib": ["./base.js"], "helpers": ["./helpers.js"]}}}`)},
+		"node_modules/@org/base/helpers.js":      {Data: []byte("")},
+		"list/tsconfig.json":                     {Data: []byte(`{"extends": ["@org/base", "@org/config"]}`)},
+		"list/index.js":                          {Data: []byte("")},
+		"bad/tsconfig.json":                      {Data: []byte(`{"extends": {"path": "@org/config"}}`)},
+		"bad/index.js":                           {Data: []byte("")},
+		"tied/tsconfig.json":                     {Data: []byte(`{"compilerOptions": {"paths": {"x/*.js": ["./second/*.js"], "x/*": ["./first/*"]}}}`)},
+		"tied/index.js":                          {Data: []byte("")},
+		"tied/first/y.js":                        {Data: []byte("")},
+		"tied/second/y.js":                       {Data: []byte("")},
+	}
+	bundle := newBundle(Config{FS: fsys})
+
+	// "extends" names a config file in a package
+	resolved, err := bundle.resolve("lib", "/app/index.js", kindImport)
+	assert.NoError(t, err)
+	assert.Equal(t, "/node_modules/@org/config/lib.js", resolved)
+	_, err = bundle.resolve("lib", "/other/index.js", kindImport)
+	assert.EqualError(t, err, "Cannot find config file @org/missing/tsconfig.json extended by /other/tsconfig.json")
+
+	// a list of config files is merged in order, the later ones overriding
+	// the options of the earlier ones
+	resolved, err = bundle.resolve("lib", "/list/index.js", kindImport)
+	assert.NoError(t, err)
+	assert.Equal(t, "/node_modules/@org/config/lib.js", resolved)
+	_, err = bundle.resolve("helpers", "/list/index.js", kindImport)
+	assert.Error(t, err)
+	_, err = bundle.resolve("lib", "/bad/index.js", kindImport)
+	assert.EqualError(t, err, "Invalid config file /bad/tsconfig.json: \"extends\" is not a string or an array of strings")
+
+	// patterns with prefixes of the same length are tried in order
+	for i := 0; i < 10; i++ {
+		resolved, err = newBundle(Config{FS: fsys}).resolve("x/y.js", "/tied/index.js", kindImport)
+		assert.NoError(t, err)
+		assert.Equal(t, "/tied/second/y.js", resolved)
+	}
+}
+
+func TestResolveSymlinks(t *testing.T) {
+	root := testdataPath(t, "symlinks")
+
+	from := filepath.Join(root, "app/index.js")
+	lib := filepath.Join(root, "packages/lib/index.js")
+
+	bundle := newBundle(Config{})
+	resolved, err := bundle.resolve("lib", from, kindImport)
+	assert.NoError(t, err)
+	assert.Equal(t, lib, resolved)
+
+	// dependencies of the linked package are found next to its real path
+	resolved, err = bundle.resolve("dep", lib, kindRequire)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "packages/lib/node_modules/dep/index.js"), resolved)
+
+	// the package is only loaded once however it is imported
+	name1, err := bundle.resolveModule("lib", from, kindImport)
+	assert.NoError(t, err)
+	name2, err := bundle.resolveModule("../packages/lib", from, kindImport)
+	assert.NoError(t, err)
+	assert.Equal(t, name1, name2)
+
+	bundle = newBundle(Config{Resolve: ResolveConfig{PreserveSymlinks: true}})
+	resolved, err = bundle.resolve("lib", from, kindImport)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "app/node_modules/lib/index.js"), resolved)
+}
+
+// testdataPath returns the absolute real path of a directory in testdata.
+func testdataPath(t *testing.T, name string) string {
+	path, err := filepath.Abs(filepath.Join("testdata", name))
+	assert.NoError(t, err)
+	path, err = filepath.EvalSymlinks(path)
+	assert.NoError(t, err)
+	return path
+}
+
+func TestResolveBuiltins(t *testing.T) {
+	root := testdataPath(t, "builtins")
+	from := filepath.Join(root, "index.js")
+
+	tests := map[string]string{
+		"path":      builtinPathPrefix + "path",
+		"node:util": builtinPathPrefix + "util",
+		"fs":        emptyModulePath,
+		"url":       filepath.Join(root, "node_modules/url/index.js"),
+		"node:url":  emptyModulePath,
+	}
+	bundle := newBundle(Config{})
+	for importValue, expected := range tests {
+		resolved, err := bundle.resolve(importValue, from, kindRequire)
+		assert.NoError(t, err, importValue)
+		assert.Equal(t, expected, resolved, importValue)
+	}
+
+	bundle = newBundle(Config{Resolve: ResolveConfig{Builtins: map[string]string{
+		"fs":   "./testdata/builtins/shims/fs",
+		"path": "",
+		"url":  "url",
+	}}})
+	tests = map[string]string{
+		"fs":   filepath.Join(root, "shims/fs.js"),
+		"path": emptyModulePath,
+		"url":  filepath.Join(root, "node_modules/url/index.js"),
+	}
+	for importValue, expected := range tests {
+		resolved, err := bundle.resolve(importValue, from, kindRequire)
+		assert.NoError(t, err, importValue)
+		assert.Equal(t, expected, resolved, importValue)
+	}
+
+	// node bundles use the native core modules
+	bundle = newBundle(Config{Platform: PlatformNode})
+	for _, importValue := range []string{"fs", "url", "node:path"} {
+		resolved, err := bundle.resolve(importValue, from, kindRequire)
+		assert.NoError(t, err, importValue)
+		assert.Equal(t, builtinPathPrefix+strings.TrimPrefix(importValue, "node:"), resolved, importValue)
+	}
+}
@@ -0,0 +1,172 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ModuleType tells the bundler how to include the output of the loaders.
+type ModuleType string
+
+const (
+	// ModuleAuto picks the type from the file extension: javascript and
+	// TypeScript files are parsed, .json files are loaded as JSON and the
+	// output of the loaders of other files is included as an asset. Other
+	// files without a loader are an error.
+	ModuleAuto ModuleType = ""
+
+	// ModuleJavaScript output is parsed and generated like a .js file.
+	ModuleJavaScript ModuleType = "js"
+
+	// ModuleJSON output is validated and exported as a JSON value.
+	ModuleJSON ModuleType = "json"
+
+	// ModuleAsset output is opaque to the bundler: it is included in the
+	// module function as it is, without being parsed.
+	ModuleAsset ModuleType = "asset"
+)
+
+// LoaderRule selects the loaders and module type of the files it matches.
+// All of the conditions that are set must match.
+type LoaderRule struct {
+	// Test is a glob pattern, eg. "*.module.css". It is matched against the
+	// file name, or against the whole slash separated path if it contains a
+	// "/".
+	Test string
+
+	// TestRegexp is matched against the slash separated absolute path.
+	TestRegexp *regexp.Regexp
+
+	// Include and Exclude are directories. Relative directories are
+	// relative to the working directory, except for plain names such as
+	// "node_modules", which match a directory of that name anywhere in the
+	// path. If Include is set, the file must be in one of the directories.
+	Include []string
+	Exclude []string
+
+	// Query is the resource query the import must have, eg. "?raw".
+	Query string
+
+	// Loaders transform the file in order.
+	Loaders []ModuleLoader
+
+	// Type is the type of the output of the loaders.
+	Type ModuleType
+}
+
+// matches reports whether the rule applies to the file at the absolute path
+// imported with query.
+func (rule *LoaderRule) matches(fsys fileSystem, absPath, query string) bool {
+	slashPath := filepath.ToSlash(absPath)
+	if rule.Test != "" {
+		name := path.Base(slashPath)
+		if strings.Contains(rule.Test, "/") {
+			name = slashPath
+		}
+		if ok, _ := path.Match(rule.Test, name); !ok {
+			return false
+		}
+	}
+	if rule.TestRegexp != nil && !rule.TestRegexp.MatchString(slashPath) {
+		return false
+	}
+	if rule.Query != "" && rule.Query != query {
+		return false
+	}
+	if len(rule.Include) > 0 && !inDirectories(fsys, absPath, rule.Include) {
+		return false
+	}
+	return !inDirectories(fsys, absPath, rule.Exclude)
+}
+
+// inDirectories reports whether the file at absPath is in one of dirs.
+func inDirectories(fsys fileSystem, absPath string, dirs []string) bool {
+	for _, dir := range dirs {
+		if !strings.ContainsAny(dir, `/\`) && !strings.HasPrefix(dir, ".") {
+			parts := strings.Split(filepath.ToSlash(filepath.Dir(absPath)), "/")
+			if containsString(parts, dir) {
+				return true
+			}
+			continue
+		}
+		abs, err := fsys.abs(dir)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(abs, absPath); err == nil && !strings.HasPrefix(rel, "..") {
+			return true
+		}
+	}
+	return false
+}
+
+// rules returns the loader rules of the config, followed by a rule for each
+// of the extensions in Loaders.
+func (c Config) rules() []LoaderRule {
+	rules := append([]LoaderRule{}, c.Rules...)
+
+	var exts []string
+	for ext := range c.Loaders {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	for _, ext := range exts {
+		rules = append(rules, LoaderRule{Test: "*" + ext, Loaders: c.Loaders[ext]})
+	}
+	return rules
+}
+
+// rule returns the first rule that matches the file, or an empty rule.
+func (bundle *_bundle) rule(absPath, query string) LoaderRule {
+	for _, rule := range bundle.rules {
+		if rule.matches(bundle.fs, absPath, query) {
+			return rule
+		}
+	}
+	return LoaderRule{}
+}
+
+// forExtension returns t, or the type of files with the extension ext if t
+// is ModuleAuto.
+func (t ModuleType) forExtension(ext string) ModuleType {
+	if t != ModuleAuto {
+		return t
+	}
+	switch ext {
+	case ".js", ".jsx", ".mjs", ".cjs":
+		return ModuleJavaScript
+	case ".ts", ".tsx", ".mts", ".cts":
+		// the output of a loader that compiles TypeScript, or TypeScript
+		// that is also valid javascript
+		return ModuleJavaScript
+	case ".json":
+		return ModuleJSON
+	}
+	return ModuleAsset
+}
+
+// TextLoader exports the contents of a file as a string. With the rule
+//
+//	LoaderRule{Query: "?raw", Loaders: []ModuleLoader{TextLoader}, Type: ModuleAsset}
+//
+// any file can be imported as text, eg. import svg from './icon.svg?raw'.
+var TextLoader = LoaderFunc(func(ctx *LoadContext, in io.Reader) (io.Reader, error) {
+	data, err := ioutil.ReadAll(in)
+	if err != nil {
+		return nil, err
+	}
+	var text bytes.Buffer
+	enc := json.NewEncoder(&text)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(string(data)); err != nil {
+		return nil, err
+	}
+	return strings.NewReader("module.exports = " + strings.TrimSpace(text.String()) + ";"), nil
+})
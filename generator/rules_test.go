@@ -0,0 +1,107 @@
+package generator
+
+import (
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoaderRuleMatches(t *testing.T) {
+	root := testdataPath(t, "rules")
+	css := filepath.Join(root, "a.module.css")
+	pkg := filepath.Join(root, "node_modules/pkg/index.js")
+
+	tests := []struct {
+		rule     LoaderRule
+		path     string
+		query    string
+		expected bool
+	}{
+		{LoaderRule{Test: "*.css"}, css, "", true},
+		{LoaderRule{Test: "*.module.css"}, filepath.Join(root, "b.css"), "", false},
+		{LoaderRule{Test: "rules/*.css"}, css, "", false},
+		{LoaderRule{Test: filepath.ToSlash(root) + "/*.css"}, css, "", true},
+		{LoaderRule{TestRegexp: regexp.MustCompile(`\.module\.css$`)}, css, "", true},
+		{LoaderRule{Query: "?raw"}, css, "", false},
+		{LoaderRule{Query: "?raw"}, css, "?raw", true},
+		{LoaderRule{Test: "*.js", Include: []string{"node_modules"}}, pkg, "", true},
+		{LoaderRule{Test: "*.js", Include: []string{"node_modules"}}, filepath.Join(root, "index.js"), "", false},
+		{LoaderRule{Test: "*.js", Exclude: []string{"node_modules"}}, pkg, "", false},
+		{LoaderRule{Include: []string{"./testdata/rules"}}, pkg, "", true},
+		{LoaderRule{Include: []string{"./testdata/rules/node_modules"}}, css, "", false},
+	}
+
+	for i, test := range tests {
+		assert.Equal(t, test.expected, test.rule.matches(osFS{}, test.path, test.query), "test %v", i)
+	}
+}
+
+func TestBundleLoaderRules(t *testing.T) {
+	loader := func(name string) ModuleLoader {
+		return LoaderFunc(func(ctx *LoadContext, in io.Reader) (io.Reader, error) {
+			return strings.NewReader("module.exports = '" + name + "';"), nil
+		})
+	}
+
+	result, err := Build("testdata/rules/index.js", Config{
+		Rules: []LoaderRule{
+			{Query: "?raw", Loaders: []ModuleLoader{TextLoader}, Type: ModuleAsset},
+			{Test: "*.module.css", Loaders: []ModuleLoader{loader("css module")}},
+			{Test: "*.js", Include: []string{"node_modules"}, Loaders: []ModuleLoader{loader("vendor")}, Type: ModuleJavaScript},
+		},
+		Loaders: map[string][]ModuleLoader{".css": {loader("css")}},
+	})
+	assert.NoError(t, err)
+
+	code := string(result.Code)
+	assert.Contains(t, code, `module.exports = "<svg></svg>\n";`)
+	assert.Contains(t, code, "module.exports = 'css module';")
+	assert.Contains(t, code, "module.exports = 'css';")
+	assert.Contains(t, code, "module.exports = 'vendor';")
+	assert.Contains(t, code, "module.exports = \"local\";")
+	assert.NotContains(t, code, "\"pkg\"")
+
+	// assets are included as they are, javascript has to parse
+	_, err = Build("testdata/rules/b.css", Config{Rules: []LoaderRule{{Test: "*.css", Type: ModuleJavaScript}}})
+	assert.Error(t, err)
+	result, err = Build("testdata/rules/local.js", Config{Rules: []LoaderRule{{Test: "*.js", Type: ModuleAsset}}})
+	assert.NoError(t, err)
+	assert.Contains(t, string(result.Code), "module.exports = \"local\";\n")
+
+	// files of unknown types need a loader
+	_, err = Build("testdata/rules/icon.svg", Config{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "No loader for .svg files: ")
+	}
+}
+
+func TestBundleTypeScript(t *testing.T) {
+	fsys := fstest.MapFS{
+		"src/index.js": {Data: []byte("import { add } from './add';\nadd(1, 2);\n")},
+		"src/add.ts":   {Data: []byte("export function add(a: number, b: number) { return a + b; }\n")},
+	}
+	stripTypes := LoaderFunc(func(ctx *LoadContext, in io.Reader) (io.Reader, error) {
+		data, err := ioutil.ReadAll(in)
+		if err != nil {
+			return nil, err
+		}
+		return strings.NewReader(strings.Replace(string(data), ": number", "", -1)), nil
+	})
+	resolve := ResolveConfig{Extensions: []string{".js", ".ts"}}
+
+	// TypeScript files are parsed like javascript after their loaders
+	result, err := Build("src/index.js", Config{FS: fsys, Resolve: resolve, Rules: []LoaderRule{{Test: "*.ts", Loaders: []ModuleLoader{stripTypes}}}})
+	assert.NoError(t, err)
+	assert.Contains(t, string(result.Code), "exports.add = function (a, b) {")
+	assert.NotContains(t, string(result.Code), "export function")
+
+	// and are not included as they are without one
+	_, err = Build("src/index.js", Config{FS: fsys, Resolve: resolve})
+	assert.Error(t, err)
+}
@@ -0,0 +1,466 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/walesey/go-bundle/ast"
+	"github.com/walesey/go-bundle/file"
+)
+
+// SourceMapMode selects how the source map of a bundle is output.
+type SourceMapMode string
+
+const (
+	// SourceMapNone does not generate a source map.
+	SourceMapNone SourceMapMode = ""
+
+	// SourceMapInline appends the source map to the bundle as a data URL.
+	SourceMapInline SourceMapMode = "inline"
+
+	// SourceMapExternal returns the source map in Result.SourceMap. Write
+	// stores it next to the bundle and links to it from the bundle.
+	SourceMapExternal SourceMapMode = "external"
+)
+
+// mapping links a position in the generated code to a position in a
+// source. Lines and columns start at 0. The source is -1 for positions that
+// are not mapped to a source.
+type mapping struct {
+	genLine, genColumn int
+	source             int
+	line, column       int
+}
+
+// sourceMap is a version 3 source map.
+type sourceMap struct {
+	Version        int       `json:"version"`
+	File           string    `json:"file,omitempty"`
+	SourceRoot     string    `json:"sourceRoot,omitempty"`
+	Sources        []string  `json:"sources"`
+	SourcesContent []*string `json:"sourcesContent"`
+	Names          []string  `json:"names"`
+	Mappings       string    `json:"mappings"`
+}
+
+// sourceLines converts positions in the source of a program to lines and
+// columns.
+type sourceLines struct {
+	base       int
+	lineStarts []int
+}
+
+func newSourceLines(f *file.File) *sourceLines {
+	lines := &sourceLines{base: f.Base(), lineStarts: []int{0}}
+	src := f.Source()
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\n' {
+			lines.lineStarts = append(lines.lineStarts, i+1)
+		}
+	}
+	return lines
+}
+
+// position returns the line and column of idx, both starting at 0.
+func (l *sourceLines) position(idx file.Idx) (int, int, bool) {
+	offset := int(idx) - l.base
+	if offset < 0 {
+		return 0, 0, false
+	}
+	line := sort.Search(len(l.lineStarts), func(i int) bool {
+		return l.lineStarts[i] > offset
+	}) - 1
+	return line, offset - l.lineStarts[line], true
+}
+
+// mark records the start of node, which is mapped to the next code written.
+// Nodes without a position, such as those added by the generator, keep the
+// mark of their parent.
+func (g *generator) mark(node ast.Node) {
+	if g.sourceLines != nil && node != nil {
+		if idx := node.Idx0(); idx != 0 {
+			g.pendingIdx = idx
+		}
+	}
+}
+
+// addMapping maps the first code in s, which is about to be written, to
+// the pending source position.
+func (g *generator) addMapping(s string) {
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return r != ' ' && r != '\n' && r != '\t'
+	})
+	if i == -1 {
+		return
+	}
+	line, column := g.currentLine, g.column+i
+	if nl := strings.LastIndex(s[:i], "\n"); nl != -1 {
+		line += strings.Count(s[:i], "\n")
+		column = i - nl - 1
+	}
+
+	if srcLine, srcColumn, ok := g.sourceLines.position(g.pendingIdx); ok {
+		g.mappings = append(g.mappings, mapping{
+			genLine:   line,
+			genColumn: column,
+			line:      srcLine,
+			column:    srcColumn,
+		})
+	}
+	g.pendingIdx = 0
+}
+
+// encodeMappings encodes mappings, sorted by generated position, in the
+// base64 VLQ format of the "mappings" field. Only the first mapping of each
+// generated position is kept.
+func encodeMappings(mappings []mapping) string {
+	var buf bytes.Buffer
+	var prev mapping
+	line, firstOnLine := 0, true
+	for _, m := range mappings {
+		if m.genLine > line {
+			buf.WriteString(strings.Repeat(";", m.genLine-line))
+			line, firstOnLine = m.genLine, true
+			prev.genColumn = 0
+		}
+		if !firstOnLine {
+			if m.genColumn <= prev.genColumn {
+				continue
+			}
+			buf.WriteByte(',')
+		}
+		writeVLQ(&buf, m.genColumn-prev.genColumn)
+		writeVLQ(&buf, m.source-prev.source)
+		writeVLQ(&buf, m.line-prev.line)
+		writeVLQ(&buf, m.column-prev.column)
+		prev, firstOnLine = m, false
+	}
+	return buf.String()
+}
+
+const base64Digits = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+func writeVLQ(buf *bytes.Buffer, value int) {
+	vlq := value << 1
+	if value < 0 {
+		vlq = (-value << 1) | 1
+	}
+	for {
+		digit := vlq & 31
+		vlq >>= 5
+		if vlq > 0 {
+			digit |= 32
+		}
+		buf.WriteByte(base64Digits[digit])
+		if vlq == 0 {
+			return
+		}
+	}
+}
+
+// sourceName returns the name of a module in the sources of the source map,
+// relative to the working directory.
+func (bundle *_bundle) sourceName(path string) string {
+	if root, err := bundle.fs.abs("."); err == nil && filepath.IsAbs(path) {
+		if rel, err := filepath.Rel(root, path); err == nil {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(path)
+}
+
+// sourceMapBuilder collects the mappings of the modules of a bundle.
+type sourceMapBuilder struct {
+	sources  []string
+	contents []*string
+	index    map[string]int
+	mappings []mapping
+}
+
+func (b *sourceMapBuilder) source(name string, content *string) int {
+	if i, ok := b.index[name]; ok {
+		return i
+	}
+	if b.index == nil {
+		b.index = make(map[string]int)
+	}
+	b.index[name] = len(b.sources)
+	b.sources = append(b.sources, name)
+	b.contents = append(b.contents, content)
+	return len(b.sources) - 1
+}
+
+// addModule adds the mappings of the module at path, which starts at line
+// and column of the bundle. Mappings of modules with an input source map are
+// traced back to its sources.
+func (b *sourceMapBuilder) addModule(bundle *_bundle, path string, mod *module, line, column int) {
+	if mod.inputMap == nil {
+		source := b.source(bundle.sourceName(path), &mod.source)
+		for _, m := range mod.mappings {
+			if m.genLine == 0 {
+				m.genColumn += column
+			}
+			m.genLine += line
+			m.source = source
+			b.mappings = append(b.mappings, m)
+		}
+		return
+	}
+
+	sources := make([]int, len(mod.inputMap.sources))
+	for i, name := range mod.inputMap.sources {
+		sources[i] = b.source(bundle.sourceName(name), mod.inputMap.contents[i])
+	}
+	for _, m := range mod.mappings {
+		original, ok := mod.inputMap.lookup(m.line, m.column)
+		if !ok {
+			continue
+		}
+		if m.genLine == 0 {
+			m.genColumn += column
+		}
+		m.genLine += line
+		m.source, m.line, m.column = sources[original.source], original.line, original.column
+		b.mappings = append(b.mappings, m)
+	}
+}
+
+// encode returns the JSON source map.
+func (b *sourceMapBuilder) encode() ([]byte, error) {
+	sources, contents := b.sources, b.contents
+	if sources == nil {
+		sources, contents = []string{}, []*string{}
+	}
+	return json.Marshal(sourceMap{
+		Version:        3,
+		Sources:        sources,
+		SourcesContent: contents,
+		Names:          []string{},
+		Mappings:       encodeMappings(b.mappings),
+	})
+}
+
+// inputSourceMap is the source map of a module that was compiled before it
+// is bundled, eg. from TypeScript. The sourcemap package is not used to read
+// it as it drops mappings to the start of a source.
+type inputSourceMap struct {
+	sources  []string
+	contents []*string
+
+	// mappings are sorted by generated position
+	mappings []mapping
+}
+
+// parseSourceMap reads a JSON source map. Relative sources are resolved
+// from dir, the directory of the map.
+func parseSourceMap(data []byte, dir string) (*inputSourceMap, error) {
+	var sm sourceMap
+	if err := json.Unmarshal(data, &sm); err != nil {
+		return nil, err
+	}
+	if sm.Version != 3 {
+		return nil, fmt.Errorf("Unsupported source map version: %v", sm.Version)
+	}
+	mappings, err := decodeMappings(sm.Mappings)
+	if err != nil {
+		return nil, err
+	}
+
+	input := &inputSourceMap{mappings: mappings}
+	for i, source := range sm.Sources {
+		if sm.SourceRoot != "" {
+			source = path.Join(sm.SourceRoot, source)
+		}
+		if !strings.Contains(source, "://") && !filepath.IsAbs(source) && dir != "" {
+			source = filepath.Join(dir, filepath.FromSlash(source))
+		}
+		input.sources = append(input.sources, source)
+		var content *string
+		if i < len(sm.SourcesContent) {
+			content = sm.SourcesContent[i]
+		}
+		input.contents = append(input.contents, content)
+	}
+	for _, m := range mappings {
+		if m.source >= len(input.sources) {
+			return nil, errors.New("Source map mappings refer to a missing source")
+		}
+	}
+	return input, nil
+}
+
+// lookup returns the mapping of the position in the generated code, which
+// is the closest mapping at or before it on the same line.
+func (m *inputSourceMap) lookup(line, column int) (mapping, bool) {
+	i := sort.Search(len(m.mappings), func(i int) bool {
+		next := m.mappings[i]
+		return next.genLine > line || (next.genLine == line && next.genColumn > column)
+	}) - 1
+	if i < 0 || m.mappings[i].genLine != line || m.mappings[i].source < 0 {
+		return mapping{}, false
+	}
+	return m.mappings[i], true
+}
+
+// compose returns a map from the generated code of m to the sources of
+// inner, which is the map of the code m was generated from.
+func (m *inputSourceMap) compose(inner *inputSourceMap) *inputSourceMap {
+	if inner == nil {
+		return m
+	}
+	composed := &inputSourceMap{sources: inner.sources, contents: inner.contents}
+	for _, outer := range m.mappings {
+		if outer.source < 0 {
+			continue
+		}
+		original, ok := inner.lookup(outer.line, outer.column)
+		if !ok {
+			continue
+		}
+		outer.source, outer.line, outer.column = original.source, original.line, original.column
+		composed.mappings = append(composed.mappings, outer)
+	}
+	return composed
+}
+
+// decodeMappings decodes the "mappings" field of a source map.
+func decodeMappings(s string) ([]mapping, error) {
+	var mappings []mapping
+	var prev mapping
+	for genLine, line := range strings.Split(s, ";") {
+		prev.genColumn = 0
+		for _, segment := range strings.Split(line, ",") {
+			if segment == "" {
+				continue
+			}
+			var fields []int
+			for i := 0; i < len(segment); {
+				value, next, err := readVLQ(segment, i)
+				if err != nil {
+					return nil, err
+				}
+				fields, i = append(fields, value), next
+			}
+			m := mapping{genLine: genLine, source: -1}
+			switch len(fields) {
+			case 1:
+				m.genColumn = prev.genColumn + fields[0]
+				prev.genColumn = m.genColumn
+			case 4, 5:
+				m.genColumn = prev.genColumn + fields[0]
+				m.source = prev.source + fields[1]
+				m.line = prev.line + fields[2]
+				m.column = prev.column + fields[3]
+				prev = m
+			default:
+				return nil, errors.New("Invalid source map mappings")
+			}
+			if m.genColumn < 0 || m.source < -1 || m.line < 0 || m.column < 0 {
+				return nil, errors.New("Invalid source map mappings")
+			}
+			mappings = append(mappings, m)
+		}
+	}
+	sort.SliceStable(mappings, func(i, j int) bool {
+		a, b := mappings[i], mappings[j]
+		return a.genLine < b.genLine || (a.genLine == b.genLine && a.genColumn < b.genColumn)
+	})
+	return mappings, nil
+}
+
+// readVLQ reads the base64 VLQ value starting at s[i], and returns it with
+// the index after it.
+func readVLQ(s string, i int) (int, int, error) {
+	vlq, shift := 0, uint(0)
+	for {
+		if i >= len(s) {
+			return 0, 0, errors.New("Invalid source map mappings")
+		}
+		digit := strings.IndexByte(base64Digits, s[i])
+		if digit == -1 {
+			return 0, 0, errors.New("Invalid source map mappings")
+		}
+		i++
+		vlq |= (digit & 31) << shift
+		shift += 5
+		if digit&32 == 0 {
+			break
+		}
+	}
+	if vlq&1 != 0 {
+		return -(vlq >> 1), i, nil
+	}
+	return vlq >> 1, i, nil
+}
+
+// sourceMappingURLPattern matches the comment linking a file to its source
+// map. Only the last one in a file applies.
+var sourceMappingURLPattern = regexp.MustCompile(`(?m)^[ \t]*//[#@] sourceMappingURL=(\S+)[ \t]*$`)
+
+// loadInputSourceMap returns the source map linked from the comment in the
+// source of the module at absPath, if there is one. Maps that cannot be read
+// are ignored, as many packages are published without the maps they link to.
+func (bundle *_bundle) loadInputSourceMap(absPath string, src []byte) *inputSourceMap {
+	matches := sourceMappingURLPattern.FindAllSubmatch(src, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+	link := string(matches[len(matches)-1][1])
+	dir := filepath.Dir(absPath)
+
+	var data []byte
+	if strings.HasPrefix(link, "data:") {
+		comma := strings.IndexByte(link, ',')
+		if comma == -1 {
+			return nil
+		}
+		header, payload := link[:comma], link[comma+1:]
+		var err error
+		if strings.HasSuffix(header, ";base64") {
+			data, err = base64.StdEncoding.DecodeString(payload)
+		} else {
+			payload, err = url.PathUnescape(payload)
+			data = []byte(payload)
+		}
+		if err != nil {
+			return nil
+		}
+	} else {
+		name, err := url.PathUnescape(link)
+		if err != nil || strings.Contains(name, "://") || isVirtualPath(absPath) {
+			return nil
+		}
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(dir, filepath.FromSlash(name))
+		}
+		if data, err = bundle.fs.readFile(name); err != nil {
+			return nil
+		}
+		bundle.dependencies[name] = true
+		dir = filepath.Dir(name)
+	}
+
+	input, err := parseSourceMap(data, dir)
+	if err != nil {
+		return nil
+	}
+	return input
+}
+
+// sourceMappingURL returns the comment linking code to its source map.
+func sourceMappingURL(url string) string {
+	return "\n//# sourceMappingURL=" + url + "\n"
+}
+
+func inlineSourceMap(data []byte) string {
+	return sourceMappingURL("data:application/json;charset=utf-8;base64," + base64.StdEncoding.EncodeToString(data))
+}
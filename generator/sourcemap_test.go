@@ -0,0 +1,178 @@
+package generator
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/sourcemap.v1"
+)
+
+func TestEncodeMappings(t *testing.T) {
+	assert.Equal(t, "AAAA,IAAI;;EACF", encodeMappings([]mapping{
+		{genLine: 0, genColumn: 0, line: 0, column: 0},
+		{genLine: 0, genColumn: 4, line: 0, column: 4},
+		{genLine: 0, genColumn: 4, line: 1, column: 0},
+		{genLine: 2, genColumn: 2, line: 1, column: 2},
+	}))
+
+	// only the first mapping of a generated position is kept
+	assert.Equal(t, "AAAA,gBAAgB", encodeMappings([]mapping{
+		{genLine: 0, genColumn: 0, line: 0, column: 0},
+		{genLine: 0, genColumn: 16, line: 0, column: 16},
+		{genLine: 0, genColumn: 16, line: 0, column: 20},
+	}))
+}
+
+func TestBundleSourceMap(t *testing.T) {
+	result, err := Build("./testdata/sourcemap/index.js", Config{SourceMap: SourceMapExternal})
+	assert.NoError(t, err)
+	assert.NotContains(t, string(result.Code), "sourceMappingURL")
+
+	consumer, err := sourcemap.Parse("index.js.map", result.SourceMap)
+	assert.NoError(t, err)
+
+	// the throw statement in helper.js
+	lines := strings.Split(string(result.Code), "\n")
+	for i, line := range lines {
+		if column := strings.Index(line, "throw"); column != -1 {
+			source, _, srcLine, srcColumn, ok := consumer.Source(i+1, column)
+			assert.True(t, ok)
+			assert.Equal(t, "testdata/sourcemap/helper.js", source)
+			assert.Equal(t, 4, srcLine)
+			assert.Equal(t, 4, srcColumn)
+		}
+		if column := strings.Index(line, "helper.compute"); column != -1 {
+			source, _, srcLine, srcColumn, ok := consumer.Source(i+1, column)
+			assert.True(t, ok)
+			assert.Equal(t, "testdata/sourcemap/index.js", source)
+			assert.Equal(t, 4, srcLine)
+			assert.Equal(t, 14, srcColumn)
+		}
+	}
+
+	// inline
+	result, err = Build("./testdata/sourcemap/index.js", Config{SourceMap: SourceMapInline})
+	assert.NoError(t, err)
+	assert.Nil(t, result.SourceMap)
+	prefix := "//# sourceMappingURL=data:application/json;charset=utf-8;base64,"
+	index := strings.LastIndex(string(result.Code), prefix)
+	assert.NotEqual(t, -1, index)
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(result.Code[index+len(prefix):])))
+	assert.NoError(t, err)
+	_, err = sourcemap.Parse("index.js.map", data)
+	assert.NoError(t, err)
+
+	// written next to the bundle
+	result, err = Build("./testdata/sourcemap/index.js", Config{SourceMap: SourceMapExternal})
+	assert.NoError(t, err)
+	dir := t.TempDir()
+	manifest, err := result.Write(OutputConfig{Dir: dir, EntryNames: "[name].[hash].js"})
+	assert.NoError(t, err)
+	codeName := "index." + contentHash(result.Code) + ".js"
+	assert.Equal(t, codeName, manifest["index.js"].File)
+	assert.Equal(t, codeName+".map", manifest["index.js.map"].File)
+	code, err := os.ReadFile(filepath.Join(dir, codeName))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(string(code), "\n//# sourceMappingURL="+codeName+".map\n"))
+	assert.FileExists(t, filepath.Join(dir, codeName+".map"))
+}
+
+func TestDecodeMappings(t *testing.T) {
+	mappings := []mapping{
+		{genLine: 0, genColumn: 0, line: 0, column: 0},
+		{genLine: 0, genColumn: 8, line: 0, column: 16},
+		{genLine: 1, genColumn: 4, source: 1, line: 1, column: 2},
+		{genLine: 3, genColumn: 0, source: 1, line: 2, column: 0},
+	}
+	decoded, err := decodeMappings(encodeMappings(mappings))
+	assert.NoError(t, err)
+	assert.Equal(t, mappings, decoded)
+
+	// segments without a source
+	decoded, err = decodeMappings("AAAA,E;CACA")
+	assert.NoError(t, err)
+	assert.Equal(t, []mapping{
+		{genLine: 0, genColumn: 0},
+		{genLine: 0, genColumn: 2, source: -1},
+		{genLine: 1, genColumn: 1, line: 1},
+	}, decoded)
+
+	_, err = decodeMappings("AA")
+	assert.Error(t, err)
+	_, err = decodeMappings("A!AA")
+	assert.Error(t, err)
+}
+
+func TestBundleInputSourceMap(t *testing.T) {
+	// findSource returns the original position of the first line of the
+	// bundle containing code
+	findSource := func(result *Result, code string) (string, int, int) {
+		consumer, err := sourcemap.Parse("index.js.map", result.SourceMap)
+		assert.NoError(t, err)
+		for i, line := range strings.Split(string(result.Code), "\n") {
+			if column := strings.Index(line, code); column != -1 {
+				source, _, line, column, ok := consumer.Source(i+1, column)
+				assert.True(t, ok)
+				return source, line, column
+			}
+		}
+		t.Fatalf("%v not found in bundle", code)
+		return "", 0, 0
+	}
+
+	// linked from a comment
+	result, err := Build("./testdata/inputmap/index.js", Config{SourceMap: SourceMapExternal})
+	assert.NoError(t, err)
+	source, line, column := findSource(result, "throw")
+	assert.Equal(t, "testdata/inputmap/greet.ts", source)
+	assert.Equal(t, 2, line)
+	assert.Equal(t, 2, column)
+	assert.Contains(t, result.Dependencies, testdataPath(t, "inputmap/greet.js.map"))
+
+	var written sourceMap
+	assert.NoError(t, json.Unmarshal(result.SourceMap, &written))
+	assert.Equal(t, []string{"testdata/inputmap/greet.ts", "testdata/inputmap/index.js"}, written.Sources)
+	assert.Nil(t, written.SourcesContent[0])
+
+	// inlined as a data URL
+	mapData, err := os.ReadFile("testdata/inputmap/greet.js.map")
+	assert.NoError(t, err)
+	compiled := "exports.greet = function (name) {\n    throw new Error('hello ' + name);\n};\n" +
+		"//# sourceMappingURL=data:application/json;base64," + base64.StdEncoding.EncodeToString(mapData) + "\n"
+	result, err = BuildSource(compiled, "testdata/inputmap/inline.js", Config{SourceMap: SourceMapExternal})
+	assert.NoError(t, err)
+	source, line, _ = findSource(result, "throw")
+	assert.Equal(t, "testdata/inputmap/greet.ts", source)
+	assert.Equal(t, 2, line)
+
+	// set by a loader, which adds a line to the start of the file
+	wrap := LoaderFunc(func(ctx *LoadContext, in io.Reader) (io.Reader, error) {
+		ctx.SetSourceMap([]byte(`{"version":3,"sources":["wrapped.js"],"names":[],"mappings":";AAAA;AACA;AACA"}`))
+		return io.MultiReader(strings.NewReader("'use strict';\n"), in), nil
+	})
+	result, err = Build("./testdata/inputmap/wrapped.js", Config{
+		SourceMap: SourceMapExternal,
+		Rules:     []LoaderRule{{Test: "wrapped.js", Loaders: []ModuleLoader{wrap}}},
+	})
+	assert.NoError(t, err)
+	source, line, _ = findSource(result, "throw")
+	assert.Equal(t, "testdata/inputmap/wrapped.js", source)
+	assert.Equal(t, 2, line)
+
+	// invalid maps set by loaders fail the build
+	invalid := LoaderFunc(func(ctx *LoadContext, in io.Reader) (io.Reader, error) {
+		ctx.SetSourceMap([]byte(`{"version":2}`))
+		return in, nil
+	})
+	_, err = Build("./testdata/inputmap/wrapped.js", Config{
+		SourceMap: SourceMapExternal,
+		Rules:     []LoaderRule{{Test: "wrapped.js", Loaders: []ModuleLoader{invalid}}},
+	})
+	assert.Error(t, err)
+}
@@ -5,14 +5,23 @@ import (
 	"reflect"
 
 	"github.com/walesey/go-bundle/ast"
+	"github.com/walesey/go-bundle/token"
 )
 
 func (g *generator) generateStatement(stmt ast.Statement, dcls []ast.Declaration) error {
-	defer func() {
-		if g.isElseStatement {
-			g.isElseStatement = false
-		}
-	}()
+	if err := g.checkFormat(stmt); err != nil {
+		return err
+	}
+	g.writeLeadingComments(stmt)
+	if err := g.statement(stmt, dcls); err != nil {
+		return err
+	}
+	g.writeTrailingComments(stmt)
+	return nil
+}
+
+func (g *generator) statement(stmt ast.Statement, dcls []ast.Declaration) error {
+	g.mark(stmt)
 	switch stmt.(type) {
 	case *ast.VariableStatement:
 		return g.variableStatement(stmt.(*ast.VariableStatement))
@@ -30,6 +39,8 @@ func (g *generator) generateStatement(stmt ast.Statement, dcls []ast.Declaration
 		return g.throwStatement(stmt.(*ast.ThrowStatement))
 	case *ast.ForStatement:
 		return g.forStatement(stmt.(*ast.ForStatement))
+	case *ast.ForOfStatement:
+		return g.forOfStatement(stmt.(*ast.ForOfStatement))
 	case *ast.ForInStatement:
 		return g.forInStatement(stmt.(*ast.ForInStatement))
 	case *ast.BranchStatement:
@@ -42,10 +53,16 @@ func (g *generator) generateStatement(stmt ast.Statement, dcls []ast.Declaration
 		return g.whileStatement(stmt.(*ast.WhileStatement))
 	case *ast.DoWhileStatement:
 		return g.doWhileStatement(stmt.(*ast.DoWhileStatement))
+	case *ast.WithStatement:
+		return g.withStatement(stmt.(*ast.WithStatement))
+	case *ast.DebuggerStatement:
+		return g.debuggerStatement(stmt.(*ast.DebuggerStatement))
 	case *ast.SwitchStatement:
 		return g.switchStatement(stmt.(*ast.SwitchStatement))
 	case *ast.FunctionStatement:
 		return g.functionStatement(stmt.(*ast.FunctionStatement))
+	case *ast.ClassStatement:
+		return g.classStatement(stmt.(*ast.ClassStatement))
 	case *ast.LabelledStatement:
 		return g.labelledStatement(stmt.(*ast.LabelledStatement))
 	case *ast.ImportStatement:
@@ -68,7 +85,11 @@ func (g *generator) switchStatement(d *ast.SwitchStatement) error {
 
 	g.write(") {")
 	g.indentLevel++
-	for _, c := range d.Body {
+	for i, c := range d.Body {
+		// the end of a case is only known from its statements
+		if i > 0 && len(d.Body[i-1].Consequent) > 0 && g.blankLineBetween(d.Body[i-1], c) {
+			g.writeBlankLine()
+		}
 		if err := g.caseStatement(c); err != nil {
 			return err
 		}
@@ -91,10 +112,8 @@ func (g *generator) caseStatement(c *ast.CaseStatement) error {
 	}
 
 	g.indentLevel++
-	for _, stmt := range c.Consequent {
-		if err := g.generateStatement(stmt, []ast.Declaration{}); err != nil {
-			return err
-		}
+	if err := g.statementList(c.Consequent); err != nil {
+		return err
 	}
 	g.indentLevel--
 
@@ -103,10 +122,14 @@ func (g *generator) caseStatement(c *ast.CaseStatement) error {
 
 func (g *generator) doWhileStatement(d *ast.DoWhileStatement) error {
 	g.writeLine("do ")
-	if err := g.generateStatement(d.Body, nil); err != nil {
+	if err := g.bodyStatement(d.Body); err != nil {
 		return err
 	}
-	g.write(" while (")
+	if _, ok := d.Body.(*ast.BlockStatement); ok || !g.options.Print.KeepLayout {
+		g.write(" while (")
+	} else {
+		g.writeLine("while (")
+	}
 	if err := g.generateExpression(d.Test); err != nil {
 		return err
 	}
@@ -120,15 +143,33 @@ func (g *generator) whileStatement(w *ast.WhileStatement) error {
 		return err
 	}
 	g.write(") ")
-	return g.generateStatement(w.Body, nil)
+	return g.bodyStatement(w.Body)
 }
 
-func (g *generator) catchStatement(c *ast.CatchStatement) error {
-	g.write(" catch (")
-	if err := g.identifier(c.Parameter); err != nil {
+func (g *generator) withStatement(w *ast.WithStatement) error {
+	g.writeLine("with (")
+	if err := g.generateExpression(w.Object); err != nil {
 		return err
 	}
 	g.write(") ")
+	return g.bodyStatement(w.Body)
+}
+
+// catchStatement generates c. A catch without a binding is given one before
+// ES2019, eg. catch (_e).
+func (g *generator) catchStatement(c *ast.CatchStatement) error {
+	switch {
+	case c.Parameter != nil:
+		g.write(" catch (")
+		if err := g.identifier(c.Parameter); err != nil {
+			return err
+		}
+		g.write(") ")
+	case g.supports(es2019):
+		g.write(" catch ")
+	default:
+		g.write(" catch (" + g.freeName("_e") + ") ")
+	}
 	return g.generateStatement(c.Body, nil)
 }
 
@@ -154,16 +195,20 @@ func (g *generator) tryStatement(t *ast.TryStatement) error {
 func (g *generator) branchStatement(b *ast.BranchStatement) error {
 	g.writeLine(b.Token.String())
 	if b.Label != nil {
+		g.write(" ")
 		if err := g.generateExpression(b.Label); err != nil {
 			return err
 		}
 	}
-	g.write(";")
+	g.endStatement()
 	return nil
 }
 
 func (g *generator) forInStatement(f *ast.ForInStatement) error {
-	g.writeLine("for (var ")
+	g.writeLine("for (")
+	if _, ok := f.Into.(*ast.VariableExpression); ok {
+		g.write("var ")
+	}
 	if err := g.generateExpression(f.Into); err != nil {
 		return err
 	}
@@ -172,26 +217,67 @@ func (g *generator) forInStatement(f *ast.ForInStatement) error {
 		return err
 	}
 	g.write(") ")
-	return g.generateStatement(f.Body, nil)
+	return g.bodyStatement(f.Body)
+}
+
+// forInitializer generates the initializer of a for statement. Expressions
+// containing in are parenthesized, as they would be read as a for-in
+// statement.
+func (g *generator) forInitializer(exp ast.Expression) error {
+	prec := func(exp ast.Expression, lowest int) int {
+		if hasIn(exp) {
+			return precPrimary + 1
+		}
+		return lowest
+	}
+	if s, ok := exp.(*ast.SequenceExpression); ok && len(s.Sequence) > 0 {
+		if _, ok := s.Sequence[0].(*ast.VariableExpression); ok {
+			g.write("var ")
+			for i, e := range s.Sequence {
+				v, ok := e.(*ast.VariableExpression)
+				if !ok {
+					return fmt.Errorf("Expected a variable declaration in for statement")
+				}
+				g.mark(v)
+				g.write(v.Name)
+				if v.Initializer != nil {
+					g.write(" = ")
+					if err := g.subExpression(v.Initializer, prec(v.Initializer, precAssign)); err != nil {
+						return err
+					}
+				}
+				if i < len(s.Sequence)-1 {
+					g.write(", ")
+				}
+			}
+			return nil
+		}
+	}
+	return g.subExpression(exp, prec(exp, precLowest))
 }
 
 func (g *generator) forStatement(f *ast.ForStatement) error {
 	g.writeLine("for (")
-	g.isInInitializer = true
-	if err := g.generateExpression(f.Initializer); err != nil {
+	if err := g.forInitializer(f.Initializer); err != nil {
 		return err
 	}
-	g.isInInitializer = false
-	g.write("; ")
+	// empty parts are only left out of the layout for KeepLayout
+	g.write(";")
+	if f.Test != nil || !g.options.Print.KeepLayout {
+		g.write(" ")
+	}
 	if err := g.generateExpression(f.Test); err != nil {
 		return err
 	}
-	g.write("; ")
+	g.write(";")
+	if f.Update != nil || !g.options.Print.KeepLayout {
+		g.write(" ")
+	}
 	if err := g.generateExpression(f.Update); err != nil {
-		return nil
+		return err
 	}
 	g.write(") ")
-	return g.generateStatement(f.Body, nil)
+	return g.bodyStatement(f.Body)
 }
 
 func (g *generator) throwStatement(t *ast.ThrowStatement) error {
@@ -200,48 +286,84 @@ func (g *generator) throwStatement(t *ast.ThrowStatement) error {
 	if err := g.generateExpression(t.Argument); err != nil {
 		return err
 	}
-	g.write(";")
+	g.endStatement()
 	return nil
 }
 
 func (g *generator) ifStatement(i *ast.IfStatement) error {
-	if !g.isElseStatement {
-		g.writeLine("")
-	}
-	g.write("if (")
+	g.writeLine("if (")
 	if err := g.generateExpression(i.Test); err != nil {
 		return err
 	}
 
 	g.write(") ")
 
-	if err := g.generateStatement(i.Consequent, nil); err != nil {
+	if err := g.bodyStatement(i.Consequent); err != nil {
 		return err
 	}
 
 	if i.Alternate != nil {
-		g.write(" else ")
-		g.isElseStatement = true
-		return g.generateStatement(i.Alternate, nil)
+		if _, ok := i.Consequent.(*ast.BlockStatement); ok || !g.options.Print.KeepLayout {
+			g.write(" else ")
+		} else {
+			g.writeLine("else ")
+		}
+		if _, ok := i.Alternate.(*ast.IfStatement); ok {
+			g.continueLine = true
+		}
+		return g.bodyStatement(i.Alternate)
 	}
 
 	return nil
 }
 
+func (g *generator) debuggerStatement(d *ast.DebuggerStatement) error {
+	g.writeLine("debugger")
+	g.endStatement()
+	return nil
+}
+
 func (g *generator) emptyStatement(r *ast.EmptyStatement) error {
 	return nil
 }
 
+// bodyStatement generates the body of a loop, if, with or label, which must be
+// written even if it is empty. For KeepLayout, bodies that are not blocks
+// are indented on the next line, unless they continue the line.
+func (g *generator) bodyStatement(stmt ast.Statement) error {
+	switch stmt.(type) {
+	case *ast.EmptyStatement:
+		g.write(";")
+		return nil
+	case *ast.BlockStatement:
+		return g.generateStatement(stmt, nil)
+	}
+	if g.continueLine || !g.options.Print.KeepLayout {
+		return g.generateStatement(stmt, nil)
+	}
+	g.indentLevel++
+	err := g.generateStatement(stmt, nil)
+	g.indentLevel--
+	return err
+}
+
 func (g *generator) returnStatement(r *ast.ReturnStatement) error {
 	g.writeLine("return ")
-	if fl, ok := r.Argument.(*ast.FunctionLiteral); ok {
-		g.functionLiteral(fl, false)
-	} else {
-		if err := g.generateExpression(r.Argument); err != nil {
+	if fn, ok := r.Argument.(*ast.FunctionLiteral); ok && !g.options.Print.KeepLayout {
+		// the function is written on the line of the return
+		g.writeInlineComments(fn)
+		g.mark(fn)
+		if err := g.functionLiteral(fn, false); err != nil {
 			return err
 		}
+		g.deferTrailingComments(fn)
+		g.endStatement()
+		return nil
 	}
-	g.write(";")
+	if err := g.generateExpression(r.Argument); err != nil {
+		return err
+	}
+	g.endStatement()
 	return nil
 }
 
@@ -249,16 +371,18 @@ func (g *generator) blockStatement(b *ast.BlockStatement, dcls []ast.Declaration
 	g.write("{")
 	g.indentLevel++
 
-	for _, stmt := range b.List {
-		if err := g.generateStatement(stmt, nil); err != nil {
-			return err
-		}
+	list := b.List
+	if prologue, ok := g.prologues[b]; ok {
+		delete(g.prologues, b)
+		list = append(prologue, list...)
 	}
-	for _, dcl := range dcls {
-		if err := g.generateDeclaration(dcl); err != nil {
-			return err
-		}
+	if err := g.statementList(list); err != nil {
+		return err
 	}
+	if err := g.generateDeclarations(dcls); err != nil {
+		return err
+	}
+	g.writeFinalComments(b)
 
 	g.indentLevel--
 	g.writeAlone("}")
@@ -267,36 +391,75 @@ func (g *generator) blockStatement(b *ast.BlockStatement, dcls []ast.Declaration
 
 func (g *generator) expressionStatement(e *ast.ExpressionStatement) error {
 	g.writeAlone("")
+	if startsStatementAmbiguously(e.Expression) {
+		// parenthesize the whole statement
+		g.precedence = precPrimary + 1
+	}
 	if err := g.generateExpression(e.Expression); err != nil {
 		return err
 	}
-	g.write(";")
+	g.endStatement()
 	return nil
 }
 
 func (g *generator) variableStatement(v *ast.VariableStatement) error {
-	g.writeLine("var ")
+	keyword := "var"
+	if (v.Token == token.LET || v.Token == token.CONST) && g.supports(es2015) {
+		keyword = v.Token.String()
+	}
+	g.writeLine(keyword + " ")
 
+	if value := destructured(v); value != nil && g.supports(es2015) {
+		if err := g.destructuring(v, value); err != nil {
+			return err
+		}
+		g.endStatement()
+		return nil
+	}
 	for i, vexp := range v.List {
-		if len(v.List) > 1 {
+		if i > 0 {
+			g.write(",")
+			if g.options.Print.KeepLayout {
+				// the following declarations are indented
+				g.indentLevel++
+				g.writeLine("")
+				g.indentLevel--
+			} else {
+				g.write("\n")
+			}
+		}
+		if len(v.List) > 1 && !g.options.Print.KeepLayout {
 			g.writeIndentation("")
 		}
-
 		if err := g.generateExpression(vexp); err != nil {
 			return err
 		}
-
-		if i < len(v.List)-1 {
-			g.write(",")
-			g.write("\n")
-		} else {
-			g.write(";")
-		}
 	}
+	g.endStatement()
 	return nil
 }
 
 func (g *generator) functionStatement(f *ast.FunctionStatement) error {
+	if !g.options.Print.KeepLayout {
+		// written with the declarations of the enclosing function
+		return nil
+	}
+	g.declared[f.Function] = true
+	return g.functionDeclaration(f.Function)
+}
+
+// statementList generates the statements of a program, block or case,
+// keeping the blank lines between them for KeepLayout.
+func (g *generator) statementList(list []ast.Statement) error {
+	for i, stmt := range list {
+		if i > 0 && g.blankLineBetween(list[i-1], stmt) {
+			g.writeLineEndComments(stmt, isLeadingComment)
+			g.writeBlankLine()
+		}
+		if err := g.generateStatement(stmt, nil); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -307,42 +470,51 @@ func (g *generator) labelledStatement(ls *ast.LabelledStatement) error {
 	}
 
 	g.write(": ")
-	return g.generateStatement(ls.Statement, []ast.Declaration{})
+	g.continueLine = g.options.Print.KeepLayout
+	return g.bodyStatement(ls.Statement)
 }
 
 func (g *generator) importStatement(i *ast.ImportStatement) error {
+	if g.options.format {
+		return g.formatImport(i)
+	}
 	modulePath := i.Path.Value
 	var err error
 	if g.bundle != nil {
-		if modulePath, err = g.bundle.resolveModule(i.Path.Value, g.filePath); err != nil {
+		if modulePath, err = g.bundle.resolveModule(i.Path.Value, g.filePath, kindImport); err != nil {
 			fmt.Println("Error Resolving Module: ", i.Path.Value)
 			return err
 		}
 	}
 
+	if g.bundle != nil && g.bundle.jsonModules[modulePath] {
+		return g.importJSON(i, modulePath)
+	}
+
 	if i.Default != nil {
 		g.writeLine("var ")
 		g.write(i.Default.Name)
 		g.write(" = require('")
-		g.write(modulePath)
+		g.writeRaw(modulePath)
 		g.write("').default")
 		g.write(" || ")
 		g.write("require('")
-		g.write(modulePath)
+		g.writeRaw(modulePath)
 		g.write("');")
 	} else if i.All != nil {
 		g.writeLine("var ")
 		g.write(i.All.Name)
+		g.usesBuiltin("Object.assign")
 		g.write(" = Object.assign({}, require('")
-		g.write(modulePath)
+		g.writeRaw(modulePath)
 		g.write("').default")
 		g.write(", ")
 		g.write("require('")
-		g.write(modulePath)
+		g.writeRaw(modulePath)
 		g.write("'));")
 	} else if i.List == nil {
-		g.write("require('")
-		g.write(modulePath)
+		g.writeLine("require('")
+		g.writeRaw(modulePath)
 		g.write("');")
 	}
 
@@ -350,32 +522,82 @@ func (g *generator) importStatement(i *ast.ImportStatement) error {
 		g.writeLine("var ")
 		g.write(ident.As.Name)
 		g.write(" = require('")
-		g.write(modulePath)
+		g.writeRaw(modulePath)
 		g.write("').")
 		g.write(ident.Name.Name)
-		g.write(";")
+		g.endStatement()
+	}
+
+	return nil
+}
+
+// importJSON imports a JSON module, whose default export is the whole value
+// and whose named exports are its top-level keys.
+func (g *generator) importJSON(i *ast.ImportStatement, modulePath string) error {
+	if i.Default != nil {
+		g.writeLine("var ")
+		g.write(i.Default.Name)
+		g.write(" = require('")
+		g.writeRaw(modulePath)
+		g.write("');")
+	} else if i.All != nil {
+		g.writeLine("var ")
+		g.write(i.All.Name)
+		g.usesBuiltin("Object.assign")
+		g.write(" = Object.assign({}, require('")
+		g.writeRaw(modulePath)
+		g.write("'), { default: require('")
+		g.writeRaw(modulePath)
+		g.write("') });")
+	} else if i.List == nil {
+		g.writeLine("require('")
+		g.writeRaw(modulePath)
+		g.write("');")
+	}
+
+	for _, ident := range i.List {
+		g.writeLine("var ")
+		g.write(ident.As.Name)
+		g.write(" = require('")
+		g.writeRaw(modulePath)
+		g.write("')")
+		if ident.Name.Name == "default" {
+			g.endStatement()
+			continue
+		}
+		g.write(".")
+		g.write(ident.Name.Name)
+		g.endStatement()
 	}
 
 	return nil
 }
 
 func (g *generator) exportStatement(e *ast.ExportStatement) error {
+	if g.options.format {
+		return g.formatExport(e)
+	}
 	switch e.Statement.(type) {
 	case *ast.VariableStatement:
 		varStmt := e.Statement.(*ast.VariableStatement)
-		for _, exp := range varStmt.List {
+		for i, exp := range varStmt.List {
 			g.writeLine("exports.")
 			if err := g.generateExpression(exp); err != nil {
 				return err
 			}
+			if i < len(varStmt.List)-1 {
+				g.endStatement()
+			}
 		}
 	case *ast.FunctionStatement:
 		funcStmt := e.Statement.(*ast.FunctionStatement)
 		g.writeLine("exports.")
-		g.write(funcStmt.Function.Name.Name)
-		g.write(" = (function ")
-		g.isCalleeExpression = false
-		if err := g.parameterList(funcStmt.Function.ParameterList); err != nil {
+		g.write(funcStmt.Function.Name.Name + " = ")
+		if err := g.async(funcStmt.Function); err != nil {
+			return err
+		}
+		g.write("function ")
+		if err := g.parameterList(funcStmt.Function); err != nil {
 			return err
 		}
 
@@ -383,23 +605,31 @@ func (g *generator) exportStatement(e *ast.ExportStatement) error {
 		if err := g.generateStatement(funcStmt.Function.Body, funcStmt.Function.DeclarationList); err != nil {
 			return err
 		}
-
-		g.write(")")
+	case *ast.ClassStatement:
+		class := e.Statement.(*ast.ClassStatement).Class
+		g.writeLine("exports.")
+		g.write(class.Name.Name + " = ")
+		if err := g.classLiteral(class); err != nil {
+			return err
+		}
 	default:
 		return fmt.Errorf("invalid export Statement <%v>", reflect.TypeOf(e.Statement))
 	}
-	g.write(";")
+	g.endStatement()
 
 	return nil
 }
 
 func (g *generator) exportDefaultStatement(e *ast.ExportDefaultStatement) error {
+	if g.options.format {
+		return g.formatExportDefault(e)
+	}
 	g.writeLine("exports.default = ")
 	if err := g.generateExpression(e.Argument); err != nil {
 		return err
 	}
 
-	g.write(";")
+	g.endStatement()
 
 	return nil
 }
@@ -0,0 +1,631 @@
+package generator
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/walesey/go-bundle/ast"
+	"github.com/walesey/go-bundle/token"
+)
+
+// Target is the version of JavaScript the generated code is written in, eg.
+// "es2015". Syntax of later versions is lowered to syntax of the target, so
+// the code runs on engines that only implement the target.
+type Target string
+
+const (
+	// TargetES5 lowers arrow functions, classes, template strings, let and
+	// const, default and rest parameters, for-of loops, computed keys and
+	// the exponentiation operator to ES5, for all browsers. It is the
+	// default, and can also be given as "es5". Async functions cannot be
+	// lowered, and are an error before ES2017.
+	TargetES5 Target = ""
+
+	// TargetES2015 keeps arrow functions, classes, template strings, let and
+	// const, destructuring, shorthand properties, default and rest
+	// parameters, for-of loops and computed keys.
+	TargetES2015 Target = "es2015"
+
+	// TargetES2016 also keeps the exponentiation operator.
+	TargetES2016 Target = "es2016"
+
+	// TargetES2017 also keeps async functions and trailing commas in
+	// argument lists.
+	TargetES2017 Target = "es2017"
+
+	// TargetES2018 also keeps object spread.
+	TargetES2018 Target = "es2018"
+
+	// TargetES2019 also keeps catch without a binding.
+	TargetES2019 Target = "es2019"
+
+	// TargetES2020 also keeps optional chaining and the nullish coalescing
+	// operator. Before ES2020, a?.b and a ?? b are lowered to conditionals
+	// that evaluate a twice, so a must not have side effects.
+	TargetES2020 Target = "es2020"
+
+	// TargetESNext keeps all syntax.
+	TargetESNext Target = "esnext"
+)
+
+// The versions that introduced the syntax that is lowered.
+const (
+	es5    = 2009
+	es2015 = 2015
+	es2016 = 2016
+	es2017 = 2017
+	es2018 = 2018
+	es2019 = 2019
+	es2020 = 2020
+	es2021 = 2021
+	esNext = 9999
+)
+
+var targetVersion = regexp.MustCompile(`^es(20[1-9][0-9])$`)
+
+// version returns the year of the version of the target, eg. 2015.
+func (t Target) version() (int, error) {
+	switch t {
+	case TargetES5, "es5":
+		return es5, nil
+	case TargetESNext:
+		return esNext, nil
+	}
+	if m := targetVersion.FindStringSubmatch(string(t)); m != nil {
+		return strconv.Atoi(m[1])
+	}
+	return 0, fmt.Errorf("Unknown target %v, expected es5, es2015 to es2099 or esnext", t)
+}
+
+// supports reports whether the target has the syntax of version.
+func (g *generator) supports(version int) bool {
+	return g.target >= version
+}
+
+// arrowFunction generates f as an arrow function. A single parameter is
+// written without parentheses, and a body that only returns an expression is
+// written as the expression.
+func (g *generator) arrowFunction(f *ast.FunctionLiteral) error {
+	if err := g.async(f); err != nil {
+		return err
+	}
+	if params := f.ParameterList.List; len(params) == 1 && f.ParameterList.Defaults == nil && !f.ParameterList.Rest {
+		g.writeInlineComments(params[0])
+		if err := g.identifier(params[0]); err != nil {
+			return err
+		}
+		g.deferTrailingComments(params[0])
+	} else if err := g.parameterList(f); err != nil {
+		return err
+	}
+	g.write(" => ")
+	if exp := conciseBody(f); exp != nil {
+		prec := precAssign
+		if startsStatementAmbiguously(exp) {
+			// { would start a block
+			prec = precPrimary + 1
+		}
+		return g.subExpression(exp, prec)
+	}
+	return g.generateStatement(f.Body, f.DeclarationList)
+}
+
+// async writes the async of f if it is an async function, which cannot be
+// lowered to targets before ES2017.
+func (g *generator) async(f *ast.FunctionLiteral) error {
+	if !f.Async {
+		return nil
+	}
+	if !g.supports(es2017) {
+		return fmt.Errorf("Cannot lower %v: async functions require target es2017 or later", g.filePath)
+	}
+	g.write("async ")
+	return nil
+}
+
+// exponentiation generates left ** right, which is a call of Math.pow for
+// targets before ES2016.
+func (g *generator) exponentiation(left, right ast.Expression) error {
+	if !g.supports(es2016) {
+		g.write("Math.pow(")
+		if err := g.subExpression(left, precAssign); err != nil {
+			return err
+		}
+		g.write(", ")
+		if err := g.subExpression(right, precAssign); err != nil {
+			return err
+		}
+		g.write(")")
+		return nil
+	}
+	// the base cannot be a unary expression, and ** is right-associative
+	if err := g.subExpression(left, precPostfix); err != nil {
+		return err
+	}
+	g.write(" ** ")
+	return g.subExpression(right, precExponent)
+}
+
+// conciseBody returns the expression of an arrow function like x => x, or
+// nil if its body is a block.
+func conciseBody(f *ast.FunctionLiteral) ast.Expression {
+	block, ok := f.Body.(*ast.BlockStatement)
+	if !ok || len(block.List) != 1 || len(f.DeclarationList) > 0 {
+		return nil
+	}
+	ret, ok := block.List[0].(*ast.ReturnStatement)
+	if !ok || ret.Argument == nil || ret.Return != block.LeftBrace {
+		return nil
+	}
+	return ret.Argument
+}
+
+// hasTemplateSource reports whether the source of the strings of d is
+// known, so it can be written as a template string.
+func hasTemplateSource(d *ast.DynamicStringExpression) bool {
+	return len(d.List) > 0 && len(d.Raw) == (len(d.List)+1)/2
+}
+
+// templateString generates d as a template string from its source.
+func (g *generator) templateString(d *ast.DynamicStringExpression) error {
+	g.writeRaw("`")
+	for i, e := range d.List {
+		if i%2 == 0 {
+			g.writeRaw(d.Raw[i/2])
+			continue
+		}
+		g.writeRaw("${")
+		if err := g.subExpression(e, precLowest); err != nil {
+			return err
+		}
+		g.writeRaw("}")
+	}
+	g.writeRaw("`")
+	return nil
+}
+
+// destructured returns the value that the declarations of v, a
+// destructuring declaration, are properties of, or nil if they were changed
+// and are not a destructuring any more.
+func destructured(v *ast.VariableStatement) ast.Expression {
+	if !v.Destructuring {
+		return nil
+	}
+	var value ast.Expression
+	for _, exp := range v.List {
+		vexp, ok := exp.(*ast.VariableExpression)
+		if !ok {
+			return nil
+		}
+		dot, ok := vexp.Initializer.(*ast.DotExpression)
+		if !ok || value != nil && dot.Left != value {
+			return nil
+		}
+		value = dot.Left
+	}
+	return value
+}
+
+// destructuring generates the declarations of v as a destructuring of
+// value, eg. { a, b: c } = d. Names that differ from their property, like
+// mangled names, are written after the property.
+func (g *generator) destructuring(v *ast.VariableStatement, value ast.Expression) error {
+	g.write("{ ")
+	for i, exp := range v.List {
+		vexp := exp.(*ast.VariableExpression)
+		if i > 0 {
+			g.write(", ")
+		}
+		g.mark(vexp)
+		if property := vexp.Initializer.(*ast.DotExpression).Identifier.Name; property != vexp.Name {
+			g.write(property + ": ")
+		}
+		g.write(vexp.Name)
+	}
+	g.write(" } = ")
+	return g.subExpression(value, precAssign)
+}
+
+// isShorthand reports whether p is written as {a}, which is kept if the
+// value is still the identifier named by the key.
+func (g *generator) isShorthand(p ast.Property) bool {
+	if !p.Shorthand || !g.supports(es2015) {
+		return false
+	}
+	identifier, ok := p.Value.(*ast.Identifier)
+	return ok && identifier.Name == p.Key
+}
+
+// nativePrecedence returns how tightly exp binds when it is written in the
+// syntax of the target, which differs from its lowered form.
+func (g *generator) nativePrecedence(exp ast.Expression) int {
+	switch exp := exp.(type) {
+	case *ast.FunctionLiteral:
+		if exp.Arrow && g.supports(es2015) {
+			return precAssign
+		}
+	case *ast.DynamicStringExpression:
+		if g.supports(es2015) && hasTemplateSource(exp) {
+			return precPrimary
+		}
+	case *ast.ClassLiteral:
+		if !g.supports(es2015) {
+			return precCall
+		}
+	case *ast.BinaryExpression:
+		if exp.Operator == token.EXPONENT && !g.supports(es2016) {
+			return precCall
+		}
+		if exp.Operator == token.NULLISH_COALESCING && !g.supports(es2020) {
+			return precConditional
+		}
+	case *ast.ObjectLiteral:
+		if !g.supports(es2015) && hasComputedKey(exp) {
+			return precCall
+		}
+	case *ast.DotExpression, *ast.BracketExpression, *ast.CallExpression:
+		if _, optional := optionalChain(exp); optional != nil && !g.supports(es2020) {
+			return precConditional
+		}
+	}
+	return precedence(exp)
+}
+
+// lowerParameters adds the assignments of the default and rest parameters
+// of f to the start of its body, eg. if (a === void 0) a = 1;.
+func (g *generator) lowerParameters(f *ast.FunctionLiteral) {
+	pl := f.ParameterList
+	var prologue []ast.Statement
+	for i, p := range pl.List {
+		if pl.Rest && i == len(pl.List)-1 {
+			// var b = Array.prototype.slice.call(arguments, 1);
+			slice := &ast.CallExpression{
+				Callee:       propertyPath("Array", "prototype", "slice", "call"),
+				ArgumentList: []ast.Expression{&ast.Identifier{Name: "arguments"}, newNumber(i)},
+			}
+			prologue = append(prologue, &ast.VariableStatement{
+				Token: token.VAR,
+				List:  []ast.Expression{&ast.VariableExpression{Name: p.Name, Initializer: slice}},
+			})
+			continue
+		}
+		if pl.Defaults == nil || pl.Defaults[i] == nil {
+			continue
+		}
+		prologue = append(prologue, &ast.IfStatement{
+			Test: &ast.BinaryExpression{
+				Operator: token.STRICT_EQUAL,
+				Left:     &ast.Identifier{Name: p.Name},
+				Right:    newUndefined(0),
+			},
+			Consequent: &ast.ExpressionStatement{
+				Expression: &ast.AssignExpression{
+					Operator: token.ASSIGN,
+					Left:     &ast.Identifier{Name: p.Name},
+					Right:    pl.Defaults[i],
+				},
+			},
+		})
+	}
+	g.addPrologue(f.Body, prologue...)
+}
+
+// addPrologue adds list to the statements written at the start of node, a
+// block or the program.
+func (g *generator) addPrologue(node ast.Node, list ...ast.Statement) {
+	if len(list) == 0 {
+		return
+	}
+	if g.prologues == nil {
+		g.prologues = make(map[ast.Node][]ast.Statement)
+	}
+	g.prologues[node] = append(g.prologues[node], list...)
+}
+
+// captureArrowScope finds the this and arguments that arrow functions refer
+// to. Lowered arrow functions read them from variables of the function or
+// program that defines them, eg. var _this = this, as functions have their
+// own.
+func (g *generator) captureArrowScope(p *ast.Program) {
+	m := &mangler{lexical: true, visited: make(map[*ast.FunctionLiteral]bool), captures: make(map[ast.Node]ast.Node)}
+	m.program(p)
+	if len(m.captures) == 0 {
+		return
+	}
+
+	// the names are chosen in a fixed order, so the same input always
+	// gives the same code
+	this := make(map[ast.Node]bool)
+	arguments := make(map[ast.Node]bool)
+	for node, owner := range m.captures {
+		if i, ok := node.(*ast.Identifier); ok && i.Name == "arguments" {
+			arguments[owner] = true
+		} else {
+			this[owner] = true
+		}
+	}
+	var thisName, argumentsName string
+	if len(this) > 0 {
+		thisName = g.freeName("_this")
+	}
+	if len(arguments) > 0 {
+		argumentsName = g.freeName("_arguments")
+	}
+
+	g.captured = make(map[ast.Node]string)
+	for node := range m.captures {
+		if i, ok := node.(*ast.Identifier); ok && i.Name == "arguments" {
+			g.captured[node] = argumentsName
+		} else {
+			g.captured[node] = thisName
+		}
+	}
+	for owner := range this {
+		g.addPrologue(body(owner), capture(thisName, &ast.ThisExpression{}))
+	}
+	for owner := range arguments {
+		g.addPrologue(body(owner), capture(argumentsName, &ast.Identifier{Name: "arguments"}))
+	}
+}
+
+// body returns the node whose prologue is written at the start of owner, a
+// function or the program.
+func body(owner ast.Node) ast.Node {
+	if fn, ok := owner.(*ast.FunctionLiteral); ok {
+		return fn.Body
+	}
+	return owner
+}
+
+// capture returns the declaration var name = value.
+func capture(name string, value ast.Expression) ast.Statement {
+	return &ast.VariableStatement{
+		Token: token.VAR,
+		List:  []ast.Expression{&ast.VariableExpression{Name: name, Initializer: value}},
+	}
+}
+
+// forOfStatement generates f. Before ES2015, it is a loop over the indices
+// of an array of the values of the source. Sources other than array
+// literals are read with Array.from, which reads iterables like Set through
+// Symbol.iterator, and is polyfilled:
+//
+//	for (var _i = 0, _a = Array.from(b); _i < _a.length; _i++) { var a = _a[_i]; }
+func (g *generator) forOfStatement(f *ast.ForOfStatement) error {
+	if g.supports(es2015) {
+		g.writeLine("for (")
+		if f.Token != 0 {
+			g.write(f.Token.String() + " ")
+		}
+		if err := g.generateExpression(f.Into); err != nil {
+			return err
+		}
+		g.write(" of ")
+		if err := g.subExpression(f.Source, precAssign); err != nil {
+			return err
+		}
+		g.write(") ")
+		return g.bodyStatement(f.Body)
+	}
+
+	index, array := g.freeName("_i"), g.freeName("_a")
+	source := f.Source
+	if _, ok := source.(*ast.ArrayLiteral); !ok {
+		g.usesBuiltin("Array.from")
+		source = &ast.CallExpression{Callee: propertyPath("Array", "from"), ArgumentList: []ast.Expression{source}}
+	}
+	element := &ast.BracketExpression{Left: &ast.Identifier{Name: array}, Member: &ast.Identifier{Name: index}}
+	var assign ast.Statement
+	if v, ok := f.Into.(*ast.VariableExpression); ok {
+		assign = &ast.VariableStatement{
+			Token: token.VAR,
+			List:  []ast.Expression{&ast.VariableExpression{Name: v.Name, Initializer: element}},
+		}
+	} else {
+		assign = &ast.ExpressionStatement{
+			Expression: &ast.AssignExpression{Operator: token.ASSIGN, Left: f.Into, Right: element},
+		}
+	}
+	body, ok := f.Body.(*ast.BlockStatement)
+	if ok {
+		g.addPrologue(body, assign)
+	} else {
+		body = &ast.BlockStatement{List: []ast.Statement{assign, f.Body}}
+	}
+
+	return g.forStatement(&ast.ForStatement{
+		Initializer: &ast.SequenceExpression{Sequence: []ast.Expression{
+			&ast.VariableExpression{Name: index, Initializer: newNumber(0)},
+			&ast.VariableExpression{Name: array, Initializer: source},
+		}},
+		Test: &ast.BinaryExpression{
+			Operator: token.LESS,
+			Left:     &ast.Identifier{Name: index},
+			Right:    propertyPath(array, "length"),
+		},
+		Update: &ast.UnaryExpression{Operator: token.INCREMENT, Postfix: true, Operand: &ast.Identifier{Name: index}},
+		Body:   body,
+	})
+}
+
+// freeName returns a name for a variable added by lowering, eg. _i, that
+// no variable of the module uses, so it neither shadows nor is shadowed by
+// them. Later calls return other names, eg. _i2.
+func (g *generator) freeName(name string) string {
+	free := name
+	for i := 2; g.names[free]; i++ {
+		free = name + strconv.Itoa(i)
+	}
+	g.names[free] = true
+	return free
+}
+
+// hasComputedKey reports whether a property of o has a computed key.
+func hasComputedKey(o *ast.ObjectLiteral) bool {
+	for _, p := range o.Value {
+		if p.Computed != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// definedProperties generates o, an object literal with computed keys,
+// before ES2015. The properties from the first computed key on are defined
+// one by one, in order, on the object of the properties before it:
+//
+//	Object.defineProperty({ a: 1 }, b, { value: 2, ... })
+func (g *generator) definedProperties(o *ast.ObjectLiteral) error {
+	first := 0
+	for o.Value[first].Computed == nil {
+		first++
+	}
+	var object ast.Expression = &ast.ObjectLiteral{Value: o.Value[:first]}
+	for _, p := range o.Value[first:] {
+		if p.Kind == "spread" {
+			g.usesBuiltin("Object.assign")
+			object = &ast.CallExpression{
+				Callee:       propertyPath("Object", "assign"),
+				ArgumentList: []ast.Expression{object, p.Value},
+			}
+			continue
+		}
+		key := p.Computed
+		if key == nil {
+			key = newString(p.Key, 0)
+		}
+		descriptor := &ast.ObjectLiteral{}
+		attribute := func(name string, value ast.Expression) {
+			descriptor.Value = append(descriptor.Value, ast.Property{Key: name, Kind: "value", Value: value})
+		}
+		if p.Kind == "get" || p.Kind == "set" {
+			attribute(p.Kind, p.Value)
+		} else {
+			attribute("value", p.Value)
+			attribute("writable", newBoolean(true, 0))
+		}
+		attribute("enumerable", newBoolean(true, 0))
+		attribute("configurable", newBoolean(true, 0))
+		object = &ast.CallExpression{
+			Callee:       propertyPath("Object", "defineProperty"),
+			ArgumentList: []ast.Expression{object, key, descriptor},
+		}
+	}
+	return g.expression(object)
+}
+
+// optionalChain returns the object of the first optional link of the chain
+// of member expressions and calls exp, eg. a for a?.b.c, and the flag
+// marking the link as optional. optional is nil if no link is optional.
+func optionalChain(exp ast.Expression) (object ast.Expression, optional *bool) {
+	for {
+		switch e := exp.(type) {
+		case *ast.DotExpression:
+			if e.Optional {
+				object, optional = e.Left, &e.Optional
+			}
+			exp = e.Left
+		case *ast.BracketExpression:
+			if e.Optional {
+				object, optional = e.Left, &e.Optional
+			}
+			exp = e.Left
+		case *ast.CallExpression:
+			if e.Optional {
+				object, optional = e.Callee, &e.Optional
+			}
+			exp = e.Callee
+		default:
+			return object, optional
+		}
+	}
+}
+
+// lowerOptionalChain generates exp, a chain whose first optional link is
+// on object, as a conditional before ES2020, eg. a == null ? void 0 : a.b.c
+// for a?.b.c. The rest of the chain is generated the same way.
+func (g *generator) lowerOptionalChain(exp, object ast.Expression, optional *bool) error {
+	if !repeatable(object) {
+		return fmt.Errorf("Cannot lower %v: optional chaining on an expression with side effects requires target es2020 or later", g.filePath)
+	}
+	if err := g.subExpression(object, precEquality); err != nil {
+		return err
+	}
+	g.write(" == null ? void")
+	g.write(" 0 : ")
+
+	*optional = false
+	defer func() {
+		*optional = true
+	}()
+	return g.expression(exp)
+}
+
+// nullishCoalescing generates a ?? b as a != null ? a : b before ES2020.
+func (g *generator) nullishCoalescing(b *ast.BinaryExpression) error {
+	if !repeatable(b.Left) {
+		return fmt.Errorf("Cannot lower %v: ?? on an expression with side effects requires target es2020 or later", g.filePath)
+	}
+	if err := g.subExpression(b.Left, precEquality); err != nil {
+		return err
+	}
+	g.write(" != null ? ")
+	if err := g.subExpression(b.Left, precAssign); err != nil {
+		return err
+	}
+	g.write(" : ")
+	return g.subExpression(b.Right, precAssign)
+}
+
+// repeatable reports whether exp can be evaluated twice, as it has no side
+// effects other than calling getters and conversions.
+func repeatable(exp ast.Expression) bool {
+	switch exp := exp.(type) {
+	case *ast.Identifier, *ast.ThisExpression, *ast.SuperExpression:
+		return true
+	case *ast.DotExpression:
+		return repeatable(exp.Left)
+	case *ast.BracketExpression:
+		return repeatable(exp.Left) && repeatable(exp.Member)
+	case *ast.BinaryExpression:
+		return repeatable(exp.Left) && repeatable(exp.Right)
+	case *ast.ConditionalExpression:
+		return repeatable(exp.Test) && repeatable(exp.Consequent) && repeatable(exp.Alternate)
+	case *ast.UnaryExpression:
+		switch exp.Operator {
+		case token.INCREMENT, token.DECREMENT, token.DELETE:
+			return false
+		}
+		return repeatable(exp.Operand)
+	}
+	return isPrimitive(exp)
+}
+
+// mixesNullish reports whether operand is a || or && operand of ??, or the
+// reverse, which must be parenthesized.
+func mixesNullish(operator token.Token, operand ast.Expression) bool {
+	b, ok := operand.(*ast.BinaryExpression)
+	if !ok {
+		return false
+	}
+	logical := func(t token.Token) bool {
+		return t == token.LOGICAL_AND || t == token.LOGICAL_OR
+	}
+	nullish := token.NULLISH_COALESCING
+	return operator == nullish && logical(b.Operator) || logical(operator) && b.Operator == nullish
+}
+
+// propertyPath returns the expression reading the properties names[1:] of
+// the variable names[0], eg. Array.prototype.slice.
+func propertyPath(names ...string) ast.Expression {
+	var exp ast.Expression = &ast.Identifier{Name: names[0]}
+	for _, name := range names[1:] {
+		exp = &ast.DotExpression{Left: exp, Identifier: &ast.Identifier{Name: name}}
+	}
+	return exp
+}
+
+func newNumber(n int) *ast.NumberLiteral {
+	return &ast.NumberLiteral{Literal: strconv.Itoa(n), Value: int64(n)}
+}
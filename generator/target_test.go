@@ -0,0 +1,119 @@
+package generator
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadTarget(t *testing.T) {
+	load := func(src string, options Options) string {
+		out, err := LoadWithOptions(strings.NewReader(src), options)
+		assert.NoError(t, err)
+		code, err := ioutil.ReadAll(out)
+		assert.NoError(t, err)
+		return string(code)
+	}
+
+	src := "const add = (a, b) => a + b;\nlet f = () => ({ a: 1 });\nvar g = (x => x)(1);\nvar s = `it's ${add(1, 2)}\n`;\nvar o = { ...base, b: 2 };"
+
+	// ES5 is the default
+	es5 := "\nvar add = function (a, b) {\n  return a + b;\n};\nvar f = function () {\n  return {\n    a: 1\n  };\n};\n" +
+		"var g = function (x) {\n  return x;\n}(1);\nvar s = 'it\\'s ' + add(1, 2) + '\\n';\nvar o = Object.assign({}, base, { b: 2 });"
+	assert.Equal(t, es5, load(src, Options{}))
+	assert.Equal(t, es5, load(src, Options{Target: "es5"}))
+
+	es2015 := "\nconst add = (a, b) => a + b;\nlet f = () => ({\n  a: 1\n});\nvar g = (x => x)(1);\nvar s = `it's ${add(1, 2)}\n`;\n"
+	assert.Equal(t, es2015+"var o = Object.assign({}, base, { b: 2 });", load(src, Options{Target: TargetES2015}))
+	assert.Equal(t, es2015+"var o = {\n  ...base,\n  b: 2\n};", load(src, Options{Target: TargetESNext}))
+	assert.Equal(t,
+		"const add=(a,b)=>a+b;let f=()=>({a:1});var g=(x=>x)(1);var s=`it's ${add(1,2)}\n`;var o={...base,b:2}",
+		load(src, Options{Target: TargetESNext, Minify: true}))
+
+	// trailing commas in argument lists are only valid from ES2017
+	src = "f(first, second);"
+	print := PrintOptions{LineWidth: 10, TrailingCommas: true}
+	assert.Equal(t, "f(\n  first,\n  second\n);", load(src, Options{Target: TargetES2015, Print: print}))
+	assert.Equal(t, "f(\n  first,\n  second,\n);", load(src, Options{Target: TargetES2017, Print: print}))
+
+	// classes are lowered to constructor functions before ES2015
+	src = "class B extends A {\n  constructor(x) { super(x); }\n  get x() { return super.x; }\n  static m() {}\n}"
+	assert.Equal(t,
+		"\nvar B = function (_super) {\n  function B(x) {\n    _super.call(this, x);\n  }\n  B.prototype = Object.create(_super.prototype);\n"+
+			"  B.prototype.constructor = B;\n  B.__proto__ = _super;\n  Object.defineProperty(B.prototype, \"x\", {\n    get: function () {\n"+
+			"      return _super.prototype.x;\n    },\n    configurable: true\n  });\n  B.m = function () {\n  };\n  return B;\n}(A);",
+		load(src, Options{Target: TargetES5}))
+	assert.Equal(t,
+		"\nclass B extends A {\n  constructor(x) {\n    super(x);\n  }\n  get x() {\n    return super.x;\n  }\n  static m() {\n  }\n}",
+		load(src, Options{Target: TargetES2015}))
+
+	// the superclass of a lowered class does not shadow the variables of
+	// the module
+	src = "var _super = 1;\nvar C = class extends A {\n  m() { return _super; }\n};"
+	assert.Equal(t,
+		"\nvar _super = 1;\nvar C = function (_super2) {\n  function _class() {\n    _super2.apply(this, arguments);\n  }\n"+
+			"  _class.prototype = Object.create(_super2.prototype);\n  _class.prototype.constructor = _class;\n  _class.__proto__ = _super2;\n"+
+			"  _class.prototype.m = function () {\n    return _super;\n  };\n  return _class;\n}(A);",
+		load(src, Options{Target: TargetES5}))
+
+	// lowered arrow functions read the this and arguments of the function
+	// that defines them, and super calls use its this as the receiver
+	src = "var _this = 1;\nvar o = { m: function () { return () => this.v + arguments[0]; } };\n" +
+		"class B extends A { m() { return () => super.m(); } }"
+	assert.Equal(t,
+		"\nvar _this = 1;\nvar o = {\n  m: function () {\n    var _this2 = this;\n    var _arguments = arguments;\n"+
+			"    return function () {\n      return _this2.v + _arguments[0];\n    };\n  }\n};\n"+
+			"var B = function (_super) {\n  function B() {\n    _super.apply(this, arguments);\n  }\n"+
+			"  B.prototype = Object.create(_super.prototype);\n  B.prototype.constructor = B;\n  B.__proto__ = _super;\n"+
+			"  B.prototype.m = function () {\n    var _this2 = this;\n    return function () {\n"+
+			"      return _super.prototype.m.call(_this2);\n    };\n  };\n  return B;\n}(A);",
+		load(src, Options{Target: TargetES5}))
+
+	// the exponentiation operator is lowered to Math.pow before ES2016
+	src = "var a = 2 ** 3 ** 2; a **= 2;"
+	assert.Equal(t, "\nvar a = Math.pow(2, Math.pow(3, 2));\na = Math.pow(a, 2);", load(src, Options{Target: TargetES2015}))
+	assert.Equal(t, "\nvar a = 2 ** 3 ** 2;\na **= 2;", load(src, Options{Target: TargetES2016}))
+
+	// async functions can't be lowered
+	src = "async function f() { await g(); }"
+	_, err := LoadWithOptions(strings.NewReader(src), Options{Target: TargetES2016})
+	assert.EqualError(t, err, "Cannot lower <input>: async functions require target es2017 or later")
+	assert.Equal(t, "async function f() {\n  await g();\n}", load(src, Options{Target: TargetES2017}))
+
+	// default and rest parameters, for-of and computed keys are lowered
+	// before ES2015
+	src = "function f(a = 1, ...b) {}\nfor (const c of d) g(c);\nvar e = { a: 1, [k]: 2 };"
+	assert.Equal(t,
+		"function f(a) {\n  if (a === void 0) \n  a = 1;\n  var b = Array.prototype.slice.call(arguments, 1);\n}\n"+
+			"for (var _i = 0, _a = Array.from(d); _i < _a.length; _i++) {\n  var c = _a[_i];\n  g(c);\n}\n"+
+			"var e = Object.defineProperty({\n  a: 1\n}, k, {\n  value: 2,\n  writable: true,\n  enumerable: true,\n  configurable: true\n});",
+		load(src, Options{Target: TargetES5}))
+	assert.Equal(t,
+		"function f(a = 1, ...b) {\n}\nfor (const c of d) \ng(c);\nvar e = {\n  a: 1,\n  [k]: 2\n};",
+		load(src, Options{Target: TargetES2015}))
+
+	// the variables of lowered for-of loops and catch clauses do not use
+	// the names of the module
+	src = "var _a = [9], _e;\nfor (var x of [1, 2]) _a.push(x);\ntry {} catch { _e = 1; }"
+	assert.Equal(t,
+		"\nvar _a = [9],\n_e;\nfor (var _i = 0, _a2 = [1, 2]; _i < _a2.length; _i++) {\n  var x = _a2[_i];\n  _a.push(x);\n}\n"+
+			"try {\n} catch (_e2) {\n  _e = 1;\n}",
+		load(src, Options{Target: TargetES5}))
+
+	// catch without a binding is given one before ES2019
+	src = "try { f(); } catch { g(); }"
+	assert.Equal(t, "\ntry {\n  f();\n} catch (_e) {\n  g();\n}", load(src, Options{Target: TargetES2018}))
+	assert.Equal(t, "\ntry {\n  f();\n} catch {\n  g();\n}", load(src, Options{Target: TargetES2019}))
+
+	// optional chaining and ?? are lowered to conditionals before ES2020
+	src = "var a = b?.c.d(), e = f ?? g;"
+	assert.Equal(t, "\nvar a = b == null ? void 0 : b.c.d(),\ne = f != null ? f : g;", load(src, Options{Target: TargetES2019}))
+	assert.Equal(t, "\nvar a = b?.c.d(),\ne = f ?? g;", load(src, Options{Target: TargetES2020}))
+	_, err = LoadWithOptions(strings.NewReader("var a = b()?.c;"), Options{Target: TargetES2019})
+	assert.EqualError(t, err, "Cannot lower <input>: optional chaining on an expression with side effects requires target es2020 or later")
+
+	_, err = LoadWithOptions(strings.NewReader(src), Options{Target: "es7"})
+	assert.EqualError(t, err, "Unknown target es7, expected es5, es2015 to es2099 or esnext")
+}
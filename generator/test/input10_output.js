@@ -1,5 +1,5 @@
 var i = [1, 2, 3];
-for (j = 0; (j < i.length); j++) {
+for (var j = 0; j < i.length; j++) {
   console.log(j);
 }
 for (var j in i) {
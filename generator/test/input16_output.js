@@ -1,8 +1,8 @@
 var CommentBox = React.createClass({
-  render: (function () {
+  render: function () {
     return React.createElement("div", {
       className: "commentBox"
     }, "Hello, world! I am a CommentBox.");
-  })
+  }
 });
 React.render(React.createElement(CommentBox, null), document.getElementById('content'));
@@ -1,9 +1,9 @@
 module.exports = k;
 exports.default = i;
 exports.j = 'test';
-exports.fn = (function () {
+exports.fn = function () {
   return console.log('arrow fn');
-});
-exports.fn2 = (function (a, b) {
-  return (a + b);
-});
\ No newline at end of file
+};
+exports.fn2 = function (a, b) {
+  return a + b;
+};
\ No newline at end of file
@@ -1,7 +1,7 @@
 var i = Object.assign({}, { heading: 'test', other: 123 }, children, { number: 2 });
 var copy = Object.assign({}, i);
 fn({
-  test: (function () {
+  test: function () {
     return 123;
-  })
+  }
 });
\ No newline at end of file
@@ -1,4 +1,4 @@
 function fn2() {
 }
-var fn1 = (function () {
-});
\ No newline at end of file
+var fn1 = function () {
+};
\ No newline at end of file
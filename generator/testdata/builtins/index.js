@@ -0,0 +1,16 @@
+var path = require('path');
+var EventEmitter = require('events');
+var util = require('node:util');
+var Buffer = require('buffer').Buffer;
+var assert = require('assert');
+var fs = require('fs');
+var url = require('url');
+
+var emitter = new EventEmitter();
+emitter.once('done', function (value) {
+  console.log(util.format('%s %d', path.join('/a/b', '../c', 'd.js'), value));
+});
+emitter.emit('done', 42);
+
+assert.deepEqual(Buffer.from('héllo').toString('base64'), 'aMOpbGxv');
+console.log(typeof fs.readFile, url.name);
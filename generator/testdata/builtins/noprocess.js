@@ -0,0 +1 @@
+module.exports = require('path').basename('/a/b.js');
@@ -0,0 +1,3 @@
+process.nextTick(function () {
+  console.log(process.browser, process.env.NODE_ENV);
+});
@@ -0,0 +1 @@
+exports.readFile = function () {};
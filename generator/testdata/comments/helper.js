@@ -0,0 +1,6 @@
+/**
+ * @license Apache-2.0
+ */
+module.exports = function () {
+  // nothing to do
+};
@@ -0,0 +1,6 @@
+/*! index v1.0.0 | MIT */
+// helpers
+var helper = require('./helper');
+
+helper(); // call it
+//# sourceMappingURL=index.js.map
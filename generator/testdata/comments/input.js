@@ -0,0 +1,27 @@
+/*! license text */
+// leading of a
+var a = 1; // trailing a
+/** doc for f */
+function f(x /* param */) {
+  // inside f
+  return x; // after return
+  // final in f
+}
+if (a) { // after brace
+  b(); /* after b */
+}
+var b = function () {};
+var o = {
+  // key comment
+  k: /* colon */ 1,
+  // final obj
+};
+if (a) { b(); } // after if
+else { b(); }
+function g() {
+  return ( // why
+    a
+  );
+}
+/* @license MIT */
+// end of file
@@ -0,0 +1,13 @@
+function repeat(s, n) {
+  var result = '';
+  for (var i = 0; i < n; i++) {
+    result += s;
+  }
+  return result;
+}
+
+exports.pad = function (value) {
+  debugger;
+  return repeat(' ', 2) + value;
+  return value;
+};
@@ -0,0 +1,14 @@
+var helpers = require('./helpers');
+
+var unused = { name: 'unused' };
+
+function format(value) {
+  console.log('formatting', value);
+  return helpers.pad(value);
+}
+
+function legacy() {
+  return format('legacy');
+}
+
+module.exports = format;
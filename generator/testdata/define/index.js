@@ -0,0 +1,7 @@
+var log = require('./log');
+
+if (process.env.NODE_ENV !== 'production') {
+  log('development build');
+}
+
+module.exports = __DEV__ ? 'dev' : 'prod';
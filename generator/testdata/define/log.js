@@ -0,0 +1,3 @@
+module.exports = function (message) {
+  console.log(message);
+};
@@ -0,0 +1,4 @@
+exports.greet = function (name) {
+    throw new Error('hello ' + name);
+};
+//# sourceMappingURL=greet.js.map
@@ -0,0 +1,3 @@
+export function greet(name: string): string {
+  throw new Error('hello ' + name);
+}
@@ -0,0 +1,3 @@
+var greet = require('./greet').greet;
+
+greet('world');
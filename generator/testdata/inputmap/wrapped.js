@@ -0,0 +1,3 @@
+exports.fail = function () {
+  throw new Error('wrapped');
+};
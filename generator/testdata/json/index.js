@@ -0,0 +1,7 @@
+import data from './data.json';
+import { name, nested as n } from './data.json';
+import * as all from './data.json';
+
+var list = require('./list.json');
+
+console.log(data.default, name, n.list, all.default === data, list.length);
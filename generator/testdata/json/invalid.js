@@ -0,0 +1 @@
+import invalid from './invalid.json';
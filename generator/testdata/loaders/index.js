@@ -0,0 +1,4 @@
+var a = require('./a.txt?upper');
+var b = require('./b.txt');
+
+console.log(a, b);
@@ -0,0 +1,11 @@
+function Counter(start) {
+  this._count = start;
+}
+
+Counter.prototype.increment = function (step) {
+  var next = this._count + step;
+  this._count = next;
+  return this;
+};
+
+module.exports = Counter;
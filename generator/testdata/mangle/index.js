@@ -0,0 +1,11 @@
+var Counter = require('./counter');
+
+function count(times) {
+  var counter = new Counter(0);
+  for (var index = 0; index < times; index++) {
+    counter.increment(1);
+  }
+  return counter['_count'];
+}
+
+module.exports = count(3);
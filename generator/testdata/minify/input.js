@@ -0,0 +1,40 @@
+var a = 1, b = 2;
+var s = a - (b - 3) + (a * (b + 1)) / 2;
+var t = !(a && b) || typeof (a + b) === 'number';
+var u = a ? b : (a ? 1 : 2), v = (a ? b : a) ? 1 : 2;
+var w = (a = 4) + a - -b + +a;
+var o = { k: 1, 'x-y': [a, b], f: function (x) { return x * 2; } };
+var n = new (o.f(1).constructor)();
+
+(function () {
+  a++;
+})();
+
+function loop(list) {
+  var total = 0;
+  outer: for (var i = 0; i < list.length; i++) {
+    if (list[i] < 0) continue outer;
+    else if (list[i] > 10) {
+      break;
+    }
+    total += list[i];
+  }
+  while (total > 100);
+  do {
+    total--;
+  } while (total > 50);
+  switch (total) {
+    case 1:
+      return 'one';
+    default:
+      return total;
+  }
+}
+
+try {
+  throw new Error("it's");
+} catch (e) {
+  loop([a, b]);
+} finally {
+  o.k = /a b/.test('a b');
+}
@@ -0,0 +1,4 @@
+var routes = require('virtual:routes');
+var page = require('./page.js');
+
+console.log(routes, page);
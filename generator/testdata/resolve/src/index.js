@@ -0,0 +1 @@
+import exp from 'exp';
@@ -0,0 +1,5 @@
+var icon = require('./icon.svg?raw');
+var a = require('./a.module.css');
+var b = require('./b.css');
+var pkg = require('pkg');
+var local = require('./local.js');
@@ -0,0 +1,7 @@
+// helpers used by index.js
+exports.compute = function (n) {
+  if (n > 1) {
+    throw new Error('boom at helper line 4');
+  }
+  return n;
+};
@@ -0,0 +1,8 @@
+var helper = require('./helper');
+
+function main() {
+  var value = helper.compute(2);
+  return value;
+}
+
+main();
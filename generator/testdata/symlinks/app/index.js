@@ -0,0 +1 @@
+import lib from 'lib';
@@ -0,0 +1 @@
+export var format = 1;
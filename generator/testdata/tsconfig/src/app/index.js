@@ -0,0 +1 @@
+import Button from 'components/Button';
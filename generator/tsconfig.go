@@ -0,0 +1,316 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// tsconfig holds the module resolution options of a tsconfig.json or
+// jsconfig.json file.
+type tsconfig struct {
+	// baseURL is the absolute baseUrl, or empty if it is not set
+	baseURL string
+	// paths maps import patterns to lists of target patterns, in the order
+	// they are declared
+	paths tsconfigPaths
+	// pathsBase is the directory the paths targets are relative to
+	pathsBase string
+}
+
+type tsconfigJSON struct {
+	Extends         tsconfigExtends `json:"extends"`
+	CompilerOptions struct {
+		BaseURL *string        `json:"baseUrl"`
+		Paths   *tsconfigPaths `json:"paths"`
+	} `json:"compilerOptions"`
+}
+
+// tsconfigExtends is the "extends" option, a config file or a list of them.
+// The options of later files override those of earlier ones.
+type tsconfigExtends []string
+
+func (e *tsconfigExtends) UnmarshalJSON(data []byte) error {
+	var extends string
+	if err := json.Unmarshal(data, &extends); err == nil {
+		*e = tsconfigExtends{extends}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("\"extends\" is not a string or an array of strings")
+	}
+	*e = list
+	return nil
+}
+
+// tsconfigPath maps an import pattern, eg. "@app/*", to target patterns.
+type tsconfigPath struct {
+	pattern string
+	targets []string
+}
+
+// tsconfigPaths is the "paths" option. It keeps the order of the patterns,
+// which decides between patterns that match equally well.
+type tsconfigPaths []tsconfigPath
+
+func (p *tsconfigPaths) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return fmt.Errorf("\"paths\" is not an object")
+	}
+	*p = tsconfigPaths{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		path := tsconfigPath{pattern: tok.(string)}
+		if err := dec.Decode(&path.targets); err != nil {
+			return err
+		}
+		*p = append(*p, path)
+	}
+	return nil
+}
+
+// readTSConfig parses the config file at path, following "extends".
+func (bundle *_bundle) readTSConfig(path string, depth int) (*tsconfig, error) {
+	if depth > 10 {
+		return nil, fmt.Errorf("Too many levels of \"extends\" in %v", path)
+	}
+
+	data, err := bundle.fs.readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw tsconfigJSON
+	if err := json.Unmarshal(stripJSONComments(data), &raw); err != nil {
+		return nil, fmt.Errorf("Invalid config file %v: %v", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	config := &tsconfig{}
+	for _, name := range raw.Extends {
+		extends, ok := bundle.extendedTSConfig(name, dir)
+		if !ok {
+			return nil, fmt.Errorf("Cannot find config file %v extended by %v", name, path)
+		}
+		extended, err := bundle.readTSConfig(extends, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		if extended.baseURL != "" {
+			config.baseURL = extended.baseURL
+		}
+		if extended.paths != nil {
+			config.paths, config.pathsBase = extended.paths, extended.pathsBase
+		}
+	}
+
+	if raw.CompilerOptions.BaseURL != nil {
+		config.baseURL = filepath.Join(dir, filepath.FromSlash(*raw.CompilerOptions.BaseURL))
+	}
+	if raw.CompilerOptions.Paths != nil {
+		config.paths = *raw.CompilerOptions.Paths
+		config.pathsBase = dir
+	}
+	if config.baseURL != "" {
+		config.pathsBase = config.baseURL
+	}
+	return config, nil
+}
+
+// extendedTSConfig returns the path of the config file that a config file
+// in dir extends. Paths that are not relative name a file in a package, or
+// the tsconfig.json of a package, in node_modules.
+func (bundle *_bundle) extendedTSConfig(extends, dir string) (string, bool) {
+	configFile := func(path string) (string, bool) {
+		if bundle.isFile(path) {
+			return path, true
+		}
+		if bundle.isFile(path + ".json") {
+			return path + ".json", true
+		}
+		return "", false
+	}
+
+	if filepath.IsAbs(extends) {
+		return configFile(extends)
+	}
+	if strings.HasPrefix(extends, ".") {
+		return configFile(filepath.Join(dir, filepath.FromSlash(extends)))
+	}
+	name, subpath := splitPackageName(extends)
+	if subpath == "." {
+		subpath = "tsconfig.json"
+	}
+	for _, moduleDir := range bundle.moduleDirectories(dir) {
+		if path, ok := configFile(filepath.Join(moduleDir, name, filepath.FromSlash(subpath))); ok {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// tsconfig returns the nearest config file for a module in dir, or nil if
+// there is none. Modules in node_modules never use a config file.
+func (bundle *_bundle) tsconfig(dir string) (*tsconfig, error) {
+	if containsString(strings.Split(filepath.ToSlash(dir), "/"), "node_modules") {
+		return nil, nil
+	}
+
+	var searched []string
+	var config *tsconfig
+	for {
+		if cached, ok := bundle.tsconfigs[dir]; ok {
+			config = cached
+			break
+		}
+		searched = append(searched, dir)
+
+		found := false
+		for _, name := range bundle.config.Resolve.TSConfigFiles {
+			path := filepath.Join(dir, name)
+			if !bundle.isFile(path) {
+				continue
+			}
+			found = true
+			var err error
+			if config, err = bundle.readTSConfig(path, 0); err != nil {
+				return nil, err
+			}
+			break
+		}
+
+		parent := filepath.Dir(dir)
+		if found || parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	for _, dir := range searched {
+		bundle.tsconfigs[dir] = config
+	}
+	return config, nil
+}
+
+// resolveTSConfigPaths resolves a bare import using the "paths" and
+// "baseUrl" options of the config file nearest to currentPath.
+func (bundle *_bundle) resolveTSConfigPaths(importValue, currentPath string) (string, bool, error) {
+	config, err := bundle.tsconfig(filepath.Dir(currentPath))
+	if config == nil || err != nil {
+		return "", false, err
+	}
+
+	targets, match := config.paths.match(importValue)
+
+	for _, target := range targets {
+		target = strings.Replace(target, "*", match, -1)
+		path := filepath.Join(config.pathsBase, filepath.FromSlash(target))
+		if resolved, ok := bundle.resolveFileOrDirectory(path); ok {
+			return resolved, true, nil
+		}
+	}
+
+	if config.baseURL != "" {
+		path := filepath.Join(config.baseURL, filepath.FromSlash(importValue))
+		resolved, ok := bundle.resolveFileOrDirectory(path)
+		return resolved, ok, nil
+	}
+	return "", false, nil
+}
+
+// match returns the targets of the pattern that matches importValue, and
+// the part of importValue matched by its "*". An exact match is preferred,
+// then the pattern with the longest prefix, then the first one declared.
+func (p tsconfigPaths) match(importValue string) ([]string, string) {
+	for _, path := range p {
+		if path.pattern == importValue {
+			return path.targets, ""
+		}
+	}
+
+	var targets []string
+	match := ""
+	longestPrefix := -1
+	for _, path := range p {
+		star := strings.Index(path.pattern, "*")
+		if star == -1 {
+			continue
+		}
+		prefix, suffix := path.pattern[:star], path.pattern[star+1:]
+		if len(importValue) >= len(prefix)+len(suffix) &&
+			strings.HasPrefix(importValue, prefix) && strings.HasSuffix(importValue, suffix) &&
+			len(prefix) > longestPrefix {
+			longestPrefix = len(prefix)
+			targets = path.targets
+			match = importValue[len(prefix) : len(importValue)-len(suffix)]
+		}
+	}
+	return targets, match
+}
+
+// stripJSONComments removes the comments and trailing commas allowed in
+// tsconfig.json files.
+func stripJSONComments(data []byte) []byte {
+	stripped := &bytes.Buffer{}
+	scanJSONStrings(data, func(i int) int {
+		switch {
+		case data[i] == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			return i
+		case data[i] == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := bytes.Index(data[i+2:], []byte("*/"))
+			if end == -1 {
+				return len(data)
+			}
+			stripped.WriteByte(' ')
+			return i + 2 + end + 2
+		}
+		stripped.WriteByte(data[i])
+		return i + 1
+	}, stripped)
+
+	// drop commas followed by the end of an object or array
+	withoutComments := stripped.Bytes()
+	out := &bytes.Buffer{}
+	scanJSONStrings(withoutComments, func(i int) int {
+		if withoutComments[i] == ',' {
+			next := bytes.TrimLeft(withoutComments[i+1:], " \t\r\n")
+			if len(next) == 0 || next[0] == '}' || next[0] == ']' {
+				return i + 1
+			}
+		}
+		out.WriteByte(withoutComments[i])
+		return i + 1
+	}, out)
+	return out.Bytes()
+}
+
+// scanJSONStrings copies string literals in data to out and calls fn with
+// the index of every other byte. fn returns the index to continue from.
+func scanJSONStrings(data []byte, fn func(i int) int, out *bytes.Buffer) {
+	for i := 0; i < len(data); {
+		if data[i] != '"' {
+			i = fn(i)
+			continue
+		}
+		start := i
+		for i++; i < len(data) && data[i] != '"'; i++ {
+			if data[i] == '\\' {
+				i++
+			}
+		}
+		if i < len(data) {
+			i++
+		}
+		out.Write(data[start:i])
+	}
+}
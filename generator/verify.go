@@ -0,0 +1,120 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/walesey/go-bundle/file"
+	"github.com/walesey/go-bundle/parser"
+)
+
+// moduleSpan is the part of a bundle that holds the code of the module at
+// path, from offset start to end.
+type moduleSpan struct {
+	path       string
+	mod        *module
+	start, end int
+}
+
+// versionMode returns the parser mode that reports the syntax newer than
+// version, or 0 if all syntax is allowed.
+func versionMode(version int) parser.Mode {
+	switch {
+	case version < es2015:
+		return parser.ES5
+	case version < es2016:
+		return parser.ES2015
+	case version < es2017:
+		return parser.ES2016
+	case version < es2018:
+		return parser.ES2017
+	case version < es2019:
+		return parser.ES2018
+	case version < es2020:
+		return parser.ES2019
+	case version < es2021:
+		return parser.ES2020
+	}
+	return 0
+}
+
+// verifyTarget reparses code, the bundle, and returns an error listing the
+// syntax in it that is newer than the target, each with the module and
+// position it came from.
+func (bundle *_bundle) verifyTarget(code []byte, spans []moduleSpan) error {
+	version, err := bundle.config.Target.version()
+	if err != nil {
+		return err
+	}
+	mode := versionMode(version)
+	if mode == 0 {
+		return nil
+	}
+	_, err = parser.ParseFile(nil, "", code, mode)
+	list, ok := err.(parser.ErrorList)
+	if !ok {
+		return err
+	}
+
+	lines := newSourceLines(file.NewFile("", string(code), 0))
+	var syntax []string
+	for _, e := range list {
+		syntax = append(syntax, fmt.Sprintf("%v: %v", bundle.location(lines, e.Position, spans), e.Message))
+	}
+
+	target := bundle.config.Target
+	if target == TargetES5 {
+		target = "es5"
+	}
+	return fmt.Errorf("Bundle has syntax newer than %v:\n  %v", target, strings.Join(syntax, "\n  "))
+}
+
+// location returns the module, line and column that the code at position of
+// the bundle came from. Positions in the runtime of the bundle, or outside
+// of it, like the end of the code, are given in the bundle.
+func (bundle *_bundle) location(lines *sourceLines, position file.Position, spans []moduleSpan) string {
+	if line := position.Line; line > 0 && line <= len(lines.lineStarts) {
+		location, ok := bundle.origin(lines.lineStarts[line-1]+position.Column-1, spans)
+		if ok {
+			return location
+		}
+		return fmt.Sprintf("bundle runtime:%v:%v", position.Line, position.Column)
+	}
+	return fmt.Sprintf("bundle:%v:%v", position.Line, position.Column)
+}
+
+// origin returns the module, line and column that the code at offset of the
+// bundle came from, or false if it is part of the runtime of the bundle.
+func (bundle *_bundle) origin(offset int, spans []moduleSpan) (string, bool) {
+	i := sort.Search(len(spans), func(i int) bool {
+		return spans[i].end > offset
+	})
+	if i == len(spans) || offset < spans[i].start {
+		return "", false
+	}
+
+	span := spans[i]
+	lines := newSourceLines(file.NewFile("", string(span.mod.data), 0))
+	line, column, _ := lines.position(file.Idx(offset - span.start))
+	line, column = span.mod.sourcePosition(line, column)
+	return fmt.Sprintf("%v:%v:%v", bundle.sourceName(span.path), line+1, column+1), true
+}
+
+// sourcePosition maps the line and column of the generated code of the
+// module to its source. Modules without mappings, such as assets, are
+// included as they are loaded.
+func (mod *module) sourcePosition(line, column int) (int, int) {
+	i := sort.Search(len(mod.mappings), func(i int) bool {
+		m := mod.mappings[i]
+		return m.genLine > line || (m.genLine == line && m.genColumn > column)
+	})
+	if i == 0 {
+		return line, column
+	}
+	m := mod.mappings[i-1]
+	if m.genLine != line {
+		return m.line, m.column
+	}
+	return m.line, m.column + column - m.genColumn
+}
@@ -0,0 +1,57 @@
+package generator
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/walesey/go-bundle/file"
+)
+
+func TestVerifyTarget(t *testing.T) {
+	fsys := fstest.MapFS{
+		"src/index.js":  {Data: []byte("var vendor = require('./vendor.js');\nvar f = (a) => `${a}!`;\n")},
+		"src/vendor.js": {Data: []byte("module.exports = {\n  f: (a) => a,\n  g: function () { return { ...a }; },\n  h: class {},\n  i: a?.b\n};")},
+	}
+	rules := []LoaderRule{{Test: "vendor.js", Type: ModuleAsset}}
+
+	// the syntax of the entry is lowered, the asset is included as it is
+	_, err := Build("src/index.js", Config{FS: fsys, Rules: rules, VerifyTarget: true})
+	assert.EqualError(t, err, "Bundle has syntax newer than es5:\n"+
+		"  src/vendor.js:2:10: Arrow function requires ES2015\n"+
+		"  src/vendor.js:3:29: Object spread requires ES2018\n"+
+		"  src/vendor.js:4:6: Class requires ES2015\n"+
+		"  src/vendor.js:5:7: Optional chaining requires ES2020")
+
+	_, err = Build("src/index.js", Config{FS: fsys, Rules: rules, Target: TargetES2015, VerifyTarget: true})
+	assert.EqualError(t, err, "Bundle has syntax newer than es2015:\n"+
+		"  src/vendor.js:3:29: Object spread requires ES2018\n"+
+		"  src/vendor.js:5:7: Optional chaining requires ES2020")
+
+	_, err = Build("src/index.js", Config{FS: fsys, Rules: rules, Target: TargetES2019, VerifyTarget: true})
+	assert.EqualError(t, err, "Bundle has syntax newer than es2019:\n"+
+		"  src/vendor.js:5:7: Optional chaining requires ES2020")
+
+	_, err = Build("src/index.js", Config{FS: fsys, Rules: rules, Target: TargetES2020, VerifyTarget: true})
+	assert.NoError(t, err)
+
+	_, err = Build("src/index.js", Config{FS: fsys, Rules: rules, Target: TargetESNext, VerifyTarget: true})
+	assert.NoError(t, err)
+
+	// positions outside of the bundle, like errors at the end of the code,
+	// are not mapped
+	bundle := newBundle(Config{})
+	lines := newSourceLines(file.NewFile("", "a;\nb;", 0))
+	assert.Equal(t, "bundle:0:0", bundle.location(lines, file.Position{}, nil))
+	assert.Equal(t, "bundle:3:1", bundle.location(lines, file.Position{Line: 3, Column: 1}, nil))
+	assert.Equal(t, "bundle runtime:2:1", bundle.location(lines, file.Position{Line: 2, Column: 1}, nil))
+
+	// positions in generated code are mapped to the source of the module
+	mod := &module{mappings: []mapping{{genLine: 0, genColumn: 0, line: 2, column: 2}, {genLine: 1, genColumn: 2, line: 4, column: 0}}}
+	line, column := mod.sourcePosition(0, 8)
+	assert.Equal(t, []int{2, 10}, []int{line, column})
+	line, column = mod.sourcePosition(1, 0)
+	assert.Equal(t, []int{2, 2}, []int{line, column})
+	line, column = mod.sourcePosition(1, 6)
+	assert.Equal(t, []int{4, 4}, []int{line, column})
+}
@@ -0,0 +1,73 @@
+package generator
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// VirtualModule is the source of a module that does not exist on disk.
+type VirtualModule struct {
+	// Contents is the source of the module.
+	Contents string
+
+	// Generate returns the source of the module instead of Contents. It is
+	// called once, when the module is first loaded.
+	Generate func() (string, error)
+}
+
+// BuildSource bundles source, eg. read from stdin, as the entry module. It
+// is loaded as if it was the file at path, which does not need to exist, so
+// relative imports are resolved from the directory of path and the loader
+// rules matching path are applied.
+func BuildSource(source, path string, config Config) (*Result, error) {
+	virtual := map[string]VirtualModule{}
+	for key, mod := range config.Virtual {
+		virtual[key] = mod
+	}
+	if !filepath.IsAbs(path) && !strings.HasPrefix(path, ".") {
+		path = "./" + path
+	}
+	virtual[path] = VirtualModule{Contents: source}
+	config.Virtual = virtual
+	return Build(path, config)
+}
+
+// virtualModules indexes the virtual modules of the config. Modules named
+// by a path are keyed by their absolute path.
+func (c Config) virtualModules(fsys fileSystem) map[string]*VirtualModule {
+	modules := make(map[string]*VirtualModule)
+	for key, mod := range c.Virtual {
+		mod := mod
+		if isPathSpecifier(key) {
+			if abs, err := fsys.abs(key); err == nil {
+				key = abs
+			}
+		}
+		modules[key] = &mod
+	}
+	return modules
+}
+
+// isPathSpecifier reports whether importValue is a relative or absolute
+// path rather than a bare module name.
+func isPathSpecifier(importValue string) bool {
+	return strings.HasPrefix(importValue, ".") || filepath.IsAbs(importValue)
+}
+
+// loadVirtual returns the source of the virtual module at path, if there
+// is one.
+func (bundle *_bundle) loadVirtual(path string) (*LoadResult, error) {
+	mod, ok := bundle.virtual[path]
+	if !ok {
+		return nil, nil
+	}
+	if mod.Generate != nil {
+		contents, err := mod.Generate()
+		if err != nil {
+			return nil, fmt.Errorf("Cannot generate virtual module %v: %v", path, err)
+		}
+		mod.Contents, mod.Generate = contents, nil
+	}
+	return &LoadResult{Contents: []byte(mod.Contents)}, nil
+}
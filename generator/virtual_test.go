@@ -0,0 +1,50 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVirtualModules(t *testing.T) {
+	generated := 0
+	config := Config{
+		Virtual: map[string]VirtualModule{
+			"virtual:routes": {Generate: func() (string, error) {
+				generated++
+				return "module.exports = ['/home'];", nil
+			}},
+			"./testdata/virtual/src/config/index.js": {Contents: "module.exports = require('../helper');"},
+			"./testdata/virtual/src/message.txt":     {Contents: "hello"},
+		},
+		Rules: []LoaderRule{{Test: "*.txt", Loaders: []ModuleLoader{TextLoader}}},
+	}
+
+	source := strings.Join([]string{
+		"var routes = require('virtual:routes');",
+		"var again = require('virtual:routes');",
+		"var config = require('./config');",
+		"var message = require('./message.txt');",
+	}, "\n")
+	result, err := BuildSource(source, "testdata/virtual/src/entry.js", config)
+	assert.NoError(t, err)
+
+	code := string(result.Code)
+	assert.Equal(t, 1, generated)
+	assert.Contains(t, code, "module.exports = ['/home'];")
+	assert.Contains(t, code, "module.exports = 'helper';")
+	assert.Contains(t, code, `module.exports = "hello";`)
+	assert.Len(t, result.Dependencies, 1)
+
+	// the virtual modules are only used by the build they are configured for
+	_, err = Build("testdata/virtual/src/entry.js", Config{})
+	assert.Error(t, err)
+
+	config.Virtual["virtual:broken"] = VirtualModule{Generate: func() (string, error) {
+		return "", fmt.Errorf("failed")
+	}}
+	_, err = BuildSource("import broken from 'virtual:broken';", "entry.js", config)
+	assert.Error(t, err)
+}
@@ -1,18 +1,87 @@
 package main
 
 import (
-	"bytes"
+	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"regexp"
+	"strings"
 
 	"github.com/walesey/go-bundle/cssLoader"
 	"github.com/walesey/go-bundle/generator"
 )
 
+// defineFlag collects the -define flags, eg. -define process.env.NODE_ENV='"production"'.
+type defineFlag map[string]string
+
+func (d defineFlag) String() string {
+	return fmt.Sprint(map[string]string(d))
+}
+
+func (d defineFlag) Set(value string) error {
+	i := strings.Index(value, "=")
+	if i == -1 {
+		return fmt.Errorf("Expected NAME=VALUE, got %v", value)
+	}
+	d[value[:i]] = value[i+1:]
+	return nil
+}
+
+// printFlags defines the flags of the print options on fs and returns a
+// function that reads them after parsing.
+func printFlags(fs *flag.FlagSet) func() generator.PrintOptions {
+	indent := fs.Int("indent", 0, "spaces per indentation level (default 2)")
+	tabs := fs.Bool("tabs", false, "indent with tabs")
+	quotes := fs.String("quotes", "", "quotes of strings, \"double\" or \"single\" (default keeps the source quotes)")
+	trailingCommas := fs.Bool("trailing-commas", false, "add trailing commas to multi-line objects and arrays")
+	semicolons := fs.String("semicolons", "", "\"as-needed\" leaves out the semicolons ending lines")
+	lineWidth := fs.Int("line-width", 0, "wrap argument and array lists longer than this")
+	asciiOnly := fs.Bool("ascii-only", false, "escape characters outside of ASCII")
+	return func() generator.PrintOptions {
+		return generator.PrintOptions{
+			Indent:         *indent,
+			Tabs:           *tabs,
+			Quotes:         generator.QuoteStyle(*quotes),
+			TrailingCommas: *trailingCommas,
+			Semicolons:     generator.SemicolonStyle(*semicolons),
+			LineWidth:      *lineWidth,
+			ASCIIOnly:      *asciiOnly,
+		}
+	}
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "fmt" {
+		os.Exit(runFmt(os.Args[2:]))
+	}
+
+	outdir := flag.String("outdir", "", "write the bundle, assets and manifest.json to this directory")
+	hash := flag.Bool("hash", false, "add content hashes to the file names written to -outdir")
+	comments := flag.String("comments", "", "keep comments, \"all\" or \"license\"")
+	minify := flag.Bool("minify", false, "write compact code")
+	mangle := flag.Bool("mangle", false, "rename local variables to short names")
+	mangleProps := flag.String("mangle-props", "", "rename properties matching this regular expression")
+	define := defineFlag{}
+	flag.Var(define, "define", "replace a global name with a literal, NAME=VALUE (repeatable)")
+	dce := flag.Bool("dce", false, "remove unreachable code and unused declarations")
+	dropDebugging := flag.Bool("drop-debugging", false, "remove console.* calls and debugger statements")
+	printOptions := printFlags(flag.CommandLine)
+	target := flag.String("target", "", "version of the output, \"es5\" to \"es2099\" or \"esnext\" (default es5)")
+	verifyTarget := flag.Bool("verify-target", false, "fail if the bundle has syntax newer than -target")
+	polyfill := flag.Bool("polyfill", false, "add polyfills for the built-ins the bundle uses that are newer than -target")
+	sourceMap := flag.String("sourcemap", "", "generate a source map, \"inline\" or \"external\" (requires -outdir)")
+	flag.Parse()
+
+	// the external source map is written next to the bundle
+	if generator.SourceMapMode(*sourceMap) == generator.SourceMapExternal && *outdir == "" {
+		fmt.Println("-sourcemap external requires -outdir")
+		os.Exit(2)
+	}
+
 	entry := "./index.js"
-	if len(os.Args) >= 2 {
-		entry = os.Args[1]
+	if flag.NArg() >= 1 {
+		entry = flag.Arg(0)
 	}
 
 	styleLoader := cssLoader.New(cssLoader.Config{
@@ -20,18 +89,68 @@ func main() {
 		Outfile:     "./styles.css",
 	})
 
-	loaders := map[string][]generator.Loader{
-		".css": []generator.Loader{styleLoader},
+	loaders := map[string][]generator.ModuleLoader{
+		".css": []generator.ModuleLoader{styleLoader},
+	}
+
+	config := generator.Config{
+		Loaders:   loaders,
+		SourceMap: generator.SourceMapMode(*sourceMap),
+		Comments:  generator.CommentMode(*comments),
+		Minify:    *minify,
+		Mangle:    *mangle,
+		Define:    define,
+
+		DeadCode:      *dce,
+		DropDebugging: *dropDebugging,
+
+		Print:        printOptions(),
+		Target:       generator.Target(*target),
+		VerifyTarget: *verifyTarget,
+		Polyfill:     *polyfill,
+	}
+	if *mangleProps != "" {
+		pattern, err := regexp.Compile(*mangleProps)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		config.MangleProperties = pattern
 	}
 
-	gen, err := generator.Bundle(entry, loaders)
+	// "-" reads the entry source from stdin
+	var result *generator.Result
+	var err error
+	if entry == "-" {
+		src, readErr := ioutil.ReadAll(os.Stdin)
+		if readErr != nil {
+			fmt.Println(readErr)
+			return
+		}
+		result, err = generator.BuildSource(string(src), "stdin.js", config)
+	} else {
+		result, err = generator.Build(entry, config)
+	}
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
 
-	buf := new(bytes.Buffer)
-	buf.ReadFrom(gen)
+	if *outdir != "" {
+		output := generator.OutputConfig{Dir: *outdir}
+		if *hash {
+			output.EntryNames = "[name].[hash].js"
+			output.AssetNames = "[dir][name].[hash][ext]"
+		}
+		if _, err := result.Write(output); err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
 
-	fmt.Print(string(buf.Bytes()))
+	if err := result.WriteAssets("."); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Print(string(result.Code))
 }
@@ -6,12 +6,19 @@ import (
 	"strings"
 
 	"github.com/walesey/go-bundle/ast"
+	"github.com/walesey/go-bundle/file"
 	"github.com/walesey/go-bundle/token"
 )
 
-func (self *_parser) parseArrowFunction(params *ast.ParameterList) *ast.FunctionLiteral {
+func (self *_parser) parseArrowFunction(params *ast.ParameterList, async file.Idx) *ast.FunctionLiteral {
 	node := &ast.FunctionLiteral{
 		Function: self.expect(token.ARROW),
+		Arrow:    true,
+		Async:    async != 0,
+	}
+	self.requireVersion(node.Function, 2015, "Arrow function")
+	if node.Async {
+		self.requireVersion(async, 2017, "Async function")
 	}
 
 	if self.mode&StoreComments != 0 {
@@ -21,11 +28,15 @@ func (self *_parser) parseArrowFunction(params *ast.ParameterList) *ast.Function
 	if self.token == token.LEFT_BRACE {
 		self.parseFunctionBlock(node)
 	} else {
+		// await is an operator in the body of an async arrow function
+		inAsync := self.scope.inAsync
+		self.scope.inAsync = node.Async
 		leftBrace := self.idx
 		stmt := &ast.ReturnStatement{
 			Return:   leftBrace,
 			Argument: self.parseAssignmentExpression(),
 		}
+		self.scope.inAsync = inAsync
 		node.Body = &ast.BlockStatement{
 			LeftBrace:  leftBrace,
 			List:       []ast.Statement{stmt},
@@ -37,6 +48,137 @@ func (self *_parser) parseArrowFunction(params *ast.ParameterList) *ast.Function
 	return node
 }
 
+// isAsyncFunction reports whether the current token is the async of an
+// async function, which must be followed by function on the same line.
+func (self *_parser) isAsyncFunction() bool {
+	if self.token != token.IDENTIFIER || self.literal != "async" {
+		return false
+	}
+	i := self.chrOffset
+	for i < self.length && (self.str[i] == ' ' || self.str[i] == '\t') {
+		i++
+	}
+	rest := self.str[i:]
+	return strings.HasPrefix(rest, "function") &&
+		(len(rest) == 8 || !isIdentifierPart(rune(rest[8])))
+}
+
+// parseAsync skips the async of an async function, and returns its
+// position, or 0 if there is none.
+func (self *_parser) parseAsync() file.Idx {
+	if !self.isAsyncFunction() {
+		return 0
+	}
+	return self.expect(token.IDENTIFIER)
+}
+
+func (self *_parser) parseClassStatement() *ast.ClassStatement {
+	var comments []*ast.Comment
+	if self.mode&StoreComments != 0 {
+		comments = self.comments.FetchAll()
+	}
+	node := &ast.ClassStatement{
+		Class: self.parseClass(true),
+	}
+	if self.mode&StoreComments != 0 {
+		self.comments.CommentMap.AddComments(node, comments, ast.LEADING)
+	}
+
+	return node
+}
+
+func (self *_parser) parseClass(declaration bool) *ast.ClassLiteral {
+	node := &ast.ClassLiteral{
+		Class: self.expect(token.CLASS),
+	}
+	self.requireVersion(node.Class, 2015, "Class")
+
+	if self.token == token.IDENTIFIER {
+		node.Name = self.parseIdentifier()
+	} else if declaration {
+		// Use expect error handling
+		self.expect(token.IDENTIFIER)
+	}
+	if self.token == token.EXTENDS {
+		self.next()
+		node.SuperClass = self.parseLeftHandSideExpressionAllowCall()
+	}
+	if self.mode&StoreComments != 0 {
+		self.comments.Unset()
+	}
+
+	self.expect(token.LEFT_BRACE)
+	for self.token != token.RIGHT_BRACE && self.token != token.EOF {
+		if self.token == token.SEMICOLON {
+			self.next()
+			continue
+		}
+		node.Body = append(node.Body, self.parseMethodDefinition())
+	}
+	if self.mode&StoreComments != 0 {
+		self.comments.CommentMap.AddComments(node, self.comments.FetchAll(), ast.FINAL)
+	}
+	node.RightBrace = self.expect(token.RIGHT_BRACE)
+
+	return node
+}
+
+func (self *_parser) parseMethodDefinition() ast.MethodDefinition {
+	var comments []*ast.Comment
+	if self.mode&StoreComments != 0 {
+		comments = self.comments.FetchAll()
+	}
+	method := ast.MethodDefinition{
+		Idx:  self.idx,
+		Kind: "method",
+	}
+
+	// static, async, get and set are the names of methods if they are
+	// followed by the parameters
+	idx := self.idx
+	literal, value, computed := self.parsePropertyKey()
+	if literal == "static" && self.token != token.LEFT_PARENTHESIS {
+		method.Static = true
+		idx = self.idx
+		literal, value, computed = self.parsePropertyKey()
+	}
+	async := false
+	if literal == "async" && self.token != token.LEFT_PARENTHESIS && !self.implicitSemicolon {
+		async = true
+		idx = self.idx
+		literal, value, computed = self.parsePropertyKey()
+	} else if (literal == "get" || literal == "set") && self.token != token.LEFT_PARENTHESIS {
+		method.Kind = literal
+		idx = self.idx
+		_, value, computed = self.parsePropertyKey()
+	}
+	method.Key = value
+	method.Computed = computed
+	if value == "constructor" && !method.Static && computed == nil {
+		if method.Kind != "method" || async {
+			self.error(idx, "Class constructor may not be an accessor or async")
+		}
+		method.Kind = "constructor"
+	}
+
+	node := &ast.FunctionLiteral{
+		Function: idx,
+		Async:    async,
+	}
+	if async {
+		self.requireVersion(idx, 2017, "Async function")
+	}
+	node.ParameterList = self.parseFunctionParameterList()
+	self.parseFunctionBlock(node)
+	node.Source = self.slice(node.Idx0(), node.Idx1())
+	method.Value = node
+
+	if self.mode&StoreComments != 0 {
+		self.comments.CommentMap.AddComments(node, comments, ast.KEY)
+	}
+	return method
+}
+
 func (self *_parser) parseImportIdentifier() *ast.ImportIdentifier {
 	node := &ast.ImportIdentifier{Name: self.parseIdentifier()}
 
@@ -70,9 +212,14 @@ func (self *_parser) parseImportIdentifierList() []*ast.ImportIdentifier {
 }
 
 func (self *_parser) parseImportStatement() ast.Statement {
+	var comments []*ast.Comment
+	if self.mode&StoreComments != 0 {
+		comments = self.comments.FetchAll()
+	}
 	node := &ast.ImportStatement{
 		Import: self.expect(token.IMPORT),
 	}
+	self.requireVersion(node.Import, 2015, "Import statement")
 
 	if self.token != token.STRING {
 		if self.token == token.MULTIPLY {
@@ -137,11 +284,15 @@ func (self *_parser) parseImportStatement() ast.Statement {
 		Literal: literal,
 		Value:   value,
 	}
+	if self.mode&StoreComments != 0 {
+		self.comments.CommentMap.AddComments(node, comments, ast.LEADING)
+	}
 	return node
 }
 
 func (self *_parser) parseExportStatement() ast.Statement {
 	export := self.expect(token.EXPORT)
+	self.requireVersion(export, 2015, "Export statement")
 
 	if self.token == token.DEFAULT {
 		self.expect(token.DEFAULT)
@@ -151,15 +302,22 @@ func (self *_parser) parseExportStatement() ast.Statement {
 		}
 	}
 
-	if self.token == token.FUNCTION {
+	if self.token == token.FUNCTION || self.isAsyncFunction() {
 		return &ast.ExportStatement{
 			Export: export,
 			Statement: &ast.FunctionStatement{
-				Function: self.parseFunction(false),
+				Function: self.parseFunction(false, self.parseAsync()),
 			},
 		}
 	}
 
+	if self.token == token.CLASS {
+		return &ast.ExportStatement{
+			Export:    export,
+			Statement: self.parseClassStatement(),
+		}
+	}
+
 	if self.token != token.VAR && self.token != token.CONST && self.token != token.LET {
 		self.errorUnexpectedToken(self.token)
 	}
@@ -170,7 +328,10 @@ func (self *_parser) parseExportStatement() ast.Statement {
 	}
 }
 
-func (self *_parser) parseDestructureVariableStatement() []ast.Expression {
+// parseDestructureVariableStatement parses a destructuring declaration as
+// declarations of its properties, eg. var a = b.a. It returns the names
+// declared too, which are not nodes of the tree.
+func (self *_parser) parseDestructureVariableStatement() ([]ast.Expression, []*ast.Identifier) {
 	self.expect(token.LEFT_BRACE)
 	identifierList := self.parseIdentifierList()
 	self.expect(token.RIGHT_BRACE)
@@ -192,18 +353,21 @@ func (self *_parser) parseDestructureVariableStatement() []ast.Expression {
 		}
 	}
 
-	return result
+	return result, identifierList
 }
 
 func (self *_parser) parseDynamicString() ast.Expression {
 	idx := self.expect(token.TEMPLATE)
+	self.requireVersion(idx, 2015, "Template string")
 	list := []ast.Expression{}
+	var raw []string
 
 	for {
 		literal := self.literal
 		strIdx := self.idx
 		value, err := self.scanTemplateString(self.chrOffset)
 		value = fmt.Sprint(literal, value)
+		raw = append(raw, value)
 		value = regexp.MustCompile("(\n|\r\n|\u2028|\u2029)").ReplaceAllString(value, "\\n")
 		value = strings.Replace(value, "'", "\\'", -1)
 		literal = fmt.Sprintf("'%v'", value)
@@ -237,5 +401,6 @@ func (self *_parser) parseDynamicString() ast.Expression {
 	return &ast.DynamicStringExpression{
 		Idx:  idx,
 		List: list,
+		Raw:  raw,
 	}
 }
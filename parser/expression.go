@@ -56,18 +56,31 @@ func (self *_parser) parsePrimaryExpression() ast.Expression {
 				}
 			}
 		}
+		if literal == "async" && !self.implicitSemicolon {
+			switch {
+			case self.token == token.FUNCTION:
+				return self.parseFunction(false, idx)
+			case self.token == token.IDENTIFIER:
+				param := self.parseIdentifier()
+				params := &ast.ParameterList{
+					Opening: param.Idx,
+					List:    []*ast.Identifier{param},
+					Closing: self.idx,
+				}
+				return self.parseArrowFunction(params, idx)
+			case self.token == token.LEFT_PARENTHESIS && self.arrowAhead():
+				return self.parseArrowFunction(self.parseFunctionParameterList(), idx)
+			}
+		}
 		if self.token == token.ARROW {
 			params := &ast.ParameterList{
 				Opening: idx,
 				List:    []*ast.Identifier{ident},
 				Closing: self.idx,
 			}
-			return self.parseArrowFunction(params)
-		}
-		return &ast.Identifier{
-			Name: literal,
-			Idx:  idx,
+			return self.parseArrowFunction(params, 0)
 		}
+		return ident
 	case token.NULL:
 		self.next()
 		return &ast.NullLiteral{
@@ -121,25 +134,9 @@ func (self *_parser) parsePrimaryExpression() ast.Expression {
 		return self.parseArrayLiteral()
 	case token.LEFT_PARENTHESIS:
 		//arrow function args
-		closed := false
-		for i := 1; ; { // peek ahead for the arrow token
-			chr := self.chrAt(self.offset + i)
-			if closed {
-				if chr.value == '=' {
-					chr := self.chrAt(self.offset + i + 1)
-					if chr.value == '>' {
-						params := self.parseFunctionParameterList()
-						return self.parseArrowFunction(params)
-					}
-				} else if chr.value != ' ' {
-					break
-				}
-			} else if chr.value == '(' {
-				break
-			} else if chr.value == ')' {
-				closed = true
-			}
-			i += chr.width
+		if self.arrowAhead() {
+			params := self.parseFunctionParameterList()
+			return self.parseArrowFunction(params, 0)
 		}
 		// expression in parenthesis
 		self.expect(token.LEFT_PARENTHESIS)
@@ -150,7 +147,7 @@ func (self *_parser) parsePrimaryExpression() ast.Expression {
 				List:    []*ast.Identifier{},
 				Closing: closing,
 			}
-			return self.parseArrowFunction(emptyParams)
+			return self.parseArrowFunction(emptyParams, 0)
 		}
 
 		expression := self.parseExpression()
@@ -165,7 +162,14 @@ func (self *_parser) parsePrimaryExpression() ast.Expression {
 			Idx: idx,
 		}
 	case token.FUNCTION:
-		return self.parseFunction(false)
+		return self.parseFunction(false, 0)
+	case token.CLASS:
+		return self.parseClass(false)
+	case token.SUPER:
+		self.next()
+		return &ast.SuperExpression{
+			Idx: idx,
+		}
 	case token.TEMPLATE:
 		return self.parseDynamicString()
 	}
@@ -175,6 +179,40 @@ func (self *_parser) parsePrimaryExpression() ast.Expression {
 	return &ast.BadExpression{From: idx, To: self.idx}
 }
 
+// arrowAhead reports whether the parameter list at the current left
+// parenthesis is followed by the arrow of an arrow function. The default
+// values of the parameters may contain brackets and strings.
+func (self *_parser) arrowAhead() bool {
+	depth := 0
+	var quote rune
+	for i := self.chrOffset; i < self.length; { // peek ahead for the arrow token
+		chr := self.chrAt(i)
+		switch {
+		case quote != 0:
+			if chr.value == '\\' {
+				i += chr.width
+				chr = self.chrAt(i)
+			} else if chr.value == quote {
+				quote = 0
+			}
+		case depth < 0:
+			if chr.value == '=' {
+				return self.chrAt(i+1).value == '>'
+			} else if chr.value != ' ' {
+				return false
+			}
+		case chr.value == '"' || chr.value == '\'' || chr.value == '`':
+			quote = chr.value
+		case chr.value == '(' || chr.value == '[' || chr.value == '{':
+			depth++
+		case chr.value == ')' || chr.value == ']' || chr.value == '}':
+			depth--
+		}
+		i += chr.width
+	}
+	return false
+}
+
 func (self *_parser) parseRegExpLiteral() *ast.RegExpLiteral {
 
 	offset := self.chrOffset - 1 // Opening slash already gotten
@@ -308,20 +346,36 @@ func (self *_parser) parseObjectPropertyKey() (string, string) {
 	return literal, value
 }
 
+// parsePropertyKey parses the key of a property or method, which is
+// computed for [a].
+func (self *_parser) parsePropertyKey() (literal, value string, computed ast.Expression) {
+	if self.token != token.LEFT_BRACKET {
+		literal, value = self.parseObjectPropertyKey()
+		return literal, value, nil
+	}
+	if self.mode&StoreComments != 0 {
+		self.comments.MarkComments(ast.KEY)
+	}
+	self.requireVersion(self.expect(token.LEFT_BRACKET), 2015, "Computed property")
+	computed = self.parseAssignmentExpression()
+	self.expect(token.RIGHT_BRACKET)
+	return "", "", computed
+}
+
 func (self *_parser) parseObjectProperty() ast.Property {
 	if self.token == token.SPREAD {
-		self.expect(token.SPREAD)
+		self.requireVersion(self.expect(token.SPREAD), 2018, "Object spread")
 		return ast.Property{
 			Kind:  "spread",
 			Value: self.parsePrimaryExpression(),
 		}
 	}
 
-	tkn := self.token
-	literal, value := self.parseObjectPropertyKey()
-	if literal == "get" && self.token != token.COLON {
+	tkn, idx := self.token, self.idx
+	literal, value, computed := self.parsePropertyKey()
+	if (literal == "get" || literal == "set") && self.token != token.COLON {
 		idx := self.idx
-		_, value := self.parseObjectPropertyKey()
+		_, value, computed := self.parsePropertyKey()
 		parameterList := self.parseFunctionParameterList()
 
 		node := &ast.FunctionLiteral{
@@ -330,24 +384,10 @@ func (self *_parser) parseObjectProperty() ast.Property {
 		}
 		self.parseFunctionBlock(node)
 		return ast.Property{
-			Key:   value,
-			Kind:  "get",
-			Value: node,
-		}
-	} else if literal == "set" && self.token != token.COLON {
-		idx := self.idx
-		_, value := self.parseObjectPropertyKey()
-		parameterList := self.parseFunctionParameterList()
-
-		node := &ast.FunctionLiteral{
-			Function:      idx,
-			ParameterList: parameterList,
-		}
-		self.parseFunctionBlock(node)
-		return ast.Property{
-			Key:   value,
-			Kind:  "set",
-			Value: node,
+			Key:      value,
+			Kind:     literal,
+			Value:    node,
+			Computed: computed,
 		}
 	}
 
@@ -356,15 +396,18 @@ func (self *_parser) parseObjectProperty() ast.Property {
 	}
 
 	exp := ast.Property{
-		Key:  value,
-		Kind: "value",
+		Key:      value,
+		Kind:     "value",
+		Computed: computed,
 	}
 
 	if tkn == token.IDENTIFIER && self.token != token.COLON {
+		self.requireVersion(idx, 2015, "Shorthand property")
 		exp.Value = &ast.Identifier{
-			Idx:  self.idx,
+			Idx:  idx,
 			Name: value,
 		}
+		exp.Shorthand = true
 	} else {
 		self.expect(token.COLON)
 		exp.Value = self.parseAssignmentExpression()
@@ -407,7 +450,7 @@ func (self *_parser) parseArrayLiteral() ast.Expression {
 	for self.token != token.RIGHT_BRACKET && self.token != token.EOF {
 		if self.token == token.COMMA {
 			// This kind of comment requires a special empty expression node.
-			empty := &ast.EmptyExpression{self.idx, self.idx}
+			empty := &ast.EmptyExpression{Begin: self.idx, End: self.idx}
 
 			if self.mode&StoreComments != 0 {
 				self.comments.SetExpression(empty)
@@ -456,8 +499,10 @@ func (self *_parser) parseArgumentList() (argumentList []ast.Expression, idx0, i
 				if self.mode&StoreComments != 0 {
 					self.comments.Unset()
 				}
+				comma := self.idx
 				self.next()
 				if self.token == token.RIGHT_PARENTHESIS {
+					self.requireVersion(comma, 2017, "Trailing comma in arguments")
 					break
 				}
 			} else {
@@ -523,6 +568,41 @@ func (self *_parser) parseBracketMember(left ast.Expression) ast.Expression {
 	}
 }
 
+// parseOptionalChain parses a?.b, a?.[b] or a?.(b) after a.
+func (self *_parser) parseOptionalChain(left ast.Expression) ast.Expression {
+	idx := self.expect(token.OPTIONAL_CHAINING)
+	self.requireVersion(idx, 2020, "Optional chaining")
+
+	switch self.token {
+	case token.LEFT_BRACKET:
+		member := self.parseBracketMember(left).(*ast.BracketExpression)
+		member.Optional = true
+		return member
+	case token.LEFT_PARENTHESIS:
+		call := self.parseCallExpression(left).(*ast.CallExpression)
+		call.Optional = true
+		return call
+	}
+
+	literal := self.literal
+	identifier := self.idx
+	if !matchIdentifier.MatchString(literal) {
+		self.expect(token.IDENTIFIER)
+		self.nextStatement()
+		return &ast.BadExpression{From: idx, To: self.idx}
+	}
+	self.next()
+
+	return &ast.DotExpression{
+		Left: left,
+		Identifier: &ast.Identifier{
+			Idx:  identifier,
+			Name: literal,
+		},
+		Optional: true,
+	}
+}
+
 func (self *_parser) parseNewExpression() ast.Expression {
 	idx := self.expect(token.NEW)
 	callee := self.parseLeftHandSideExpression()
@@ -613,6 +693,8 @@ func (self *_parser) parseLeftHandSideExpressionAllowCall() ast.Expression {
 			left = self.parseBracketMember(left)
 		} else if self.token == token.LEFT_PARENTHESIS {
 			left = self.parseCallExpression(left)
+		} else if self.token == token.OPTIONAL_CHAINING {
+			left = self.parseOptionalChain(left)
 		} else {
 			break
 		}
@@ -698,13 +780,57 @@ func (self *_parser) parseUnaryExpression() ast.Expression {
 			Idx:      idx,
 			Operand:  operand,
 		}
+	case token.IDENTIFIER:
+		if self.literal == "await" && self.scope.inAsync {
+			idx := self.idx
+			if self.mode&StoreComments != 0 {
+				self.comments.Unset()
+			}
+			self.next()
+
+			return &ast.AwaitExpression{
+				Await:    idx,
+				Argument: self.parseUnaryExpression(),
+			}
+		}
 	}
 
 	return self.parsePostfixExpression()
 }
 
+func (self *_parser) parseExponentiationExpression() ast.Expression {
+	unary := false
+	switch self.token {
+	case token.PLUS, token.MINUS, token.NOT, token.BITWISE_NOT, token.DELETE, token.VOID, token.TYPEOF:
+		unary = true
+	}
+	left := self.parseUnaryExpression()
+
+	if self.token == token.EXPONENT {
+		idx := self.idx
+		self.requireVersion(idx, 2016, "Exponentiation operator")
+		if unary {
+			// -a ** b is ambiguous, (-a) ** b must be written instead
+			self.error(idx, "Unary operator before exponentiation operator")
+		}
+		if self.mode&StoreComments != 0 {
+			self.comments.Unset()
+		}
+		self.next()
+
+		// ** is right-associative
+		return &ast.BinaryExpression{
+			Operator: token.EXPONENT,
+			Left:     left,
+			Right:    self.parseExponentiationExpression(),
+		}
+	}
+
+	return left
+}
+
 func (self *_parser) parseMultiplicativeExpression() ast.Expression {
-	next := self.parseUnaryExpression
+	next := self.parseExponentiationExpression
 	left := next()
 
 	for self.token == token.MULTIPLY || self.token == token.SLASH ||
@@ -938,11 +1064,14 @@ func (self *_parser) parseLogicalOrExpression() ast.Expression {
 	next := self.parseLogicalAndExpression
 	left := next()
 
-	for self.token == token.LOGICAL_OR {
+	for self.token == token.LOGICAL_OR || self.token == token.NULLISH_COALESCING {
 		if self.mode&StoreComments != 0 {
 			self.comments.Unset()
 		}
 		tkn := self.token
+		if tkn == token.NULLISH_COALESCING {
+			self.requireVersion(self.idx, 2020, "Nullish coalescing operator")
+		}
 		self.next()
 
 		left = &ast.BinaryExpression{
@@ -997,6 +1126,9 @@ func (self *_parser) parseAssignmentExpression() ast.Expression {
 		operator = token.SLASH
 	case token.REMAINDER_ASSIGN:
 		operator = token.REMAINDER
+	case token.EXPONENT_ASSIGN:
+		self.requireVersion(self.idx, 2016, "Exponentiation operator")
+		operator = token.EXPONENT
 	case token.AND_ASSIGN:
 		operator = token.AND
 	case token.AND_NOT_ASSIGN:
@@ -97,7 +97,8 @@ func (self *_parser) parseOpeningElement() *ast.JSXElement {
 	}
 
 	for {
-		if self.token == token.IDENTIFIER {
+		// attributes may be named like keywords, eg. class
+		if self.token != token.LEFT_BRACE && matchIdentifier.MatchString(self.literal) {
 			open.PropertyList = append(open.PropertyList, self.parseJSXProperty())
 		} else if self.token == token.LEFT_BRACE {
 			open.PropertyList = append(open.PropertyList, self.parseObjectLiteral().Value...)
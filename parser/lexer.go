@@ -139,6 +139,10 @@ func (self *_parser) scan() (tkn token.Token, literal string, idx file.Idx) {
 			}
 			tkn = token.WHITESPACE
 			literal = string(self.str[offset:self.chrOffset])
+			if self.insertSemicolon && strings.ContainsAny(literal, "\r\n\u2028\u2029") {
+				self.insertSemicolon = false
+				self.implicitSemicolon = true
+			}
 			return
 		}
 
@@ -184,6 +188,7 @@ func (self *_parser) scan() (tkn token.Token, literal string, idx file.Idx) {
 
 				case
 					token.THIS,
+					token.SUPER,
 					token.BREAK,
 					token.THROW, // A newline after a throw is not allowed, but we need to detect it
 					token.RETURN,
@@ -263,7 +268,12 @@ func (self *_parser) scan() (tkn token.Token, literal string, idx file.Idx) {
 					insertSemicolon = true
 				}
 			case '*':
-				tkn = self.switch2(token.MULTIPLY, token.MULTIPLY_ASSIGN)
+				if self.chr == '*' {
+					self.read()
+					tkn = self.switch2(token.EXPONENT, token.EXPONENT_ASSIGN)
+				} else {
+					tkn = self.switch2(token.MULTIPLY, token.MULTIPLY_ASSIGN)
+				}
 			case '/':
 				if self.chr == '/' {
 					if self.mode&StoreComments != 0 {
@@ -322,7 +332,16 @@ func (self *_parser) scan() (tkn token.Token, literal string, idx file.Idx) {
 			case '~':
 				tkn = token.BITWISE_NOT
 			case '?':
-				tkn = token.QUESTION_MARK
+				if self.chr == '.' && (self.offset >= self.length || digitValue(rune(self.str[self.offset])) >= 10) {
+					// a?.5:0 is a conditional
+					self.read()
+					tkn = token.OPTIONAL_CHAINING
+				} else if self.chr == '?' {
+					self.read()
+					tkn = token.NULLISH_COALESCING
+				} else {
+					tkn = token.QUESTION_MARK
+				}
 			case '`':
 				tkn = token.TEMPLATE
 			case '"', '\'':
@@ -17,6 +17,13 @@ type Mode uint
 const (
 	IgnoreRegExpErrors Mode = 1 << iota // Ignore RegExp compatibility errors (allow backtracking)
 	StoreComments                       // Store the comments from source to the comments map
+	ES5                                 // Report syntax newer than ES5 as errors
+	ES2015                              // Report syntax newer than ES2015 as errors
+	ES2016                              // Report syntax newer than ES2016 as errors
+	ES2017                              // Report syntax newer than ES2017 as errors
+	ES2018                              // Report syntax newer than ES2018 as errors
+	ES2019                              // Report syntax newer than ES2019 as errors
+	ES2020                              // Report syntax newer than ES2020 as errors
 )
 
 type _parser struct {
@@ -178,6 +185,36 @@ func (self *_parser) parse() (*ast.Program, error) {
 	return program, self.errors.Err()
 }
 
+// version returns the year of the JavaScript version the mode restricts the
+// syntax to, or 0 if all syntax is allowed.
+func (self *_parser) version() int {
+	switch {
+	case self.mode&ES5 != 0:
+		return 2009
+	case self.mode&ES2015 != 0:
+		return 2015
+	case self.mode&ES2016 != 0:
+		return 2016
+	case self.mode&ES2017 != 0:
+		return 2017
+	case self.mode&ES2018 != 0:
+		return 2018
+	case self.mode&ES2019 != 0:
+		return 2019
+	case self.mode&ES2020 != 0:
+		return 2020
+	}
+	return 0
+}
+
+// requireVersion reports syntax at idx as an error if it was added in a
+// later version than the mode allows.
+func (self *_parser) requireVersion(idx file.Idx, version int, syntax string) {
+	if restricted := self.version(); restricted != 0 && version > restricted {
+		self.error(idx, "%v requires ES%d", syntax, version)
+	}
+}
+
 // rawNext moves pointer to the next token
 func (self *_parser) rawNext() {
 	self.token, self.literal, self.idx = self.scan()
@@ -185,12 +222,17 @@ func (self *_parser) rawNext() {
 
 // next moves pointer to next non-whitespace token
 func (self *_parser) next() {
+	// the line break that allows a semicolon is in the whitespace before the
+	// token
+	implicitSemicolon := false
 	for {
 		self.token, self.literal, self.idx = self.scan()
+		implicitSemicolon = implicitSemicolon || self.implicitSemicolon
 		if self.token != token.WHITESPACE {
 			break
 		}
 	}
+	self.implicitSemicolon = implicitSemicolon
 }
 
 func (self *_parser) semicolon() {
@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/walesey/go-bundle/ast"
+)
+
+func TestImplicitSemicolon(t *testing.T) {
+	// a line break ends break and return statements, also when the next
+	// line is indented
+	program, err := p("switch (a) {\n  case 1:\n    break\n  default:\n    b()\n}")
+	assert.NoError(t, err)
+	cases := program.Body[0].(*ast.SwitchStatement).Body
+	assert.Len(t, cases, 2)
+
+	program, err = p("function f() {\n  return\n  a\n}")
+	assert.NoError(t, err)
+	body := program.Body[0].(*ast.FunctionStatement).Function.Body.(*ast.BlockStatement)
+	assert.Nil(t, body.List[0].(*ast.ReturnStatement).Argument)
+	assert.Len(t, body.List, 2)
+
+	_, err = p("for (;;) {\n  break\n  a()\n}")
+	assert.NoError(t, err)
+}
+
+func TestRequireVersion(t *testing.T) {
+	src := "let f = (a) => `${a}!`;\nvar o = { a, ...b };\nf(1, 2,);"
+	parse := func(mode Mode) []string {
+		_, err := ParseFile(nil, "a.js", src, mode)
+		if err == nil {
+			return nil
+		}
+		var errors []string
+		for _, e := range err.(ErrorList) {
+			errors = append(errors, e.Error())
+		}
+		return errors
+	}
+
+	assert.Nil(t, parse(0))
+	assert.Equal(t, []string{
+		"a.js: Line 1:1 let declaration requires ES2015",
+		"a.js: Line 1:13 Arrow function requires ES2015",
+		"a.js: Line 1:16 Template string requires ES2015",
+		"a.js: Line 2:11 Shorthand property requires ES2015",
+		"a.js: Line 2:14 Object spread requires ES2018",
+		"a.js: Line 3:7 Trailing comma in arguments requires ES2017",
+	}, parse(ES5))
+	assert.Equal(t, []string{
+		"a.js: Line 2:14 Object spread requires ES2018",
+		"a.js: Line 3:7 Trailing comma in arguments requires ES2017",
+	}, parse(ES2015))
+	assert.Equal(t, []string{
+		"a.js: Line 2:14 Object spread requires ES2018",
+	}, parse(ES2017))
+
+	src = "class A {}\nvar b = 2 ** 3;\nasync function c() {}"
+	assert.Equal(t, []string{
+		"a.js: Line 1:1 Class requires ES2015",
+		"a.js: Line 2:11 Exponentiation operator requires ES2016",
+		"a.js: Line 3:1 Async function requires ES2017",
+	}, parse(ES5))
+	assert.Equal(t, []string{
+		"a.js: Line 2:11 Exponentiation operator requires ES2016",
+		"a.js: Line 3:1 Async function requires ES2017",
+	}, parse(ES2015))
+	assert.Equal(t, []string{
+		"a.js: Line 3:1 Async function requires ES2017",
+	}, parse(ES2016))
+	assert.Nil(t, parse(ES2017))
+
+	src = "function f(a = 1, ...b) {}\nfor (const c of d) {}\nvar e = { [f]: 1 };"
+	assert.Equal(t, []string{
+		"a.js: Line 1:14 Default parameter requires ES2015",
+		"a.js: Line 1:19 Rest parameter requires ES2015",
+		"a.js: Line 2:6 const declaration requires ES2015",
+		"a.js: Line 2:1 for-of statement requires ES2015",
+		"a.js: Line 3:11 Computed property requires ES2015",
+	}, parse(ES5))
+	assert.Nil(t, parse(ES2015))
+
+	src = "try {} catch {}\nvar a = b?.c ?? d;"
+	assert.Equal(t, []string{
+		"a.js: Line 1:8 Optional catch binding requires ES2019",
+		"a.js: Line 2:10 Optional chaining requires ES2020",
+		"a.js: Line 2:14 Nullish coalescing operator requires ES2020",
+	}, parse(ES2018))
+	assert.Equal(t, []string{
+		"a.js: Line 2:10 Optional chaining requires ES2020",
+		"a.js: Line 2:14 Nullish coalescing operator requires ES2020",
+	}, parse(ES2019))
+	assert.Nil(t, parse(ES2020))
+}
@@ -10,6 +10,7 @@ type _scope struct {
 	inIteration     bool
 	inSwitch        bool
 	inFunction      bool
+	inAsync         bool
 	declarationList []ast.Declaration
 
 	labels []string
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"github.com/walesey/go-bundle/ast"
+	"github.com/walesey/go-bundle/file"
 	"github.com/walesey/go-bundle/token"
 )
 
@@ -86,6 +87,8 @@ func (self *_parser) parseStatement() ast.Statement {
 		return self.parseVariableStatement()
 	case token.FUNCTION:
 		return self.parseFunctionStatement()
+	case token.CLASS:
+		return self.parseClassStatement()
 	case token.SWITCH:
 		return self.parseSwitchStatement()
 	case token.RETURN:
@@ -98,6 +101,10 @@ func (self *_parser) parseStatement() ast.Statement {
 		return self.parseImportStatement()
 	case token.EXPORT:
 		return self.parseExportStatement()
+	case token.IDENTIFIER:
+		if self.isAsyncFunction() {
+			return self.parseFunctionStatement()
+		}
 	}
 
 	var comments []*ast.Comment
@@ -170,23 +177,27 @@ func (self *_parser) parseTryStatement() ast.Statement {
 			self.comments.Unset()
 		}
 		self.next()
-		self.expect(token.LEFT_PARENTHESIS)
-		if self.token != token.IDENTIFIER {
-			self.expect(token.IDENTIFIER)
-			self.nextStatement()
-			return &ast.BadStatement{From: catch, To: self.idx}
+		var identifier *ast.Identifier
+		if self.token == token.LEFT_BRACE {
+			self.requireVersion(catch, 2019, "Optional catch binding")
 		} else {
-			identifier := self.parseIdentifier()
-			self.expect(token.RIGHT_PARENTHESIS)
-			node.Catch = &ast.CatchStatement{
-				Catch:     catch,
-				Parameter: identifier,
-				Body:      self.parseBlockStatement(),
+			self.expect(token.LEFT_PARENTHESIS)
+			if self.token != token.IDENTIFIER {
+				self.expect(token.IDENTIFIER)
+				self.nextStatement()
+				return &ast.BadStatement{From: catch, To: self.idx}
 			}
+			identifier = self.parseIdentifier()
+			self.expect(token.RIGHT_PARENTHESIS)
+		}
+		node.Catch = &ast.CatchStatement{
+			Catch:     catch,
+			Parameter: identifier,
+			Body:      self.parseBlockStatement(),
+		}
 
-			if self.mode&StoreComments != 0 {
-				self.comments.CommentMap.AddComments(node.Catch.Body, self.comments.FetchAll(), ast.TRAILING)
-			}
+		if self.mode&StoreComments != 0 {
+			self.comments.CommentMap.AddComments(node.Catch.Body, self.comments.FetchAll(), ast.TRAILING)
 		}
 	}
 
@@ -219,13 +230,30 @@ func (self *_parser) parseFunctionParameterList() *ast.ParameterList {
 	if self.mode&StoreComments != 0 {
 		self.comments.Unset()
 	}
-	var list []*ast.Identifier
+	node := &ast.ParameterList{
+		Opening: opening,
+	}
 	for self.token != token.RIGHT_PARENTHESIS && self.token != token.EOF {
+		if self.token == token.SPREAD {
+			self.requireVersion(self.expect(token.SPREAD), 2015, "Rest parameter")
+			node.Rest = true
+		}
 		if self.token != token.IDENTIFIER {
 			self.expect(token.IDENTIFIER)
 		} else {
 			identifier := self.parseIdentifier()
-			list = append(list, identifier)
+			node.List = append(node.List, identifier)
+		}
+		if self.token == token.ASSIGN && !node.Rest {
+			self.requireVersion(self.expect(token.ASSIGN), 2015, "Default parameter")
+			for len(node.Defaults) < len(node.List)-1 {
+				node.Defaults = append(node.Defaults, nil)
+			}
+			node.Defaults = append(node.Defaults, self.parseAssignmentExpression())
+		}
+		if node.Rest {
+			// the rest parameter is the last
+			break
 		}
 		if self.token != token.RIGHT_PARENTHESIS {
 			if self.mode&StoreComments != 0 {
@@ -234,13 +262,14 @@ func (self *_parser) parseFunctionParameterList() *ast.ParameterList {
 			self.expect(token.COMMA)
 		}
 	}
-	closing := self.expect(token.RIGHT_PARENTHESIS)
-
-	return &ast.ParameterList{
-		Opening: opening,
-		List:    list,
-		Closing: closing,
+	if node.Defaults != nil {
+		for len(node.Defaults) < len(node.List) {
+			node.Defaults = append(node.Defaults, nil)
+		}
 	}
+	node.Closing = self.expect(token.RIGHT_PARENTHESIS)
+
+	return node
 }
 
 func (self *_parser) parseFunctionStatement() *ast.FunctionStatement {
@@ -249,7 +278,7 @@ func (self *_parser) parseFunctionStatement() *ast.FunctionStatement {
 		comments = self.comments.FetchAll()
 	}
 	function := &ast.FunctionStatement{
-		Function: self.parseFunction(true),
+		Function: self.parseFunction(true, self.parseAsync()),
 	}
 	if self.mode&StoreComments != 0 {
 		self.comments.CommentMap.AddComments(function, comments, ast.LEADING)
@@ -258,10 +287,16 @@ func (self *_parser) parseFunctionStatement() *ast.FunctionStatement {
 	return function
 }
 
-func (self *_parser) parseFunction(declaration bool) *ast.FunctionLiteral {
+// parseFunction parses a function, whose async, if it is async, is already
+// parsed at the position async.
+func (self *_parser) parseFunction(declaration bool, async file.Idx) *ast.FunctionLiteral {
 
 	node := &ast.FunctionLiteral{
 		Function: self.expect(token.FUNCTION),
+		Async:    async != 0,
+	}
+	if node.Async {
+		self.requireVersion(async, 2017, "Async function")
 	}
 
 	var name *ast.Identifier
@@ -292,6 +327,7 @@ func (self *_parser) parseFunctionBlock(node *ast.FunctionLiteral) {
 		self.openScope()
 		inFunction := self.scope.inFunction
 		self.scope.inFunction = true
+		self.scope.inAsync = node.Async
 		defer func() {
 			self.scope.inFunction = inFunction
 			self.closeScope()
@@ -317,11 +353,13 @@ func (self *_parser) parseDebuggerStatement() ast.Statement {
 }
 
 func (self *_parser) parseReturnStatement() ast.Statement {
-	idx := self.expect(token.RETURN)
+	// the comments after return are left for the argument
 	var comments []*ast.Comment
 	if self.mode&StoreComments != 0 {
 		comments = self.comments.FetchAll()
+		self.comments.Unset()
 	}
+	idx := self.expect(token.RETURN)
 
 	if !self.scope.inFunction {
 		self.error(idx, "Illegal return statement")
@@ -363,6 +401,7 @@ func (self *_parser) parseThrowStatement() ast.Statement {
 	}
 
 	node := &ast.ThrowStatement{
+		Throw:    idx,
 		Argument: self.parseExpression(),
 	}
 	if self.mode&StoreComments != 0 {
@@ -379,12 +418,13 @@ func (self *_parser) parseSwitchStatement() ast.Statement {
 	if self.mode&StoreComments != 0 {
 		comments = self.comments.FetchAll()
 	}
-	self.expect(token.SWITCH)
+	idx := self.expect(token.SWITCH)
 	if self.mode&StoreComments != 0 {
 		comments = append(comments, self.comments.FetchAll()...)
 	}
 	self.expect(token.LEFT_PARENTHESIS)
 	node := &ast.SwitchStatement{
+		Switch:       idx,
 		Discriminant: self.parseExpression(),
 		Default:      -1,
 	}
@@ -522,6 +562,28 @@ func (self *_parser) parseForIn(into ast.Expression) *ast.ForInStatement {
 	return forin
 }
 
+func (self *_parser) parseForOf(into ast.Expression) *ast.ForOfStatement {
+
+	// Already have consumed "<into> of"
+
+	source := self.parseAssignmentExpression()
+	self.expect(token.RIGHT_PARENTHESIS)
+	body := self.parseIterationStatement()
+
+	forof := &ast.ForOfStatement{
+		Into:   into,
+		Source: source,
+		Body:   body,
+	}
+
+	return forof
+}
+
+// isOf reports whether the current token is the of of a for-of statement.
+func (self *_parser) isOf() bool {
+	return self.token == token.IDENTIFIER && self.literal == "of"
+}
+
 func (self *_parser) parseFor(initializer ast.Expression) *ast.ForStatement {
 
 	// Already have consumed "<initializer> ;"
@@ -566,13 +628,15 @@ func (self *_parser) parseForOrForInStatement() ast.Statement {
 
 	var left []ast.Expression
 
-	forIn := false
+	forIn, forOf := false, false
+	declaration := token.VAR
 	if self.token != token.SEMICOLON {
 
 		allowIn := self.scope.allowIn
 		self.scope.allowIn = false
-		if self.token == token.VAR {
+		if self.token == token.VAR || self.token == token.LET || self.token == token.CONST {
 			var_ := self.idx
+			declaration = self.token
 			var varComments []*ast.Comment
 			if self.mode&StoreComments != 0 {
 				varComments = self.comments.FetchAll()
@@ -587,22 +651,57 @@ func (self *_parser) parseForOrForInStatement() ast.Statement {
 				self.next() // in
 				forIn = true
 				left = []ast.Expression{list[0]} // There is only one declaration
+			} else if len(list) == 1 && self.isOf() {
+				self.next() // of
+				forOf = true
+				left = []ast.Expression{list[0]}
 			} else {
 				left = list
 			}
 			if self.mode&StoreComments != 0 {
 				self.comments.CommentMap.AddComments(left[0], varComments, ast.LEADING)
 			}
+			if declaration != token.VAR && !forOf {
+				// only the declarations of for-of loops are lexical
+				self.error(var_, "Unexpected token %v", declaration)
+			} else if declaration != token.VAR {
+				self.requireVersion(var_, 2015, declaration.String()+" declaration")
+			}
 		} else {
 			left = append(left, self.parseExpression())
 			if self.token == token.IN {
 				self.next()
 				forIn = true
+			} else if self.isOf() {
+				self.next()
+				forOf = true
 			}
 		}
 		self.scope.allowIn = allowIn
 	}
 
+	if forOf {
+		switch left[0].(type) {
+		case *ast.Identifier, *ast.DotExpression, *ast.BracketExpression, *ast.VariableExpression:
+		default:
+			self.error(idx, "Invalid left-hand side in for-of")
+			self.nextStatement()
+			return &ast.BadStatement{From: idx, To: self.idx}
+		}
+		self.requireVersion(idx, 2015, "for-of statement")
+
+		forof := self.parseForOf(left[0])
+		forof.For = idx
+		if _, ok := left[0].(*ast.VariableExpression); ok {
+			forof.Token = declaration
+		}
+		if self.mode&StoreComments != 0 {
+			self.comments.CommentMap.AddComments(forof, comments, ast.LEADING)
+			self.comments.CommentMap.AddComments(forof, forComments, ast.FOR)
+		}
+		return forof
+	}
+
 	if forIn {
 		switch left[0].(type) {
 		case *ast.Identifier, *ast.DotExpression, *ast.BracketExpression, *ast.VariableExpression:
@@ -614,6 +713,7 @@ func (self *_parser) parseForOrForInStatement() ast.Statement {
 		}
 
 		forin := self.parseForIn(left[0])
+		forin.For = idx
 		if self.mode&StoreComments != 0 {
 			self.comments.CommentMap.AddComments(forin, comments, ast.LEADING)
 			self.comments.CommentMap.AddComments(forin, forComments, ast.FOR)
@@ -631,6 +731,7 @@ func (self *_parser) parseForOrForInStatement() ast.Statement {
 		initializer = &ast.SequenceExpression{Sequence: left}
 	}
 	forstatement := self.parseFor(initializer)
+	forstatement.For = idx
 
 	if self.mode&StoreComments != 0 {
 		self.comments.CommentMap.AddComments(forstatement, comments, ast.LEADING)
@@ -644,26 +745,41 @@ func (self *_parser) parseVariableStatement() *ast.VariableStatement {
 	if self.mode&StoreComments != 0 {
 		comments = self.comments.FetchAll()
 	}
-	constant := self.token == token.CONST
+	tkn := self.token
 	idx := self.idx
 	self.next()
 
 	var list []ast.Expression
+	var names []*ast.Identifier
 
-	if self.token == token.LEFT_BRACE {
-		list = self.parseDestructureVariableStatement()
+	if tkn != token.VAR {
+		self.requireVersion(idx, 2015, tkn.String()+" declaration")
+	}
+	destructuring := self.token == token.LEFT_BRACE
+	if destructuring {
+		self.requireVersion(self.idx, 2015, "Destructuring")
+		list, names = self.parseDestructureVariableStatement()
 	} else {
 		list = self.parseVariableDeclarationList(idx)
 	}
 
 	statement := &ast.VariableStatement{
-		Var:      idx,
-		List:     list,
-		Constant: constant,
+		Var:           idx,
+		List:          list,
+		Constant:      tkn == token.CONST,
+		Token:         tkn,
+		Destructuring: destructuring,
 	}
 	if self.mode&StoreComments != 0 {
 		self.comments.CommentMap.AddComments(statement, comments, ast.LEADING)
 		self.comments.Unset()
+
+		// the comments of the names of a destructuring, which are not
+		// written, belong to the statement
+		for _, name := range names {
+			self.comments.CommentMap.AddComments(statement, self.comments.CommentMap[name], ast.TRAILING)
+			delete(self.comments.CommentMap, name)
+		}
 	}
 	// self.semicolon()
 
@@ -681,13 +797,13 @@ func (self *_parser) parseDoWhileStatement() ast.Statement {
 	if self.mode&StoreComments != 0 {
 		comments = self.comments.FetchAll()
 	}
-	self.expect(token.DO)
+	idx := self.expect(token.DO)
 	var doComments []*ast.Comment
 	if self.mode&StoreComments != 0 {
 		doComments = self.comments.FetchAll()
 	}
 
-	node := &ast.DoWhileStatement{}
+	node := &ast.DoWhileStatement{Do: idx}
 	if self.token == token.LEFT_BRACE {
 		node.Body = self.parseBlockStatement()
 	} else {
@@ -717,7 +833,7 @@ func (self *_parser) parseWhileStatement() ast.Statement {
 	if self.mode&StoreComments != 0 {
 		comments = self.comments.FetchAll()
 	}
-	self.expect(token.WHILE)
+	idx := self.expect(token.WHILE)
 
 	var whileComments []*ast.Comment
 	if self.mode&StoreComments != 0 {
@@ -726,7 +842,8 @@ func (self *_parser) parseWhileStatement() ast.Statement {
 
 	self.expect(token.LEFT_PARENTHESIS)
 	node := &ast.WhileStatement{
-		Test: self.parseExpression(),
+		While: idx,
+		Test:  self.parseExpression(),
 	}
 	self.expect(token.RIGHT_PARENTHESIS)
 	node.Body = self.parseIterationStatement()
@@ -744,7 +861,7 @@ func (self *_parser) parseIfStatement() ast.Statement {
 	if self.mode&StoreComments != 0 {
 		comments = self.comments.FetchAll()
 	}
-	self.expect(token.IF)
+	idx := self.expect(token.IF)
 	var ifComments []*ast.Comment
 	if self.mode&StoreComments != 0 {
 		ifComments = self.comments.FetchAll()
@@ -752,6 +869,7 @@ func (self *_parser) parseIfStatement() ast.Statement {
 
 	self.expect(token.LEFT_PARENTHESIS)
 	node := &ast.IfStatement{
+		If:   idx,
 		Test: self.parseExpression(),
 	}
 	self.expect(token.RIGHT_PARENTHESIS)
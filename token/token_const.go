@@ -20,6 +20,7 @@ const (
 	MULTIPLY  // *
 	SLASH     // /
 	REMAINDER // %
+	EXPONENT  // **
 
 	AND                  // &
 	OR                   // |
@@ -34,6 +35,7 @@ const (
 	MULTIPLY_ASSIGN  // *=
 	QUOTIENT_ASSIGN  // /=
 	REMAINDER_ASSIGN // %=
+	EXPONENT_ASSIGN  // **=
 
 	AND_ASSIGN                  // &=
 	OR_ASSIGN                   // |=
@@ -43,10 +45,11 @@ const (
 	UNSIGNED_SHIFT_RIGHT_ASSIGN // >>>=
 	AND_NOT_ASSIGN              // &^=
 
-	LOGICAL_AND // &&
-	LOGICAL_OR  // ||
-	INCREMENT   // ++
-	DECREMENT   // --
+	LOGICAL_AND        // &&
+	LOGICAL_OR         // ||
+	NULLISH_COALESCING // ??
+	INCREMENT          // ++
+	DECREMENT          // --
 
 	EQUAL        // ==
 	STRICT_EQUAL // ===
@@ -74,6 +77,7 @@ const (
 	SEMICOLON         // ;
 	COLON             // :
 	QUESTION_MARK     // ?
+	OPTIONAL_CHAINING // ?.
 	BACKSLASH
 
 	firstKeyword
@@ -118,6 +122,9 @@ const (
 	LET
 	EXPORT
 	IMPORT
+	CLASS
+	EXTENDS
+	SUPER
 
 	lastKeyword
 )
@@ -138,6 +145,7 @@ var token2string = [...]string{
 	MULTIPLY:                    "*",
 	SLASH:                       "/",
 	REMAINDER:                   "%",
+	EXPONENT:                    "**",
 	AND:                         "&",
 	OR:                          "|",
 	EXCLUSIVE_OR:                "^",
@@ -150,6 +158,7 @@ var token2string = [...]string{
 	MULTIPLY_ASSIGN:             "*=",
 	QUOTIENT_ASSIGN:             "/=",
 	REMAINDER_ASSIGN:            "%=",
+	EXPONENT_ASSIGN:             "**=",
 	AND_ASSIGN:                  "&=",
 	OR_ASSIGN:                   "|=",
 	EXCLUSIVE_OR_ASSIGN:         "^=",
@@ -159,6 +168,7 @@ var token2string = [...]string{
 	AND_NOT_ASSIGN:              "&^=",
 	LOGICAL_AND:                 "&&",
 	LOGICAL_OR:                  "||",
+	NULLISH_COALESCING:          "??",
 	INCREMENT:                   "++",
 	DECREMENT:                   "--",
 	EQUAL:                       "==",
@@ -183,6 +193,7 @@ var token2string = [...]string{
 	SEMICOLON:                   ";",
 	COLON:                       ":",
 	QUESTION_MARK:               "?",
+	OPTIONAL_CHAINING:           "?.",
 	BACKSLASH:                   "\\",
 	IF:                          "if",
 	IN:                          "in",
@@ -218,6 +229,9 @@ var token2string = [...]string{
 	LET:      "let",
 	EXPORT:   "export",
 	IMPORT:   "import",
+	CLASS:    "class",
+	EXTENDS:  "extends",
+	SUPER:    "super",
 }
 
 var keywordTable = map[string]_keyword{
@@ -312,6 +326,15 @@ var keywordTable = map[string]_keyword{
 	"let": _keyword{
 		token: LET,
 	},
+	"class": _keyword{
+		token: CLASS,
+	},
+	"extends": _keyword{
+		token: EXTENDS,
+	},
+	"super": _keyword{
+		token: SUPER,
+	},
 
 	"enum": _keyword{
 		token:         KEYWORD,